@@ -0,0 +1,220 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Principal identity authenticated by Auth middleware
+type Principal struct {
+	// ID of user, client or key owner
+	ID string `json:"id"`
+	// Scheme credentials were presented with
+	Scheme AuthType `json:"scheme"`
+	// Scope granted to principal, checked by RequireScopes
+	Scope string `json:"scope,omitempty"`
+	// Attributes set by verifier, e.g. roles or tenant
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+}
+
+// BasicVerifier verify username and password of Basic scheme.
+//
+// Verifiers return nil principal or fiber error with status 401 when credentials are invalid,
+// other errors are returned to error handler as is.
+type BasicVerifier interface {
+	VerifyBasic(ctx context.Context, username, password string) (*Principal, error)
+}
+
+// BearerVerifier verify token of Bearer scheme.
+type BearerVerifier interface {
+	VerifyBearer(ctx context.Context, token string) (*Principal, error)
+}
+
+// APIKeyVerifier verify API key of request.
+type APIKeyVerifier interface {
+	VerifyAPIKey(ctx context.Context, key string) (*Principal, error)
+}
+
+// BasicVerifierFunc adapt function to BasicVerifier
+type BasicVerifierFunc func(ctx context.Context, username, password string) (*Principal, error)
+
+func (f BasicVerifierFunc) VerifyBasic(ctx context.Context, username, password string) (*Principal, error) {
+	return f(ctx, username, password)
+}
+
+// BearerVerifierFunc adapt function to BearerVerifier
+type BearerVerifierFunc func(ctx context.Context, token string) (*Principal, error)
+
+func (f BearerVerifierFunc) VerifyBearer(ctx context.Context, token string) (*Principal, error) {
+	return f(ctx, token)
+}
+
+// APIKeyVerifierFunc adapt function to APIKeyVerifier
+type APIKeyVerifierFunc func(ctx context.Context, key string) (*Principal, error)
+
+func (f APIKeyVerifierFunc) VerifyAPIKey(ctx context.Context, key string) (*Principal, error) {
+	return f(ctx, key)
+}
+
+// OauthBearerVerifier verify bearer access tokens by validator, e.g. introspection or token store.
+// Sender-constrained tokens are rejected, use OauthResource to accept DPoP.
+//
+// Optional audience of this resource server, tokens without it in aud are rejected.
+func OauthBearerVerifier(validator OauthTokenValidator, audience ...string) BearerVerifier {
+	return BearerVerifierFunc(func(ctx context.Context, token string) (*Principal, error) {
+		info, err := validator.ValidateToken(ctx, token)
+		if err != nil {
+			// validator error may reveal introspection endpoint or store details
+			return nil, fiber.NewError(http.StatusServiceUnavailable, "token validation unavailable")
+		}
+		if !info.Active || info.Cnf != nil || (info.Exp != 0 && time.Now().Unix() >= info.Exp) {
+			return nil, nil
+		}
+		if len(audience) != 0 && audience[0] != "" && !info.HasAudience(audience[0]) {
+			return nil, nil
+		}
+		id := info.Sub
+		if id == "" {
+			id = info.ClientID
+		}
+		return &Principal{
+			ID:     id,
+			Scheme: BearerToken,
+			Scope:  info.Scope,
+			Attributes: map[string]interface{}{
+				"client_id": info.ClientID,
+			},
+		}, nil
+	})
+}
+
+// AuthConfig defines the config for Auth middleware, schemes without verifier are not accepted.
+type AuthConfig struct {
+	// Next defines a function to skip this middleware when returned true.
+	Next func(c *fiber.Ctx) bool
+
+	Basic  BasicVerifier
+	Bearer BearerVerifier
+	APIKey APIKeyVerifier
+
+	// APIKeyHeader header carrying API key
+	//
+	// Optional. Default: "X-API-Key"
+	APIKeyHeader string
+
+	// APIKeyQuery query parameter carrying API key, not read when empty
+	APIKeyQuery string
+
+	// Realm of WWW-Authenticate challenge
+	Realm string
+
+	// Optional let requests without credentials pass anonymously, Ctx.Principal is nil.
+	// Invalid credentials are still rejected.
+	Optional bool
+}
+
+const localsPrincipal = "helpers.principal"
+
+// Auth creates a middleware that authenticates request by the first credentials present,
+// Authorization header then API key header, the principal is available by Ctx.Principal.
+func Auth(config AuthConfig) fiber.Handler {
+	if config.APIKeyHeader == "" {
+		config.APIKeyHeader = "X-API-Key"
+	}
+	return func(c *fiber.Ctx) error {
+		if config.Next != nil && config.Next(c) {
+			return c.Next()
+		}
+		ctx := c.UserContext()
+
+		var (
+			scheme    AuthType
+			principal *Principal
+			err       error
+		)
+		if header := c.Get(fiber.HeaderAuthorization); header != "" {
+			auth, authErr := ExtractAuthString(header)
+			if authErr != nil {
+				return config.unauthorized(c, "", authErr.Error())
+			}
+			scheme = auth.Type
+			switch {
+			case scheme == BasicAuth && config.Basic != nil:
+				principal, err = config.Basic.VerifyBasic(ctx, auth.Username, auth.Password)
+			case scheme == BearerToken && config.Bearer != nil:
+				principal, err = config.Bearer.VerifyBearer(ctx, auth.Token)
+			case scheme == APIKeyAuth && config.APIKey != nil:
+				principal, err = config.APIKey.VerifyAPIKey(ctx, auth.Token)
+			default:
+				return config.unauthorized(c, "", "unsupported authorization scheme")
+			}
+		} else if key := config.apiKey(c); key != "" && config.APIKey != nil {
+			scheme = APIKeyAuth
+			principal, err = config.APIKey.VerifyAPIKey(ctx, key)
+		} else {
+			if config.Optional {
+				return c.Next()
+			}
+			return config.unauthorized(c, "", "missing credentials")
+		}
+
+		if err != nil {
+			var fiberErr *fiber.Error
+			if !errors.As(err, &fiberErr) || fiberErr.Code != http.StatusUnauthorized {
+				return err
+			}
+			return config.unauthorized(c, scheme, fiberErr.Message)
+		}
+		if principal == nil {
+			return config.unauthorized(c, scheme, "invalid credentials")
+		}
+		if principal.Scheme == "" {
+			// verifier may return shared principal, e.g. of static API key
+			copied := *principal
+			copied.Scheme = scheme
+			principal = &copied
+		}
+		c.Locals(localsPrincipal, principal)
+		return c.Next()
+	}
+}
+
+func (config AuthConfig) apiKey(c *fiber.Ctx) (key string) {
+	if key = c.Get(config.APIKeyHeader); key == "" && config.APIKeyQuery != "" {
+		key = c.Query(config.APIKeyQuery)
+	}
+	return
+}
+
+// unauthorized challenge every accepted scheme, the scheme of rejected credentials carries the error.
+func (config AuthConfig) unauthorized(c *fiber.Ctx, rejected AuthType, message string) error {
+	var challenges []AuthChallenge
+	if config.Bearer != nil {
+		challenge := AuthChallenge{Scheme: BearerToken, Realm: config.Realm}
+		if rejected == BearerToken {
+			challenge.Error = InvalidToken
+			challenge.ErrorDescription = message
+		}
+		challenges = append(challenges, challenge)
+	}
+	if config.Basic != nil {
+		challenges = append(challenges, AuthChallenge{Scheme: BasicAuth, Realm: config.Realm})
+	}
+	if config.APIKey != nil {
+		challenges = append(challenges, AuthChallenge{Scheme: APIKeyAuth, Realm: config.Realm})
+	}
+	if len(challenges) != 0 {
+		SetAuthChallenge(c, challenges...)
+	}
+	return fiber.NewError(http.StatusUnauthorized, message)
+}
+
+// Principal returns identity authenticated by Auth middleware, nil when request is anonymous.
+func (c *Ctx) Principal() *Principal {
+	principal, _ := c.Locals(localsPrincipal).(*Principal)
+	return principal
+}
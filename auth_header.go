@@ -0,0 +1,223 @@
+package helpers
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ExtractAuthString parse credentials of Authorization header (RFC 7235 section 2.1).
+//
+// Basic, Bearer, DPoP and ApiKey take token68, ApiKey also accepts key parameter.
+// Digest (RFC 7616) and HMAC-SHA256 take auth-params which are kept in Params with lower case names.
+func ExtractAuthString(authStr string) (auth HttpAuth, err error) {
+	scheme, rest, _ := strings.Cut(strings.TrimSpace(authStr), " ")
+	if scheme == "" || !isAuthToken(scheme) {
+		err = fiber.NewError(http.StatusUnauthorized, "invalid authorize format")
+		return
+	}
+	rest = strings.TrimLeft(rest, " ")
+	auth.Type = AuthType(strings.ToLower(scheme))
+
+	var token string
+	if isToken68(rest) {
+		token = rest
+	} else if rest != "" {
+		if auth.Params, err = parseAuthParams(rest); err != nil {
+			return
+		}
+	}
+
+	switch auth.Type {
+	case BasicAuth:
+		err = auth.parseBasic(token)
+	case BearerToken, DPoPToken:
+		if token == "" {
+			err = fiber.NewError(http.StatusUnauthorized, "invalid "+auth.Type.Name()+" token")
+		}
+		auth.Token = token
+	case APIKeyAuth:
+		if token == "" {
+			token = auth.Params["key"]
+		}
+		if token == "" {
+			err = fiber.NewError(http.StatusUnauthorized, "missing api key")
+		}
+		auth.Token = token
+	case DigestAuth:
+		// username* is RFC 8187 encoded, used when username is not ASCII (RFC 7616 section 3.4.4)
+		auth.Username = auth.Params["username"]
+		if extended, ok := auth.Params["username*"]; ok {
+			if auth.Username != "" {
+				return auth, fiber.NewError(http.StatusUnauthorized, "username and username* must not both be present")
+			}
+			if auth.Username, err = decodeExtValue(extended); err != nil {
+				return
+			}
+		}
+		if auth.Username == "" || auth.Params["response"] == "" {
+			err = fiber.NewError(http.StatusUnauthorized, "invalid digest credentials")
+		}
+	case HMACAuth:
+		auth.KeyID, auth.Signature = auth.Params["keyid"], auth.Params["signature"]
+		if auth.KeyID == "" || auth.Signature == "" {
+			err = fiber.NewError(http.StatusUnauthorized, "invalid hmac credentials")
+		}
+	default:
+		err = fiber.NewError(http.StatusUnauthorized, "invalid authorize type: "+scheme)
+	}
+	return
+}
+
+// parseBasic decode user-pass of Basic scheme (RFC 7617 section 2), password may contain colon.
+func (a *HttpAuth) parseBasic(token string) error {
+	if token == "" {
+		return fiber.NewError(http.StatusUnauthorized, "invalid basic credentials")
+	}
+	raw, err := base64.StdEncoding.DecodeString(token)
+	if err != nil {
+		return fiber.NewError(http.StatusUnauthorized, "invalid basic credentials: "+err.Error())
+	}
+	username, password, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return fiber.NewError(http.StatusUnauthorized, "invalid basic credentials: missing colon")
+	}
+	a.Username, a.Password = username, password
+	return nil
+}
+
+// decodeExtValue decode UTF-8 ext-value of RFC 8187 section 3.2, e.g. UTF-8'en'J%C3%A4s%C3%B8n
+func decodeExtValue(value string) (string, error) {
+	invalid := fiber.NewError(http.StatusUnauthorized, "invalid extended parameter value")
+	charset, rest, ok := strings.Cut(value, "'")
+	if !ok || !strings.EqualFold(charset, "UTF-8") {
+		return "", invalid
+	}
+	// language tag is ignored
+	if _, rest, ok = strings.Cut(rest, "'"); !ok {
+		return "", invalid
+	}
+	for i := 0; i < len(rest); i++ {
+		if c := rest[i]; !(isAlphaNum(c) || strings.IndexByte("!#$&+-.^_`|~%", c) >= 0) {
+			return "", invalid
+		}
+	}
+	decoded, err := url.PathUnescape(rest)
+	if err != nil || !utf8.ValidString(decoded) {
+		return "", invalid
+	}
+	return decoded, nil
+}
+
+// Param return auth-param of credentials, name is case-insensitive.
+func (a HttpAuth) Param(name string) string {
+	return a.Params[strings.ToLower(name)]
+}
+
+// parseAuthParams parse comma separated auth-params, names must be unique (RFC 7235 section 2.1).
+func parseAuthParams(s string) (params map[string]string, err error) {
+	params = make(map[string]string)
+	invalid := fiber.NewError(http.StatusUnauthorized, "invalid authorize parameters")
+	for i := 0; i < len(s); {
+		// skip empty list elements and whitespace
+		if s[i] == ',' || s[i] == ' ' || s[i] == '\t' {
+			i++
+			continue
+		}
+
+		start := i
+		for i < len(s) && isAuthTokenChar(s[i]) {
+			i++
+		}
+		name := strings.ToLower(s[start:i])
+		i = skipAuthSpace(s, i)
+		if name == "" || i == len(s) || s[i] != '=' {
+			return nil, invalid
+		}
+		i = skipAuthSpace(s, i+1)
+		if i == len(s) {
+			return nil, invalid
+		}
+
+		var value string
+		if s[i] == '"' {
+			var b strings.Builder
+			closed := false
+			for i++; i < len(s); i++ {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+				} else if s[i] == '"' {
+					closed = true
+					i++
+					break
+				}
+				b.WriteByte(s[i])
+			}
+			if !closed {
+				return nil, invalid
+			}
+			value = b.String()
+		} else {
+			start = i
+			for i < len(s) && isAuthTokenChar(s[i]) {
+				i++
+			}
+			if value = s[start:i]; value == "" {
+				return nil, invalid
+			}
+		}
+
+		if _, ok := params[name]; ok {
+			return nil, fiber.NewError(http.StatusUnauthorized, "duplicate authorize parameter: "+name)
+		}
+		params[name] = value
+		if i = skipAuthSpace(s, i); i < len(s) && s[i] != ',' {
+			return nil, invalid
+		}
+	}
+	return
+}
+
+func skipAuthSpace(s string, i int) int {
+	for i < len(s) && (s[i] == ' ' || s[i] == '\t') {
+		i++
+	}
+	return i
+}
+
+// isToken68 check token68 syntax: 1*( ALPHA / DIGIT / "-" / "." / "_" / "~" / "+" / "/" ) *"="
+func isToken68(s string) bool {
+	s = strings.TrimRight(s, "=")
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !(isAlphaNum(c) || strings.IndexByte("-._~+/", c) >= 0) {
+			return false
+		}
+	}
+	return true
+}
+
+func isAuthToken(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if !isAuthTokenChar(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// isAuthTokenChar check tchar of RFC 7230 section 3.2.6
+func isAuthTokenChar(c byte) bool {
+	return isAlphaNum(c) || strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0
+}
+
+func isAlphaNum(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
@@ -0,0 +1,80 @@
+package helpers
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestExtractAuthString(t *testing.T) {
+	t.Parallel()
+	basic := func(userPass string) string {
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(userPass))
+	}
+
+	auth, err := ExtractAuthString(basic("user:pa:ss"))
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, BasicAuth, auth.Type)
+	utils.AssertEqual(t, "user", auth.Username)
+	utils.AssertEqual(t, "pa:ss", auth.Password)
+
+	auth, err = ExtractAuthString(basic("user:"))
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, "", auth.Password)
+
+	auth, err = ExtractAuthString("bearer   mF_9.B5f-4.1JqM")
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, BearerToken, auth.Type)
+	utils.AssertEqual(t, "mF_9.B5f-4.1JqM", auth.Token)
+
+	auth, err = ExtractAuthString("ApiKey key-1")
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, APIKeyAuth, auth.Type)
+	utils.AssertEqual(t, "key-1", auth.Token)
+
+	auth, err = ExtractAuthString(`ApiKey key="key-2"`)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, "key-2", auth.Token)
+
+	auth, err = ExtractAuthString(`Digest username="Mufasa", realm="http-auth@example.org", uri="/dir/index.html", ` +
+		`algorithm=SHA-256, nonce="7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v", nc=00000001, cnonce="f2/wE4q74E6zIJEtWaHKaf5wv/H5QzzpXusqGemxURZJ", ` +
+		`qop=auth, response="753927fa0e85d155564e2e272a28d1802ca10daf4496794697cf8db5856cb6c1", opaque="FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS"`)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, DigestAuth, auth.Type)
+	utils.AssertEqual(t, "Mufasa", auth.Username)
+	utils.AssertEqual(t, "SHA-256", auth.Param("Algorithm"))
+	utils.AssertEqual(t, "/dir/index.html", auth.Params["uri"])
+
+	// non-ASCII username of RFC 7616 section 3.9.2
+	auth, err = ExtractAuthString(`Digest username*=UTF-8''J%C3%A4s%C3%B8n%20Doe, response="x"`)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, "Jäsøn Doe", auth.Username)
+
+	auth, err = ExtractAuthString(`HMAC-SHA256 keyId="k1",signedHeaders="host;x-date", signature="c2lnbmF0dXJl", note="say \"hi\""`)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, HMACAuth, auth.Type)
+	utils.AssertEqual(t, "k1", auth.KeyID)
+	utils.AssertEqual(t, "c2lnbmF0dXJl", auth.Signature)
+	utils.AssertEqual(t, "host;x-date", auth.Param("signedheaders"))
+	utils.AssertEqual(t, `say "hi"`, auth.Param("note"))
+
+	for _, header := range []string{
+		"",
+		"Basic",
+		"Bearer",
+		basic("no-colon"),
+		"Basic not-base64!",
+		"Token abc",
+		`Digest username="Mufasa"`,
+		`Digest username="a", username="b", response="x"`,
+		`Digest username="a", username*=UTF-8''b, response="x"`,
+		`Digest username*=ISO-8859-1''J%E4s%F8n, response="x"`,
+		`Digest username*=UTF-8''%FF, response="x"`,
+		`HMAC-SHA256 keyId="k1", signature="unterminated`,
+		`HMAC-SHA256 keyId=, signature=x`,
+	} {
+		_, err = ExtractAuthString(header)
+		utils.AssertEqual(t, true, err != nil, header)
+	}
+}
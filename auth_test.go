@@ -0,0 +1,146 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestAuth(t *testing.T) {
+	t.Parallel()
+	service := &Principal{ID: "service-1"}
+
+	config := AuthConfig{
+		Realm: "api",
+		Basic: BasicVerifierFunc(func(ctx context.Context, username, password string) (*Principal, error) {
+			if username != "user" || password != "passwd" {
+				return nil, nil
+			}
+			return &Principal{ID: username, Scope: "orders:read"}, nil
+		}),
+		Bearer: BearerVerifierFunc(func(ctx context.Context, token string) (*Principal, error) {
+			switch token {
+			case "valid":
+				return &Principal{ID: "client-1", Scope: "orders:read orders:write"}, nil
+			case "broken":
+				return nil, errors.New("store unavailable")
+			}
+			return nil, fiber.NewError(http.StatusUnauthorized, "token expired")
+		}),
+		APIKey: APIKeyVerifierFunc(func(ctx context.Context, key string) (*Principal, error) {
+			if key != "key-1" {
+				return nil, nil
+			}
+			return service, nil
+		}),
+		APIKeyQuery: "api_key",
+	}
+	optional := config
+	optional.Optional = true
+
+	app := fiber.New()
+	whoami := func(c *fiber.Ctx) error {
+		principal := (&Ctx{c}).Principal()
+		if principal == nil {
+			return c.SendString("anonymous")
+		}
+		return c.SendString(string(principal.Scheme) + ":" + principal.ID)
+	}
+	app.Get("/me", Auth(config), whoami)
+	app.Get("/public", Auth(optional), whoami)
+	app.Post("/orders", Auth(config), RequireScopes("orders:write"), whoami)
+
+	request := func(method, target string, header ...string) (int, string, string) {
+		req := httptest.NewRequest(method, target, nil)
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		resp, err := app.Test(req)
+		utils.AssertEqual(t, nil, err)
+		body := make([]byte, 64)
+		n, _ := resp.Body.Read(body)
+		return resp.StatusCode, string(body[:n]), resp.Header.Get(fiber.HeaderWWWAuthenticate)
+	}
+
+	status, body, _ := request(http.MethodGet, "/me", fiber.HeaderAuthorization, "Basic dXNlcjpwYXNzd2Q=")
+	utils.AssertEqual(t, http.StatusOK, status)
+	utils.AssertEqual(t, "basic:user", body)
+
+	status, body, _ = request(http.MethodGet, "/me", fiber.HeaderAuthorization, "Bearer valid")
+	utils.AssertEqual(t, http.StatusOK, status)
+	utils.AssertEqual(t, "bearer:client-1", body)
+
+	status, body, _ = request(http.MethodGet, "/me", "X-API-Key", "key-1")
+	utils.AssertEqual(t, http.StatusOK, status)
+	utils.AssertEqual(t, "apikey:service-1", body)
+
+	status, body, _ = request(http.MethodGet, "/me?api_key=key-1")
+	utils.AssertEqual(t, http.StatusOK, status)
+	utils.AssertEqual(t, "apikey:service-1", body)
+	// principal returned by verifier is not modified
+	utils.AssertEqual(t, AuthType(""), service.Scheme)
+
+	status, _, challenge := request(http.MethodGet, "/me")
+	utils.AssertEqual(t, http.StatusUnauthorized, status)
+	utils.AssertEqual(t, `Bearer realm="api", Basic realm="api", charset="UTF-8", ApiKey realm="api"`, challenge)
+
+	status, _, challenge = request(http.MethodGet, "/me", fiber.HeaderAuthorization, "Bearer expired")
+	utils.AssertEqual(t, http.StatusUnauthorized, status)
+	utils.AssertEqual(t, `Bearer realm="api", error="invalid_token", error_description="token expired", Basic realm="api", charset="UTF-8", ApiKey realm="api"`, challenge)
+
+	status, _, _ = request(http.MethodGet, "/me", fiber.HeaderAuthorization, "Bearer broken")
+	utils.AssertEqual(t, http.StatusInternalServerError, status)
+
+	status, _, _ = request(http.MethodGet, "/me", "X-API-Key", "key-2")
+	utils.AssertEqual(t, http.StatusUnauthorized, status)
+
+	// anonymous request passes optional route, invalid credentials do not
+	status, body, _ = request(http.MethodGet, "/public")
+	utils.AssertEqual(t, http.StatusOK, status)
+	utils.AssertEqual(t, "anonymous", body)
+	status, _, _ = request(http.MethodGet, "/public", fiber.HeaderAuthorization, "Basic dXNlcjp3cm9uZw==")
+	utils.AssertEqual(t, http.StatusUnauthorized, status)
+
+	// principal scope is checked by RequireScopes
+	status, _, _ = request(http.MethodPost, "/orders", fiber.HeaderAuthorization, "Bearer valid")
+	utils.AssertEqual(t, http.StatusOK, status)
+	status, _, challenge = request(http.MethodPost, "/orders", fiber.HeaderAuthorization, "Basic dXNlcjpwYXNzd2Q=")
+	utils.AssertEqual(t, http.StatusForbidden, status)
+	utils.AssertEqual(t, "", challenge)
+}
+
+type failingTokenValidator struct{}
+
+func (failingTokenValidator) ValidateToken(ctx context.Context, token string) (*OauthTokenInfo, error) {
+	return nil, errors.New("dial tcp 10.0.0.5:443: connection refused")
+}
+
+func TestOauthBearerVerifier(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	store := NewMemoryOauthStore()
+	utils.AssertEqual(t, nil, store.SaveToken(ctx, &OauthToken{
+		AccessToken: "token-1",
+		ClientID:    "web",
+		Audience:    []string{"orders"},
+		ExpiresAt:   time.Now().Add(time.Minute),
+	}))
+	validator := StoreTokenValidator{Tokens: store}
+
+	principal, err := OauthBearerVerifier(validator, "orders").VerifyBearer(ctx, "token-1")
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, "web", principal.ID)
+	principal, err = OauthBearerVerifier(validator, "billing").VerifyBearer(ctx, "token-1")
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, true, principal == nil)
+
+	// validator error is not sent to client
+	_, err = OauthBearerVerifier(failingTokenValidator{}).VerifyBearer(ctx, "token-1")
+	utils.AssertEqual(t, "token validation unavailable", err.Error())
+}
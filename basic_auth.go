@@ -0,0 +1,254 @@
+package helpers
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// BasicUserStore return password hash of user checked by CheckPasswordHash.
+//
+// Stores return fiber.ErrNotFound when user does not exist.
+type BasicUserStore interface {
+	PasswordHash(ctx context.Context, username string) (hash string, err error)
+}
+
+// basicHashStore store with own hash formats or sample hash of its users, e.g. Htpasswd
+type basicHashStore interface {
+	checkPassword(password, hash []byte) error
+	sampleHash() string
+}
+
+// BasicUsers password hashes by username, e.g. loaded from config
+type BasicUsers map[string]string
+
+func (u BasicUsers) PasswordHash(ctx context.Context, username string) (string, error) {
+	hash, ok := u[username]
+	if !ok {
+		return "", fiber.ErrNotFound
+	}
+	return hash, nil
+}
+
+func (u BasicUsers) checkPassword(password, hash []byte) error {
+	return checkPasswordFormat(password, hash)
+}
+
+// sampleHash return hash of first user by name.
+func (u BasicUsers) sampleHash() (hash string) {
+	first := ""
+	for username, h := range u {
+		if first == "" || username < first {
+			first, hash = username, h
+		}
+	}
+	return
+}
+
+// StoreBasicVerifier verify Basic credentials against password hashes of store.
+//
+// Unknown users are checked against dummy hash with format and cost of sampleHash,
+// so they take as long as known users. Dummy hash is created on first use of each format,
+// so it follows sample of store after reload. Users of store should share one format.
+//
+// Optional sampleHash. Default: hash of BasicUsers or Htpasswd user, otherwise bcrypt of DefaultCost
+func StoreBasicVerifier(users BasicUserStore, sampleHash ...string) BasicVerifier {
+	check := checkPasswordFormat
+	store, _ := users.(basicHashStore)
+	if store != nil {
+		check = store.checkPassword
+	}
+	dummies := &dummyHashes{}
+	sample := func() string {
+		if len(sampleHash) != 0 {
+			return sampleHash[0]
+		}
+		if store != nil {
+			return store.sampleHash()
+		}
+		return ""
+	}
+
+	return BasicVerifierFunc(func(ctx context.Context, username, password string) (*Principal, error) {
+		hash, err := users.PasswordHash(ctx, username)
+		if errors.Is(err, fiber.ErrNotFound) {
+			_ = check([]byte(password), dummies.like(sample()))
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err = check([]byte(password), []byte(hash)); errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &Principal{ID: username, Scheme: BasicAuth}, nil
+	})
+}
+
+// BasicAuthConfig defines the config for RequireBasicAuth middleware
+type BasicAuthConfig struct {
+	// Next defines a function to skip this middleware when returned true.
+	Next func(c *fiber.Ctx) bool
+
+	Users BasicUserStore
+
+	// Realm of WWW-Authenticate challenge
+	//
+	// Optional. Default: "Restricted"
+	Realm string
+}
+
+// RequireBasicAuth creates a middleware that requires Basic credentials of user in store,
+// the user is available by Ctx.Principal.
+func RequireBasicAuth(config BasicAuthConfig) fiber.Handler {
+	if config.Realm == "" {
+		config.Realm = "Restricted"
+	}
+	return Auth(AuthConfig{
+		Next:  config.Next,
+		Basic: StoreBasicVerifier(config.Users),
+		Realm: config.Realm,
+	})
+}
+
+// Htpasswd user store of Apache htpasswd file with bcrypt, SHA1 and APR1 hashes.
+type Htpasswd struct {
+	path     string
+	interval time.Duration
+
+	mu        sync.RWMutex
+	users     map[string]string
+	sample    string
+	modTime   time.Time
+	size      int64
+	checkedAt time.Time
+}
+
+// NewHtpasswd load htpasswd file, when reloadInterval is set the file is checked
+// for change at most once per interval and reloaded before lookup.
+func NewHtpasswd(path string, reloadInterval ...time.Duration) (h *Htpasswd, err error) {
+	h = &Htpasswd{path: path}
+	if len(reloadInterval) != 0 {
+		h.interval = reloadInterval[0]
+	}
+	if err = h.Reload(); err != nil {
+		h = nil
+	}
+	return
+}
+
+// Reload read htpasswd file again, current users are kept on error.
+func (h *Htpasswd) Reload() (err error) {
+	info, err := os.Stat(h.path)
+	if err != nil {
+		return
+	}
+	file, err := os.Open(h.path)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	users, err := ParseHtpasswd(file)
+	if err != nil {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.users = users
+	h.sample = BasicUsers(users).sampleHash()
+	h.modTime = info.ModTime()
+	h.size = info.Size()
+	h.checkedAt = time.Now()
+	return
+}
+
+func (h *Htpasswd) checkPassword(password, hash []byte) error {
+	return checkHtpasswdFormat(password, hash)
+}
+
+// sampleHash return hash of first user by name in file.
+func (h *Htpasswd) sampleHash() string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.sample
+}
+
+func (h *Htpasswd) PasswordHash(ctx context.Context, username string) (string, error) {
+	if h.interval > 0 {
+		// file may be replaced at the moment, users of last load are served until next check
+		_ = h.reloadChanged()
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	hash, ok := h.users[username]
+	if !ok {
+		return "", fiber.ErrNotFound
+	}
+	return hash, nil
+}
+
+// reloadChanged reload file when its modification time or size changed since last load.
+func (h *Htpasswd) reloadChanged() error {
+	h.mu.Lock()
+	if time.Since(h.checkedAt) < h.interval {
+		h.mu.Unlock()
+		return nil
+	}
+	h.checkedAt = time.Now()
+	modTime, size := h.modTime, h.size
+	h.mu.Unlock()
+
+	info, err := os.Stat(h.path)
+	if err != nil {
+		return err
+	}
+	if info.ModTime().Equal(modTime) && info.Size() == size {
+		return nil
+	}
+	return h.Reload()
+}
+
+// ParseHtpasswd parse htpasswd lines of user:hash, blank lines and # comments are skipped.
+// Only bcrypt ($2y$), SHA1 ({SHA}) and APR1 ($apr1$) hashes are accepted.
+func ParseHtpasswd(r io.Reader) (users map[string]string, err error) {
+	users = make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		username, hash, ok := strings.Cut(text, ":")
+		if !ok || username == "" {
+			return nil, fmt.Errorf("htpasswd line %d: invalid format", line)
+		}
+		if !isHtpasswdHash(hash) {
+			return nil, fmt.Errorf("htpasswd line %d: unsupported hash of user %s", line, username)
+		}
+		users[username] = hash
+	}
+	err = scanner.Err()
+	return
+}
+
+func isHtpasswdHash(hash string) bool {
+	for _, prefix := range []string{"$2a$", "$2b$", "$2y$", apr1Prefix, sha1Prefix} {
+		if strings.HasPrefix(hash, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,178 @@
+package helpers
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestCheckPasswordHashFormats(t *testing.T) {
+	t.Parallel()
+
+	bcryptHash, err := HashPasswordString("pa:ss")
+	utils.AssertEqual(t, nil, err)
+	argonHash, err := HashPasswordArgon2id("pa:ss", Argon2Params{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32})
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, true, strings.HasPrefix(argonHash, "$argon2id$v=19$m=1024,t=1,p=1$"))
+
+	for _, hash := range []string{
+		bcryptHash,
+		"$2y$" + strings.TrimPrefix(bcryptHash, "$2a$"),
+		argonHash,
+	} {
+		utils.AssertEqual(t, nil, CheckPasswordHashString("pa:ss", hash), hash)
+		utils.AssertEqual(t, bcrypt.ErrMismatchedHashAndPassword, CheckPasswordHashString("wrong", hash), hash)
+	}
+
+	// unsalted and MD5 htpasswd hashes are accepted by htpasswd store only
+	for password, hash := range map[string]string{
+		"pa:ss":  "$apr1$4zq3kvbx$dOBHn/ZkVDreoflNiIkcS.",
+		"secret": "{SHA}5en6G6MezRroT3XKqkdPOmY/BfQ=",
+		"":       "$apr1$xx$aGHxR9NsG1buO7l/rFJjm1",
+	} {
+		utils.AssertEqual(t, true, CheckPasswordHashString(password, hash) != nil, hash)
+		utils.AssertEqual(t, nil, checkHtpasswdFormat([]byte(password), []byte(hash)), hash)
+		utils.AssertEqual(t, bcrypt.ErrMismatchedHashAndPassword, checkHtpasswdFormat([]byte("wrong"), []byte(hash)), hash)
+	}
+
+	// zero iterations or parallelism is rejected instead of panic in argon2
+	for _, hash := range []string{
+		"$argon2id$v=19$m=1024,t=0,p=1$c2FsdHNhbHRzYWx0c2FsdA$aGFzaA",
+		"$argon2id$v=19$m=1024,t=1,p=0$c2FsdHNhbHRzYWx0c2FsdA$aGFzaA",
+	} {
+		utils.AssertEqual(t, true, CheckPasswordHashString("pa:ss", hash) != nil, hash)
+	}
+	_, err = HashPasswordArgon2id("pa:ss", Argon2Params{Memory: 1024, SaltLength: 16, KeyLength: 32})
+	utils.AssertEqual(t, true, err != nil)
+}
+
+func TestDummyHashLike(t *testing.T) {
+	t.Parallel()
+
+	lowCost, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	utils.AssertEqual(t, nil, err)
+	dummy, err := dummyHashLike(string(lowCost))
+	utils.AssertEqual(t, nil, err)
+	cost, err := bcrypt.Cost(dummy)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, bcrypt.MinCost, cost)
+
+	argonHash, err := HashPasswordArgon2id("secret", Argon2Params{Memory: 1024, Iterations: 2, Parallelism: 1, SaltLength: 16, KeyLength: 32})
+	utils.AssertEqual(t, nil, err)
+	dummy, err = dummyHashLike(argonHash)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, true, strings.HasPrefix(string(dummy), "$argon2id$v=19$m=1024,t=2,p=1$"))
+
+	for _, prefix := range []string{"$apr1$", "{SHA}"} {
+		dummy, err = dummyHashLike(prefix + "x")
+		utils.AssertEqual(t, nil, err)
+		utils.AssertEqual(t, true, strings.HasPrefix(string(dummy), prefix))
+	}
+	dummy, err = dummyHashLike("")
+	utils.AssertEqual(t, nil, err)
+	cost, _ = bcrypt.Cost(dummy)
+	utils.AssertEqual(t, bcrypt.DefaultCost, cost)
+}
+
+func TestDummyHashes(t *testing.T) {
+	t.Parallel()
+
+	dummies := &dummyHashes{}
+	apr1 := dummies.like("$apr1$4zq3kvbx$dOBHn/ZkVDreoflNiIkcS.")
+	utils.AssertEqual(t, true, strings.HasPrefix(string(apr1), "$apr1$"))
+	utils.AssertEqual(t, apr1, dummies.like("$apr1$other"))
+
+	// sample of another format after reload get its own dummy
+	lowCost, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	utils.AssertEqual(t, nil, err)
+	cost, err := bcrypt.Cost(dummies.like(string(lowCost)))
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, bcrypt.MinCost, cost)
+	utils.AssertEqual(t, 2, len(dummies.hashes))
+
+	path := filepath.Join(t.TempDir(), ".htpasswd")
+	utils.AssertEqual(t, nil, os.WriteFile(path, []byte("alice:$apr1$4zq3kvbx$dOBHn/ZkVDreoflNiIkcS.\n"), 0o600))
+	users, err := NewHtpasswd(path)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, apr1Prefix, dummyHashFormat(users.sampleHash()))
+	utils.AssertEqual(t, nil, os.WriteFile(path, []byte("alice:"+string(lowCost)+"\n"), 0o600))
+	utils.AssertEqual(t, nil, users.Reload())
+	utils.AssertEqual(t, "bcrypt:4", dummyHashFormat(users.sampleHash()))
+}
+
+func TestStoreBasicVerifierTiming(t *testing.T) {
+	t.Parallel()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), 6)
+	utils.AssertEqual(t, nil, err)
+	verifier := StoreBasicVerifier(BasicUsers{"alice": string(hash)})
+
+	// fastest of several runs, unknown user must cost about the same as known user
+	fastest := func(username string) (min time.Duration) {
+		for i := 0; i < 5; i++ {
+			start := time.Now()
+			principal, err := verifier.VerifyBasic(context.Background(), username, "wrong")
+			elapsed := time.Since(start)
+			utils.AssertEqual(t, nil, err)
+			utils.AssertEqual(t, true, principal == nil)
+			if i == 0 || elapsed < min {
+				min = elapsed
+			}
+		}
+		return
+	}
+	known, unknown := fastest("alice"), fastest("mallory")
+	utils.AssertEqual(t, true, unknown*3 > known && known*3 > unknown, fmt.Sprintf("known %s, unknown %s", known, unknown))
+}
+
+func TestRequireBasicAuth(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), ".htpasswd")
+	write := func(content string) {
+		utils.AssertEqual(t, nil, os.WriteFile(path, []byte(content), 0o600))
+	}
+	write("# users\nalice:$apr1$4zq3kvbx$dOBHn/ZkVDreoflNiIkcS.\n\nbob:{SHA}5en6G6MezRroT3XKqkdPOmY/BfQ=\n")
+	users, err := NewHtpasswd(path, time.Nanosecond)
+	utils.AssertEqual(t, nil, err)
+
+	app := fiber.New()
+	app.Get("/", RequireBasicAuth(BasicAuthConfig{Users: users}), func(c *fiber.Ctx) error {
+		return c.SendString((&Ctx{c}).Principal().ID)
+	})
+	request := func(username, password string) *http.Response {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(fiber.HeaderAuthorization, "Basic "+base64.StdEncoding.EncodeToString([]byte(username+":"+password)))
+		resp, err := app.Test(req)
+		utils.AssertEqual(t, nil, err)
+		return resp
+	}
+
+	utils.AssertEqual(t, http.StatusOK, request("alice", "pa:ss").StatusCode)
+	utils.AssertEqual(t, http.StatusOK, request("bob", "secret").StatusCode)
+	resp := request("alice", "wrong")
+	utils.AssertEqual(t, http.StatusUnauthorized, resp.StatusCode)
+	utils.AssertEqual(t, `Basic realm="Restricted", charset="UTF-8"`, resp.Header.Get(fiber.HeaderWWWAuthenticate))
+	utils.AssertEqual(t, http.StatusUnauthorized, request("carol", "secret").StatusCode)
+
+	// changed file is reloaded on next lookup
+	write("carol:{SHA}5en6G6MezRroT3XKqkdPOmY/BfQ=\n")
+	utils.AssertEqual(t, http.StatusOK, request("carol", "secret").StatusCode)
+	utils.AssertEqual(t, http.StatusUnauthorized, request("bob", "secret").StatusCode)
+
+	_, err = ParseHtpasswd(strings.NewReader("dave:plaintext\n"))
+	utils.AssertEqual(t, true, err != nil)
+	_, err = ParseHtpasswd(strings.NewReader("no-separator\n"))
+	utils.AssertEqual(t, true, err != nil)
+}
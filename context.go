@@ -1,7 +1,7 @@
 package helpers
 
 import (
-	"bytes"
+	"crypto/subtle"
 	"encoding/base64"
 	"net/http"
 	"net/url"
@@ -285,34 +285,31 @@ func (c *Ctx) ParamBool(name string) bool {
 	return v
 }
 
-func (c *Ctx) BasicAuth(user, passwd string) error {
-	// Get authorization header
-	authStr := c.Get(fiber.HeaderAuthorization)
-
-	authSlices := strings.Split(authStr, " ")
-	if len(authSlices) != 2 {
-		return fiber.NewError(http.StatusUnauthorized, "invalid authorization format")
-	}
-
-	// Check if the header contains content besides "basic".
-	if strings.ToLower(authSlices[0]) != "basic" {
-		return fiber.NewError(http.StatusUnauthorized, "invalid basic auth format")
-	}
-
-	// Decode the header contents
-	raw, err := base64.StdEncoding.DecodeString(authSlices[1])
+// BasicAuth check basic credentials of request, failure is 401 with Basic challenge of realm.
+//
+// Optional realm. Default: "Restricted"
+func (c *Ctx) BasicAuth(user, passwd string, realm ...string) (err error) {
+	defer func() {
+		if err != nil {
+			challenge := AuthChallenge{Scheme: BasicAuth, Realm: "Restricted"}
+			if len(realm) != 0 && realm[0] != "" {
+				challenge.Realm = realm[0]
+			}
+			SetAuthChallenge(c.Ctx, challenge)
+		}
+	}()
+
+	auth, err := ExtractAuthString(c.Get(fiber.HeaderAuthorization))
 	if err != nil {
-		return fiber.NewError(http.StatusUnauthorized, err.Error())
+		return
 	}
-
-	// Check if the credentials are in the correct form
-	// which is "username:password".
-	credentials := bytes.Split(raw, []byte(":"))
-	if len(credentials) != 2 {
+	if auth.Type != BasicAuth {
 		return fiber.NewError(http.StatusUnauthorized, "invalid basic auth format")
 	}
 
-	if bytes.Equal([]byte(user), credentials[0]) && bytes.Equal([]byte(passwd), credentials[1]) {
+	userMatch := subtle.ConstantTimeCompare([]byte(user), []byte(auth.Username))
+	passwdMatch := subtle.ConstantTimeCompare([]byte(passwd), []byte(auth.Password))
+	if userMatch&passwdMatch == 1 {
 		return nil
 	}
 
@@ -1,14 +1,18 @@
 package helpers
 
 import (
+	"errors"
 	"log"
 	"net/http"
 	"strings"
+
+	"github.com/gofiber/fiber/v2"
 )
 
 // Error represents an error that occurred while handling a request.
 type Error struct {
 	Code    int         `json:"code"`
+	Kind    ErrorKind   `json:"kind,omitempty"`
 	Source  interface{} `json:"source,omitempty"`
 	Title   string      `json:"title,omitempty"`
 	Message string      `json:"message,omitempty"`
@@ -46,4 +50,30 @@ func NewErrorSource(code int, source string, message ...string) (err *Error) {
 		Message: strings.Join(message, " \n"),
 	}
 	return
-}
\ No newline at end of file
+}
+
+// AsError converts err to *Error.
+//
+// *fiber.Error keeps its code, any other error becomes 500.
+func AsError(err error) (helperErr *Error) {
+	if err == nil {
+		return nil
+	}
+	var fiberErr *fiber.Error
+	switch {
+	case errors.As(err, &helperErr):
+	case errors.As(err, &fiberErr):
+		helperErr = &Error{
+			Code:    fiberErr.Code,
+			Title:   http.StatusText(fiberErr.Code),
+			Message: fiberErr.Message,
+		}
+	default:
+		helperErr = &Error{
+			Code:    http.StatusInternalServerError,
+			Title:   http.StatusText(http.StatusInternalServerError),
+			Message: err.Error(),
+		}
+	}
+	return
+}
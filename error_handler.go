@@ -0,0 +1,33 @@
+package helpers
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ErrorHandler is a fiber.ErrorHandler that renders errors as ResponseForm
+// with title and message in the request language.
+//
+//	app := fiber.New(fiber.Config{ErrorHandler: helpers.ErrorHandler})
+func ErrorHandler(c *fiber.Ctx, err error) error {
+	cc := Ctx{c}
+	lang := cc.Language()
+
+	var multiErr *MultiError
+	if errors.As(err, &multiErr) {
+		form := multiErr.ResponseForm()
+		for i, item := range multiErr.Items {
+			localized := item.Err.Localize(lang)
+			form.Errors[i].Title = localized.Title
+			form.Errors[i].Message = localized.Message
+		}
+		return c.Status(multiErr.StatusCode()).JSON(form)
+	}
+
+	helperErr := AsError(err).Localize(lang)
+
+	return c.Status(helperErr.Code).JSON(ResponseForm{
+		Errors: []ResponseError{ResponseError(*helperErr)},
+	})
+}
@@ -0,0 +1,216 @@
+package helpers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ErrorKind transport-neutral kind of error, named after gRPC canonical codes.
+//
+// Use it in domain code and message queues, then map to HTTP status or gRPC code at the edge.
+type ErrorKind string
+
+// ErrorKind constant
+const (
+	KindOK                 ErrorKind = "ok"
+	KindCanceled           ErrorKind = "canceled"
+	KindUnknown            ErrorKind = "unknown"
+	KindInvalidArgument    ErrorKind = "invalid_argument"
+	KindDeadlineExceeded   ErrorKind = "deadline_exceeded"
+	KindNotFound           ErrorKind = "not_found"
+	KindAlreadyExists      ErrorKind = "already_exists"
+	KindPermissionDenied   ErrorKind = "permission_denied"
+	KindResourceExhausted  ErrorKind = "resource_exhausted"
+	KindFailedPrecondition ErrorKind = "failed_precondition"
+	KindAborted            ErrorKind = "aborted"
+	KindOutOfRange         ErrorKind = "out_of_range"
+	KindUnimplemented      ErrorKind = "unimplemented"
+	KindInternal           ErrorKind = "internal"
+	KindUnavailable        ErrorKind = "unavailable"
+	KindDataLoss           ErrorKind = "data_loss"
+	KindUnauthenticated    ErrorKind = "unauthenticated"
+)
+
+// GRPCCode gRPC status code, values are identical to google.golang.org/grpc/codes.Code
+// so it converts directly:
+//
+//	status.Error(codes.Code(helpers.KindOf(err).GRPCCode()), err.Error())
+type GRPCCode uint32
+
+// GRPCCode constant
+const (
+	GRPCOK GRPCCode = iota
+	GRPCCanceled
+	GRPCUnknown
+	GRPCInvalidArgument
+	GRPCDeadlineExceeded
+	GRPCNotFound
+	GRPCAlreadyExists
+	GRPCPermissionDenied
+	GRPCResourceExhausted
+	GRPCFailedPrecondition
+	GRPCAborted
+	GRPCOutOfRange
+	GRPCUnimplemented
+	GRPCInternal
+	GRPCUnavailable
+	GRPCDataLoss
+	GRPCUnauthenticated
+)
+
+var kindGRPC = map[ErrorKind]GRPCCode{
+	KindOK:                 GRPCOK,
+	KindCanceled:           GRPCCanceled,
+	KindUnknown:            GRPCUnknown,
+	KindInvalidArgument:    GRPCInvalidArgument,
+	KindDeadlineExceeded:   GRPCDeadlineExceeded,
+	KindNotFound:           GRPCNotFound,
+	KindAlreadyExists:      GRPCAlreadyExists,
+	KindPermissionDenied:   GRPCPermissionDenied,
+	KindResourceExhausted:  GRPCResourceExhausted,
+	KindFailedPrecondition: GRPCFailedPrecondition,
+	KindAborted:            GRPCAborted,
+	KindOutOfRange:         GRPCOutOfRange,
+	KindUnimplemented:      GRPCUnimplemented,
+	KindInternal:           GRPCInternal,
+	KindUnavailable:        GRPCUnavailable,
+	KindDataLoss:           GRPCDataLoss,
+	KindUnauthenticated:    GRPCUnauthenticated,
+}
+
+// kindHTTP follows google.rpc.Code HTTP mapping
+var kindHTTP = map[ErrorKind]int{
+	KindOK:                 http.StatusOK,
+	KindCanceled:           499,
+	KindUnknown:            http.StatusInternalServerError,
+	KindInvalidArgument:    http.StatusBadRequest,
+	KindDeadlineExceeded:   http.StatusGatewayTimeout,
+	KindNotFound:           http.StatusNotFound,
+	KindAlreadyExists:      http.StatusConflict,
+	KindPermissionDenied:   http.StatusForbidden,
+	KindResourceExhausted:  http.StatusTooManyRequests,
+	KindFailedPrecondition: http.StatusBadRequest,
+	KindAborted:            http.StatusConflict,
+	KindOutOfRange:         http.StatusBadRequest,
+	KindUnimplemented:      http.StatusNotImplemented,
+	KindInternal:           http.StatusInternalServerError,
+	KindUnavailable:        http.StatusServiceUnavailable,
+	KindDataLoss:           http.StatusInternalServerError,
+	KindUnauthenticated:    http.StatusUnauthorized,
+}
+
+// HTTPStatus return HTTP status code of kind, unknown kind is 500.
+func (k ErrorKind) HTTPStatus() int {
+	if status, ok := kindHTTP[k]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// GRPCCode return gRPC code of kind, unknown kind is GRPCUnknown.
+func (k ErrorKind) GRPCCode() GRPCCode {
+	if code, ok := kindGRPC[k]; ok {
+		return code
+	}
+	return GRPCUnknown
+}
+
+// Kind return ErrorKind of gRPC code.
+func (c GRPCCode) Kind() ErrorKind {
+	for kind, code := range kindGRPC {
+		if code == c {
+			return kind
+		}
+	}
+	return KindUnknown
+}
+
+// String return name of code as used by gRPC, e.g. "NotFound".
+func (c GRPCCode) String() string {
+	kind := c.Kind()
+	switch {
+	case kind == KindOK:
+		return "OK"
+	case kind == KindUnknown && c != GRPCUnknown:
+		return "Code(" + strconv.FormatUint(uint64(c), 10) + ")"
+	}
+	var name strings.Builder
+	for _, word := range strings.Split(string(kind), "_") {
+		name.WriteString(strings.ToUpper(word[:1]) + word[1:])
+	}
+	return name.String()
+}
+
+// KindFromHTTP guess ErrorKind from HTTP status code.
+func KindFromHTTP(status int) ErrorKind {
+	switch status {
+	case http.StatusBadRequest:
+		return KindInvalidArgument
+	case http.StatusUnauthorized:
+		return KindUnauthenticated
+	case http.StatusForbidden:
+		return KindPermissionDenied
+	case http.StatusNotFound, http.StatusGone:
+		return KindNotFound
+	case http.StatusRequestTimeout, http.StatusGatewayTimeout:
+		return KindDeadlineExceeded
+	case http.StatusConflict:
+		return KindAlreadyExists
+	case http.StatusPreconditionFailed, http.StatusUnprocessableEntity:
+		return KindFailedPrecondition
+	case http.StatusRequestedRangeNotSatisfiable:
+		return KindOutOfRange
+	case http.StatusTooManyRequests, http.StatusRequestEntityTooLarge:
+		return KindResourceExhausted
+	case 499:
+		return KindCanceled
+	case http.StatusNotImplemented:
+		return KindUnimplemented
+	case http.StatusBadGateway, http.StatusServiceUnavailable:
+		return KindUnavailable
+	}
+	switch {
+	case status >= 200 && status < 300:
+		return KindOK
+	case status >= 400 && status < 500:
+		return KindInvalidArgument
+	case status >= 500:
+		return KindInternal
+	}
+	return KindUnknown
+}
+
+// KindOf return ErrorKind of any error.
+//
+// *Error with Kind set keeps it, other errors are guessed from HTTP status code.
+func KindOf(err error) ErrorKind {
+	if err == nil {
+		return KindOK
+	}
+	helperErr := AsError(err)
+	if helperErr.Kind != "" {
+		return helperErr.Kind
+	}
+	return KindFromHTTP(helperErr.Code)
+}
+
+// GRPCCode return gRPC code of error.
+func (e *Error) GRPCCode() GRPCCode {
+	return KindOf(e).GRPCCode()
+}
+
+// NewErrorKind create error of kind with matching HTTP status code.
+func NewErrorKind(kind ErrorKind, message ...string) (err *Error) {
+	err = NewErrorSource(kind.HTTPStatus(), WhereAmI(2), message...)
+	err.Kind = kind
+	return
+}
+
+// NewErrorGRPC create error from gRPC status code and message, e.g. returned by gRPC client.
+func NewErrorGRPC(code uint32, message ...string) (err *Error) {
+	kind := GRPCCode(code).Kind()
+	err = NewErrorSource(kind.HTTPStatus(), WhereAmI(2), message...)
+	err.Kind = kind
+	return
+}
@@ -0,0 +1,108 @@
+package helpers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestErrorKindMapping(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		kind   ErrorKind
+		status int
+		code   GRPCCode
+		name   string
+	}{
+		{KindOK, http.StatusOK, GRPCOK, "OK"},
+		{KindCanceled, 499, GRPCCanceled, "Canceled"},
+		{KindUnknown, http.StatusInternalServerError, GRPCUnknown, "Unknown"},
+		{KindInvalidArgument, http.StatusBadRequest, GRPCInvalidArgument, "InvalidArgument"},
+		{KindDeadlineExceeded, http.StatusGatewayTimeout, GRPCDeadlineExceeded, "DeadlineExceeded"},
+		{KindNotFound, http.StatusNotFound, GRPCNotFound, "NotFound"},
+		{KindAlreadyExists, http.StatusConflict, GRPCAlreadyExists, "AlreadyExists"},
+		{KindPermissionDenied, http.StatusForbidden, GRPCPermissionDenied, "PermissionDenied"},
+		{KindResourceExhausted, http.StatusTooManyRequests, GRPCResourceExhausted, "ResourceExhausted"},
+		{KindFailedPrecondition, http.StatusBadRequest, GRPCFailedPrecondition, "FailedPrecondition"},
+		{KindAborted, http.StatusConflict, GRPCAborted, "Aborted"},
+		{KindOutOfRange, http.StatusBadRequest, GRPCOutOfRange, "OutOfRange"},
+		{KindUnimplemented, http.StatusNotImplemented, GRPCUnimplemented, "Unimplemented"},
+		{KindInternal, http.StatusInternalServerError, GRPCInternal, "Internal"},
+		{KindUnavailable, http.StatusServiceUnavailable, GRPCUnavailable, "Unavailable"},
+		{KindDataLoss, http.StatusInternalServerError, GRPCDataLoss, "DataLoss"},
+		{KindUnauthenticated, http.StatusUnauthorized, GRPCUnauthenticated, "Unauthenticated"},
+	} {
+		utils.AssertEqual(t, tt.status, tt.kind.HTTPStatus(), string(tt.kind))
+		utils.AssertEqual(t, tt.code, tt.kind.GRPCCode(), string(tt.kind))
+		utils.AssertEqual(t, tt.kind, tt.code.Kind(), string(tt.kind))
+		utils.AssertEqual(t, tt.name, tt.code.String(), string(tt.kind))
+
+		err := NewErrorGRPC(uint32(tt.code), "message")
+		utils.AssertEqual(t, tt.kind, err.Kind, string(tt.kind))
+		utils.AssertEqual(t, tt.status, err.Code, string(tt.kind))
+		utils.AssertEqual(t, tt.code, err.GRPCCode(), string(tt.kind))
+	}
+
+	// unknown values
+	utils.AssertEqual(t, http.StatusInternalServerError, ErrorKind("teapot").HTTPStatus())
+	utils.AssertEqual(t, GRPCUnknown, ErrorKind("teapot").GRPCCode())
+	utils.AssertEqual(t, KindUnknown, GRPCCode(42).Kind())
+	utils.AssertEqual(t, "Code(42)", GRPCCode(42).String())
+	utils.AssertEqual(t, KindUnknown, NewErrorGRPC(42).Kind)
+}
+
+func TestKindFromHTTP(t *testing.T) {
+	t.Parallel()
+
+	for status, kind := range map[int]ErrorKind{
+		http.StatusOK:                           KindOK,
+		http.StatusNoContent:                    KindOK,
+		http.StatusBadRequest:                   KindInvalidArgument,
+		http.StatusUnauthorized:                 KindUnauthenticated,
+		http.StatusForbidden:                    KindPermissionDenied,
+		http.StatusNotFound:                     KindNotFound,
+		http.StatusGone:                         KindNotFound,
+		http.StatusRequestTimeout:               KindDeadlineExceeded,
+		http.StatusGatewayTimeout:               KindDeadlineExceeded,
+		http.StatusConflict:                     KindAlreadyExists,
+		http.StatusPreconditionFailed:           KindFailedPrecondition,
+		http.StatusUnprocessableEntity:          KindFailedPrecondition,
+		http.StatusRequestedRangeNotSatisfiable: KindOutOfRange,
+		http.StatusTooManyRequests:              KindResourceExhausted,
+		http.StatusRequestEntityTooLarge:        KindResourceExhausted,
+		499:                                     KindCanceled,
+		http.StatusNotImplemented:               KindUnimplemented,
+		http.StatusBadGateway:                   KindUnavailable,
+		http.StatusServiceUnavailable:           KindUnavailable,
+		http.StatusInternalServerError:          KindInternal,
+		http.StatusTeapot:                       KindInvalidArgument,
+		http.StatusHTTPVersionNotSupported:      KindInternal,
+		http.StatusFound:                        KindUnknown,
+		0:                                       KindUnknown,
+	} {
+		utils.AssertEqual(t, kind, KindFromHTTP(status), fmt.Sprint(status))
+	}
+}
+
+func TestKindOf(t *testing.T) {
+	t.Parallel()
+
+	utils.AssertEqual(t, KindOK, KindOf(nil))
+	utils.AssertEqual(t, KindInternal, KindOf(errors.New("db down")))
+	utils.AssertEqual(t, KindNotFound, KindOf(fiber.ErrNotFound))
+	utils.AssertEqual(t, KindNotFound, KindOf(fmt.Errorf("load order: %w", fiber.ErrNotFound)))
+
+	// kind set on error is kept through wrapping, not guessed from status
+	aborted := NewErrorKind(KindAborted, "retry transaction")
+	utils.AssertEqual(t, http.StatusConflict, aborted.Code)
+	utils.AssertEqual(t, KindAborted, KindOf(fmt.Errorf("checkout: %w", aborted)))
+	utils.AssertEqual(t, GRPCAborted, aborted.GRPCCode())
+
+	// error without kind is guessed from its status
+	utils.AssertEqual(t, KindPermissionDenied, KindOf(fmt.Errorf("wrapped: %w", NewError(http.StatusForbidden))))
+}
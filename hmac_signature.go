@@ -0,0 +1,352 @@
+package helpers
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"golang.org/x/crypto/sha3"
+)
+
+type HMACAlgorithm string
+
+const (
+	HMACSHA256  HMACAlgorithm = "hmac-sha256"
+	HMACSHA3256 HMACAlgorithm = "hmac-sha3-256"
+)
+
+// HMACKey secret shared with partner, algorithm is bound to key so request cannot choose it.
+type HMACKey struct {
+	ID     string
+	Secret []byte
+	// Algorithm of signature
+	//
+	// Optional. Default: HMACSHA256
+	Algorithm HMACAlgorithm
+}
+
+// HMACKeyStore lookup keys by key ID.
+//
+// Stores return fiber.ErrNotFound when key does not exist.
+type HMACKeyStore interface {
+	GetHMACKey(ctx context.Context, keyID string) (*HMACKey, error)
+}
+
+// HMACNonceStore remember used nonces, share one store between instances,
+// e.g. Redis SET NX with expiry, so a request cannot be replayed to another instance.
+type HMACNonceStore interface {
+	// UseNonce record nonce of key until expiresAt, false when it was already used.
+	UseNonce(ctx context.Context, keyID, nonce string, expiresAt time.Time) (bool, error)
+}
+
+// memoryNonceStore HMACNonceStore of single instance
+type memoryNonceStore struct {
+	replays *replayCache
+}
+
+func (s memoryNonceStore) UseNonce(ctx context.Context, keyID, nonce string, expiresAt time.Time) (bool, error) {
+	return s.replays.Add(keyID+" "+nonce, expiresAt), nil
+}
+
+// HMACKeys keys by key ID, e.g. loaded from config
+type HMACKeys map[string]*HMACKey
+
+func (k HMACKeys) GetHMACKey(ctx context.Context, keyID string) (*HMACKey, error) {
+	key, ok := k[keyID]
+	if !ok {
+		return nil, fiber.ErrNotFound
+	}
+	return key, nil
+}
+
+// Sum return base64 signature of string to sign.
+func (k *HMACKey) Sum(stringToSign string) (signature string, err error) {
+	var h func() hash.Hash
+	switch k.Algorithm {
+	case "", HMACSHA256:
+		h = sha256.New
+	case HMACSHA3256:
+		h = sha3.New256
+	default:
+		return "", fiber.NewError(http.StatusInternalServerError, "unsupported hmac algorithm: "+string(k.Algorithm))
+	}
+	mac := hmac.New(h, k.Secret)
+	mac.Write([]byte(stringToSign))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+// HMACStringToSign canonicalize request as lines of upper case method, path with sorted query,
+// lower case name:value of signed headers, timestamp, nonce and hex SHA-256 of body.
+func HMACStringToSign(method, requestURI string, signedHeaders []string, header func(name string) string, timestamp, nonce string, body []byte) string {
+	path, rawQuery, _ := strings.Cut(requestURI, "?")
+	if path == "" {
+		path = "/"
+	}
+	if query, err := url.ParseQuery(rawQuery); err == nil {
+		rawQuery = query.Encode()
+	}
+	if rawQuery != "" {
+		path += "?" + rawQuery
+	}
+
+	var b strings.Builder
+	b.WriteString(strings.ToUpper(method))
+	b.WriteByte('\n')
+	b.WriteString(path)
+	b.WriteByte('\n')
+	for _, name := range signedHeaders {
+		name = strings.ToLower(name)
+		b.WriteString(name)
+		b.WriteByte(':')
+		b.WriteString(strings.TrimSpace(header(name)))
+		b.WriteByte('\n')
+	}
+	b.WriteString(timestamp)
+	b.WriteByte('\n')
+	b.WriteString(nonce)
+	b.WriteByte('\n')
+	digest := sha256.Sum256(body)
+	b.WriteString(hex.EncodeToString(digest[:]))
+	return b.String()
+}
+
+// HMACSigner sign outgoing requests, e.g. webhooks to partners.
+type HMACSigner struct {
+	Key HMACKey
+
+	// Headers signed in addition to method, path, timestamp, nonce and body,
+	// host is taken from request URL.
+	Headers []string
+
+	// Header carrying credentials
+	//
+	// Optional. Default: "Authorization"
+	Header string
+
+	// Transport of signed requests sent by RoundTrip
+	//
+	// Optional. Default: http.DefaultTransport
+	Transport http.RoundTripper
+}
+
+// Sign set HMAC-SHA256 credentials of request, the body is read and restored.
+func (s *HMACSigner) Sign(req *http.Request) (err error) {
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return
+		}
+		req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	nonce, err := RandomHash()
+	if err != nil {
+		return
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	headers := make([]string, len(s.Headers))
+	for i, name := range s.Headers {
+		headers[i] = strings.ToLower(name)
+	}
+	header := func(name string) string {
+		if name == "host" {
+			if req.Host != "" {
+				return req.Host
+			}
+			return req.URL.Host
+		}
+		return req.Header.Get(name)
+	}
+	signature, err := s.Key.Sum(HMACStringToSign(req.Method, req.URL.RequestURI(), headers, header, timestamp, nonce, body))
+	if err != nil {
+		return
+	}
+
+	params := []string{
+		"keyId=" + quoteAuthParam(s.Key.ID),
+		"timestamp=" + quoteAuthParam(timestamp),
+		"nonce=" + quoteAuthParam(nonce),
+	}
+	if len(headers) != 0 {
+		params = append(params, "signedHeaders="+quoteAuthParam(strings.Join(headers, ";")))
+	}
+	params = append(params, "signature="+quoteAuthParam(signature))
+	name := s.Header
+	if name == "" {
+		name = fiber.HeaderAuthorization
+	}
+	req.Header.Set(name, HMACAuth.Name()+" "+strings.Join(params, ", "))
+	return
+}
+
+// RoundTrip sign request, so HMACSigner can be used as Transport of http.Client.
+func (s *HMACSigner) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	if err := s.Sign(req); err != nil {
+		return nil, err
+	}
+	transport := s.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	return transport.RoundTrip(req)
+}
+
+// HMACConfig defines the config for HMACVerifier and RequireHMACSignature middleware.
+type HMACConfig struct {
+	// Next defines a function to skip this middleware when returned true.
+	Next func(c *fiber.Ctx) bool
+
+	Keys HMACKeyStore
+
+	// Header carrying credentials
+	//
+	// Optional. Default: "Authorization"
+	Header string
+
+	// RequiredHeaders must be covered by signature, e.g. host or content-type
+	RequiredHeaders []string
+
+	// MaxSkew reject timestamps further from now, nonces are remembered this long
+	//
+	// Optional. Default: 5 minutes
+	MaxSkew time.Duration
+
+	// Nonces remember used nonces
+	//
+	// Optional. Default: in-memory store of this instance
+	Nonces HMACNonceStore
+}
+
+// HMACVerifier validate signed requests and reject replayed nonces.
+type HMACVerifier struct {
+	cfg HMACConfig
+}
+
+// NewHMACVerifier create HMACVerifier with defaults of unset config.
+func NewHMACVerifier(config HMACConfig) *HMACVerifier {
+	if config.Header == "" {
+		config.Header = fiber.HeaderAuthorization
+	}
+	if config.MaxSkew <= 0 {
+		config.MaxSkew = 5 * time.Minute
+	}
+	if config.Nonces == nil {
+		config.Nonces = memoryNonceStore{replays: newReplayCache()}
+	}
+	return &HMACVerifier{cfg: config}
+}
+
+// Verify check credentials of request and return signing key with ID of keyId credential,
+// failure is fiber error with status 401.
+func (v *HMACVerifier) Verify(ctx context.Context, method, requestURI string, header func(name string) string, body []byte) (key *HMACKey, err error) {
+	if v.cfg.Keys == nil {
+		return nil, fiber.NewError(http.StatusInternalServerError, "hmac keys not configured")
+	}
+	auth, err := ExtractAuthString(header(v.cfg.Header))
+	if err != nil {
+		return
+	}
+	if auth.Type != HMACAuth {
+		return nil, fiber.NewError(http.StatusUnauthorized, "invalid authorize type: "+string(auth.Type))
+	}
+	timestamp, nonce := auth.Param("timestamp"), auth.Param("nonce")
+	if nonce == "" {
+		return nil, fiber.NewError(http.StatusUnauthorized, "missing nonce")
+	}
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return nil, fiber.NewError(http.StatusUnauthorized, "invalid timestamp")
+	}
+	signedAt := time.Unix(unix, 0)
+	if now := time.Now(); signedAt.Before(now.Add(-v.cfg.MaxSkew)) || signedAt.After(now.Add(v.cfg.MaxSkew)) {
+		return nil, fiber.NewError(http.StatusUnauthorized, "stale timestamp")
+	}
+
+	var signedHeaders []string
+	if value := auth.Param("signedHeaders"); value != "" {
+		signedHeaders = strings.Split(strings.ToLower(value), ";")
+	}
+	for _, required := range v.cfg.RequiredHeaders {
+		if !containsString(signedHeaders, strings.ToLower(required)) {
+			return nil, fiber.NewError(http.StatusUnauthorized, "unsigned header: "+required)
+		}
+	}
+
+	key, err = v.cfg.Keys.GetHMACKey(ctx, auth.KeyID)
+	if err != nil && !errors.Is(err, fiber.ErrNotFound) {
+		return nil, err
+	}
+	// key without secret would accept signature anyone can compute
+	if err != nil || key == nil || len(key.Secret) == 0 {
+		return nil, fiber.NewError(http.StatusUnauthorized, "unknown key")
+	}
+	expected, err := key.Sum(HMACStringToSign(method, requestURI, signedHeaders, header, timestamp, nonce, body))
+	if err != nil {
+		return nil, err
+	}
+	if !hmac.Equal([]byte(expected), []byte(auth.Signature)) {
+		return nil, fiber.NewError(http.StatusUnauthorized, "signature mismatch")
+	}
+
+	// nonce is recorded only for valid signature, so forged requests cannot burn it
+	fresh, err := v.cfg.Nonces.UseNonce(ctx, auth.KeyID, nonce, signedAt.Add(v.cfg.MaxSkew))
+	if err != nil {
+		return nil, err
+	}
+	if !fresh {
+		return nil, fiber.NewError(http.StatusUnauthorized, "replayed nonce")
+	}
+	// key of store may not carry its ID
+	if key.ID != auth.KeyID {
+		copied := *key
+		copied.ID = auth.KeyID
+		key = &copied
+	}
+	return key, nil
+}
+
+// RequireHMACSignature creates a middleware that requires request signed by HMACSigner,
+// the key ID is available as Ctx.Principal.
+func RequireHMACSignature(config HMACConfig) fiber.Handler {
+	verifier := NewHMACVerifier(config)
+	return func(c *fiber.Ctx) error {
+		if config.Next != nil && config.Next(c) {
+			return c.Next()
+		}
+		header := func(name string) string {
+			return c.Get(name)
+		}
+		key, err := verifier.Verify(c.UserContext(), c.Method(), c.OriginalURL(), header, c.Body())
+		if err != nil {
+			if verifier.cfg.Header == fiber.HeaderAuthorization {
+				SetAuthChallenge(c, AuthChallenge{Scheme: HMACAuth})
+			}
+			return err
+		}
+		c.Locals(localsPrincipal, &Principal{ID: key.ID, Scheme: HMACAuth})
+		return c.Next()
+	}
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
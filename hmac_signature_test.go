@@ -0,0 +1,142 @@
+package helpers
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestHMACSignature(t *testing.T) {
+	t.Parallel()
+	keys := HMACKeys{
+		"partner-1": {ID: "partner-1", Secret: []byte("secret-1")},
+		"partner-2": {ID: "partner-2", Secret: []byte("secret-2"), Algorithm: HMACSHA3256},
+	}
+
+	app := fiber.New()
+	app.Post("/webhooks", RequireHMACSignature(HMACConfig{Keys: keys, RequiredHeaders: []string{"Content-Type"}}), func(c *fiber.Ctx) error {
+		return c.SendString((&Ctx{c}).Principal().ID + ":" + string(c.Body()))
+	})
+
+	newRequest := func() *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/webhooks?b=2&a=1", strings.NewReader(`{"event":"paid"}`))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+		return req
+	}
+	send := func(req *http.Request) (int, string) {
+		resp, err := app.Test(req)
+		utils.AssertEqual(t, nil, err)
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+
+	// outgoing helper signs request sent by http.Client
+	signer := &HMACSigner{Key: *keys["partner-2"], Headers: []string{"Content-Type", "Host"}, Transport: &fiberTransport{app: app}}
+	resp, err := (&http.Client{Transport: signer}).Post("http://partner.example.com/webhooks?a=1&b=2", fiber.MIMEApplicationJSON, strings.NewReader(`{"event":"paid"}`))
+	utils.AssertEqual(t, nil, err)
+	body, _ := io.ReadAll(resp.Body)
+	utils.AssertEqual(t, http.StatusOK, resp.StatusCode, string(body))
+	utils.AssertEqual(t, `partner-2:{"event":"paid"}`, string(body))
+
+	signer = &HMACSigner{Key: *keys["partner-1"], Headers: []string{"content-type"}}
+	req := newRequest()
+	utils.AssertEqual(t, nil, signer.Sign(req))
+	signed := req.Header.Get(fiber.HeaderAuthorization)
+	utils.AssertEqual(t, true, strings.HasPrefix(signed, `HMAC-SHA256 keyId="partner-1", timestamp="`))
+	status, body2 := send(req)
+	utils.AssertEqual(t, http.StatusOK, status, body2)
+
+	// same nonce is rejected
+	req = newRequest()
+	req.Header.Set(fiber.HeaderAuthorization, signed)
+	status, body2 = send(req)
+	utils.AssertEqual(t, http.StatusUnauthorized, status)
+	utils.AssertEqual(t, "replayed nonce", body2)
+
+	// tampered body
+	req = httptest.NewRequest(http.MethodPost, "/webhooks?b=2&a=1", strings.NewReader(`{"event":"refund"}`))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	req.Header.Set(fiber.HeaderAuthorization, signed)
+	status, body2 = send(req)
+	utils.AssertEqual(t, http.StatusUnauthorized, status)
+	utils.AssertEqual(t, "signature mismatch", body2)
+
+	// signature without required header
+	req = newRequest()
+	utils.AssertEqual(t, nil, (&HMACSigner{Key: *keys["partner-1"]}).Sign(req))
+	status, body2 = send(req)
+	utils.AssertEqual(t, http.StatusUnauthorized, status)
+	utils.AssertEqual(t, "unsigned header: Content-Type", body2)
+
+	// stale timestamp
+	req = newRequest()
+	key := keys["partner-1"]
+	timestamp := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+	signature, err := key.Sum(HMACStringToSign(http.MethodPost, "/webhooks?a=1&b=2", []string{"content-type"}, req.Header.Get, timestamp, "n-1", []byte(`{"event":"paid"}`)))
+	utils.AssertEqual(t, nil, err)
+	req.Header.Set(fiber.HeaderAuthorization, `HMAC-SHA256 keyId="partner-1", timestamp="`+timestamp+`", nonce="n-1", signedHeaders="content-type", signature="`+signature+`"`)
+	status, body2 = send(req)
+	utils.AssertEqual(t, http.StatusUnauthorized, status)
+	utils.AssertEqual(t, "stale timestamp", body2)
+
+	req = newRequest()
+	utils.AssertEqual(t, nil, (&HMACSigner{Key: HMACKey{ID: "unknown", Secret: []byte("x")}, Headers: []string{"content-type"}}).Sign(req))
+	status, _ = send(req)
+	utils.AssertEqual(t, http.StatusUnauthorized, status)
+
+	status, _ = send(newRequest())
+	utils.AssertEqual(t, http.StatusUnauthorized, status)
+}
+
+func TestHMACVerifierNonceStore(t *testing.T) {
+	t.Parallel()
+	// key without ID is identified by keyId of credentials
+	keys := HMACKeys{"partner-1": {Secret: []byte("secret-1")}}
+	nonces := memoryNonceStore{replays: newReplayCache()}
+
+	// instances sharing nonce store reject request replayed to the other
+	instances := make([]*fiber.App, 2)
+	for i := range instances {
+		instances[i] = fiber.New()
+		instances[i].Post("/webhooks", RequireHMACSignature(HMACConfig{Keys: keys, Nonces: nonces}), func(c *fiber.Ctx) error {
+			return c.SendString((&Ctx{c}).Principal().ID)
+		})
+	}
+	req := httptest.NewRequest(http.MethodPost, "/webhooks", strings.NewReader(`{}`))
+	utils.AssertEqual(t, nil, (&HMACSigner{Key: HMACKey{ID: "partner-1", Secret: []byte("secret-1")}}).Sign(req))
+	signed := req.Header.Get(fiber.HeaderAuthorization)
+
+	resp, err := instances[0].Test(req)
+	utils.AssertEqual(t, nil, err)
+	body, _ := io.ReadAll(resp.Body)
+	utils.AssertEqual(t, http.StatusOK, resp.StatusCode, string(body))
+	utils.AssertEqual(t, "partner-1", string(body))
+
+	req = httptest.NewRequest(http.MethodPost, "/webhooks", strings.NewReader(`{}`))
+	req.Header.Set(fiber.HeaderAuthorization, signed)
+	resp, err = instances[1].Test(req)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, http.StatusUnauthorized, resp.StatusCode)
+
+	// missing key store is an error, not a panic
+	_, err = NewHMACVerifier(HMACConfig{}).Verify(context.Background(), http.MethodPost, "/webhooks", req.Header.Get, []byte(`{}`))
+	utils.AssertEqual(t, "hmac keys not configured", err.Error())
+
+	// nil key and key without secret are unknown, not a panic or signature anyone can forge
+	verifier := NewHMACVerifier(HMACConfig{Keys: HMACKeys{"nil-key": nil, "no-secret": {}}})
+	for _, keyID := range []string{"nil-key", "no-secret"} {
+		req = httptest.NewRequest(http.MethodPost, "/webhooks", strings.NewReader(`{}`))
+		utils.AssertEqual(t, nil, (&HMACSigner{Key: HMACKey{ID: keyID}}).Sign(req))
+		_, err = verifier.Verify(context.Background(), http.MethodPost, "/webhooks", req.Header.Get, []byte(`{}`))
+		utils.AssertEqual(t, http.StatusUnauthorized, AsError(err).Code, keyID)
+		utils.AssertEqual(t, "unknown key", AsError(err).Message, keyID)
+	}
+}
@@ -0,0 +1,290 @@
+package helpers
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Supported languages of DefaultBundle
+const (
+	LangEN = "en"
+	LangTH = "th"
+)
+
+// Message is a translatable text with optional plural form.
+//
+// Placeholders are written as {name} and replaced by the data passed to Translate,
+// {count} is filled automatically by TranslatePlural.
+type Message struct {
+	One   string `json:"one,omitempty"`
+	Other string `json:"other"`
+}
+
+// Bundle holds messages by language and message key.
+//
+// HTTP status titles are keyed by their code, e.g. "404".
+type Bundle struct {
+	mu       sync.RWMutex
+	fallback string
+	messages map[string]map[string]Message
+}
+
+// NewBundle create empty bundle that falls back to given language.
+func NewBundle(fallback string) *Bundle {
+	return &Bundle{
+		fallback: normalizeLang(fallback),
+		messages: make(map[string]map[string]Message),
+	}
+}
+
+// DefaultBundle used by Ctx, Error.Localize and ErrorHandler when no bundle is given.
+var DefaultBundle = NewBundle(LangEN)
+
+func init() {
+	DefaultBundle.AddMessages(LangTH, map[string]Message{
+		strconv.Itoa(http.StatusBadRequest):            {Other: "คำขอไม่ถูกต้อง"},
+		strconv.Itoa(http.StatusUnauthorized):          {Other: "กรุณายืนยันตัวตน"},
+		strconv.Itoa(http.StatusForbidden):             {Other: "ไม่มีสิทธิ์เข้าถึง"},
+		strconv.Itoa(http.StatusNotFound):              {Other: "ไม่พบข้อมูล"},
+		strconv.Itoa(http.StatusMethodNotAllowed):      {Other: "ไม่อนุญาตให้ใช้เมธอดนี้"},
+		strconv.Itoa(http.StatusRequestTimeout):        {Other: "หมดเวลารอคำขอ"},
+		strconv.Itoa(http.StatusConflict):              {Other: "ข้อมูลขัดแย้งกัน"},
+		strconv.Itoa(http.StatusRequestEntityTooLarge): {Other: "ข้อมูลมีขนาดใหญ่เกินไป"},
+		strconv.Itoa(http.StatusUnsupportedMediaType):  {Other: "ไม่รองรับประเภทข้อมูลนี้"},
+		strconv.Itoa(http.StatusUnprocessableEntity):   {Other: "ข้อมูลไม่ผ่านการตรวจสอบ"},
+		strconv.Itoa(http.StatusTooManyRequests):       {Other: "มีคำขอมากเกินไป กรุณาลองใหม่ภายหลัง"},
+		strconv.Itoa(http.StatusInternalServerError):   {Other: "เกิดข้อผิดพลาดภายในระบบ"},
+		strconv.Itoa(http.StatusNotImplemented):        {Other: "ยังไม่รองรับการทำงานนี้"},
+		strconv.Itoa(http.StatusBadGateway):            {Other: "เกตเวย์ตอบกลับไม่ถูกต้อง"},
+		strconv.Itoa(http.StatusServiceUnavailable):    {Other: "ระบบไม่พร้อมให้บริการชั่วคราว"},
+		strconv.Itoa(http.StatusGatewayTimeout):        {Other: "เกตเวย์หมดเวลารอ"},
+		"validation.required":                          {Other: "กรุณาระบุ {field}"},
+		"validation.invalid":                           {Other: "{field} ไม่ถูกต้อง"},
+		"validation.min":                               {Other: "{field} ต้องมีอย่างน้อย {min} ตัวอักษร"},
+		"validation.max":                               {Other: "{field} ต้องไม่เกิน {max} ตัวอักษร"},
+	})
+	DefaultBundle.AddMessages(LangEN, map[string]Message{
+		"validation.required": {Other: "{field} is required"},
+		"validation.invalid":  {Other: "{field} is invalid"},
+		"validation.min":      {One: "{field} must be at least {min} character", Other: "{field} must be at least {min} characters"},
+		"validation.max":      {One: "{field} must be at most {max} character", Other: "{field} must be at most {max} characters"},
+	})
+}
+
+// AddMessages add or replace messages of given language.
+func (b *Bundle) AddMessages(lang string, messages map[string]Message) {
+	lang = normalizeLang(lang)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.messages[lang] == nil {
+		b.messages[lang] = make(map[string]Message, len(messages))
+	}
+	for key, msg := range messages {
+		b.messages[lang][key] = msg
+	}
+}
+
+// Fallback return language used when requested one has no message.
+func (b *Bundle) Fallback() string {
+	return b.fallback
+}
+
+// Languages return languages in bundle, fallback first.
+func (b *Bundle) Languages() (langs []string) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	langs = append(langs, b.fallback)
+	for lang := range b.messages {
+		if lang != b.fallback {
+			langs = append(langs, lang)
+		}
+	}
+	sort.Strings(langs[1:])
+	return
+}
+
+// Has report whether key exists in given language or fallback.
+func (b *Bundle) Has(lang, key string) bool {
+	_, ok := b.lookup(lang, key)
+	return ok
+}
+
+// Translate return message of key in given language.
+//
+// Lookup order is lang, base of lang ("th" for "th-TH") then fallback,
+// if not found the key itself is returned.
+func (b *Bundle) Translate(lang, key string, data ...map[string]interface{}) string {
+	msg, ok := b.lookup(lang, key)
+	if !ok {
+		return key
+	}
+	return replacePlaceholders(msg.Other, data...)
+}
+
+// TranslatePlural likes Translate but choose plural form by count.
+func (b *Bundle) TranslatePlural(lang, key string, count int, data ...map[string]interface{}) string {
+	msg, ok := b.lookup(lang, key)
+	if !ok {
+		return key
+	}
+	text := msg.Other
+	if count == 1 && msg.One != "" {
+		text = msg.One
+	}
+	return replacePlaceholders(text, append(data, map[string]interface{}{"count": count})...)
+}
+
+// StatusTitle return localized title of HTTP status code.
+func (b *Bundle) StatusTitle(lang string, code int) string {
+	if msg, ok := b.lookup(lang, strconv.Itoa(code)); ok {
+		return msg.Other
+	}
+	return http.StatusText(code)
+}
+
+func (b *Bundle) lookup(lang, key string) (msg Message, ok bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	lang = normalizeLang(lang)
+	candidates := []string{lang}
+	if i := strings.Index(lang, "-"); i != -1 {
+		candidates = append(candidates, lang[:i])
+	}
+	candidates = append(candidates, b.fallback)
+	for _, candidate := range candidates {
+		if msg, ok = b.messages[candidate][key]; ok {
+			return
+		}
+	}
+	return
+}
+
+func normalizeLang(lang string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(lang), "_", "-"))
+}
+
+func replacePlaceholders(text string, data ...map[string]interface{}) string {
+	if len(data) == 0 {
+		return text
+	}
+	var pairs []string
+	for _, d := range data {
+		for k, v := range d {
+			pairs = append(pairs, "{"+k+"}", fmt.Sprint(v))
+		}
+	}
+	return strings.NewReplacer(pairs...).Replace(text)
+}
+
+// Localize return copy of error with title and message in given language.
+//
+// Message is translated when it is a key in bundle, default status text
+// messages are replaced by the localized title.
+func (e *Error) Localize(lang string, bundle ...*Bundle) (localized *Error) {
+	b := DefaultBundle
+	if len(bundle) != 0 {
+		b = bundle[0]
+	}
+	localized = &Error{
+		Code:    e.Code,
+		Kind:    e.Kind,
+		Source:  e.Source,
+		Title:   b.StatusTitle(lang, e.Code),
+		Message: e.Message,
+	}
+	switch {
+	case e.Message == "" || e.Message == http.StatusText(e.Code):
+		localized.Message = localized.Title
+	case b.Has(lang, e.Message):
+		localized.Message = b.Translate(lang, e.Message)
+	}
+	return
+}
+
+// Language returns the best language for the request from Accept-Language header.
+//
+// If no bundle language is acceptable the bundle fallback is returned.
+func (c *Ctx) Language(bundle ...*Bundle) string {
+	b := DefaultBundle
+	if len(bundle) != 0 {
+		b = bundle[0]
+	}
+	return NegotiateLanguage(c.Get(fiber.HeaderAcceptLanguage), b.Languages(), b.Fallback())
+}
+
+// NegotiateLanguage pick the best of supported languages from Accept-Language value,
+// ordered by q-value. "th-TH" matches supported "th" and vice versa.
+func NegotiateLanguage(acceptLanguage string, supported []string, fallback string) string {
+	type langQ struct {
+		lang string
+		q    float64
+	}
+	var ranges []langQ
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		params := strings.Split(part, ";")
+		lang := normalizeLang(params[0])
+		if lang == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		if q > 0 {
+			ranges = append(ranges, langQ{lang: lang, q: q})
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].q > ranges[j].q })
+
+	for _, r := range ranges {
+		if r.lang == "*" {
+			return fallback
+		}
+		base := r.lang
+		if i := strings.Index(base, "-"); i != -1 {
+			base = base[:i]
+		}
+		for _, lang := range supported {
+			if lang == r.lang || lang == base || strings.HasPrefix(lang, r.lang+"-") {
+				return lang
+			}
+		}
+	}
+	return fallback
+}
+
+// T returns message of key translated to the request language.
+func (c *Ctx) T(key string, data ...map[string]interface{}) string {
+	return DefaultBundle.Translate(c.Language(), key, data...)
+}
+
+// NewError likes NewError but title and message are in the request language.
+func (c *Ctx) NewError(code int, message ...string) *Error {
+	err := NewErrorSource(code, WhereAmI(2), message...)
+	return err.Localize(c.Language())
+}
+
+// ValidationError returns 400 error of field with message key translated to the request language.
+//
+// The field name is available to message as {field}.
+func (c *Ctx) ValidationError(field, key string, data ...map[string]interface{}) *Error {
+	data = append(data, map[string]interface{}{"field": field})
+	lang := c.Language()
+	return &Error{
+		Code:    http.StatusBadRequest,
+		Source:  field,
+		Title:   DefaultBundle.StatusTitle(lang, http.StatusBadRequest),
+		Message: DefaultBundle.Translate(lang, key, data...),
+	}
+}
@@ -0,0 +1,59 @@
+package helpers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/segmentio/encoding/json"
+)
+
+func TestNegotiateLanguage(t *testing.T) {
+	t.Parallel()
+	supported := []string{LangEN, LangTH}
+
+	utils.AssertEqual(t, LangTH, NegotiateLanguage("th-TH,th;q=0.9,en;q=0.8", supported, LangEN))
+	utils.AssertEqual(t, LangTH, NegotiateLanguage("en;q=0.5, th", supported, LangEN))
+	utils.AssertEqual(t, LangEN, NegotiateLanguage("ja, *;q=0.1", supported, LangEN))
+	utils.AssertEqual(t, LangEN, NegotiateLanguage("", supported, LangEN))
+}
+
+func TestBundleTranslate(t *testing.T) {
+	t.Parallel()
+	bundle := NewBundle(LangEN)
+	bundle.AddMessages(LangEN, map[string]Message{
+		"cart.items": {One: "{count} item in {name}", Other: "{count} items in {name}"},
+	})
+	bundle.AddMessages(LangTH, map[string]Message{
+		"cart.items": {Other: "{count} รายการใน {name}"},
+	})
+
+	data := map[string]interface{}{"name": "cart"}
+	utils.AssertEqual(t, "1 item in cart", bundle.TranslatePlural(LangEN, "cart.items", 1, data))
+	utils.AssertEqual(t, "2 items in cart", bundle.TranslatePlural("en-US", "cart.items", 2, data))
+	utils.AssertEqual(t, "1 รายการใน cart", bundle.TranslatePlural("th-TH", "cart.items", 1, data))
+	utils.AssertEqual(t, "2 items in cart", bundle.TranslatePlural("ja", "cart.items", 2, data))
+	utils.AssertEqual(t, "unknown.key", bundle.Translate(LangTH, "unknown.key"))
+}
+
+func TestErrorHandlerLocalized(t *testing.T) {
+	t.Parallel()
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Get("/test", func(c *fiber.Ctx) error {
+		return NewError(http.StatusNotFound)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	req.Header.Set(fiber.HeaderAcceptLanguage, "th-TH,en;q=0.5")
+	resp, err := app.Test(req)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, http.StatusNotFound, resp.StatusCode)
+
+	var body ResponseForm
+	utils.AssertEqual(t, nil, json.NewDecoder(resp.Body).Decode(&body))
+	utils.AssertEqual(t, 1, len(body.Errors))
+	utils.AssertEqual(t, "ไม่พบข้อมูล", body.Errors[0].Title)
+	utils.AssertEqual(t, "ไม่พบข้อมูล", body.Errors[0].Message)
+}
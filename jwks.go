@@ -0,0 +1,229 @@
+package helpers
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// JWK JSON Web Key (RFC 7517), public parameters only
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// EC and OKP
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JWKSet JSON Web Key Set
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// Key return key of kid, nil if not found.
+func (s JWKSet) Key(kid string) *JWK {
+	for i := range s.Keys {
+		if s.Keys[i].Kid == kid {
+			return &s.Keys[i]
+		}
+	}
+	return nil
+}
+
+// NewJWK create JWK of public key.
+func NewJWK(publicKey interface{}) (jwk JWK, err error) {
+	switch key := publicKey.(type) {
+	case *rsa.PublicKey:
+		jwk = JWK{
+			Kty: "RSA",
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}
+	case *ecdsa.PublicKey:
+		if key.Curve != elliptic.P256() {
+			err = fmt.Errorf("unsupported curve: %s", key.Curve.Params().Name)
+			return
+		}
+		jwk = JWK{
+			Kty: "EC",
+			Crv: "P-256",
+			X:   base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, 32))),
+			Y:   base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, 32))),
+		}
+	case ed25519.PublicKey:
+		jwk = JWK{
+			Kty: "OKP",
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(key),
+		}
+	default:
+		err = fmt.Errorf("unsupported public key type: %T", publicKey)
+	}
+	return
+}
+
+// Thumbprint return base64url SHA-256 thumbprint of required members (RFC 7638).
+func (j JWK) Thumbprint() (string, error) {
+	var members string
+	switch j.Kty {
+	case "RSA":
+		members = fmt.Sprintf(`{"e":%q,"kty":"RSA","n":%q}`, j.E, j.N)
+	case "EC":
+		members = fmt.Sprintf(`{"crv":%q,"kty":"EC","x":%q,"y":%q}`, j.Crv, j.X, j.Y)
+	case "OKP":
+		members = fmt.Sprintf(`{"crv":%q,"kty":"OKP","x":%q}`, j.Crv, j.X)
+	default:
+		return "", fmt.Errorf("unsupported key type: %s", j.Kty)
+	}
+	sum := sha256.Sum256([]byte(members))
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+// PublicKey decode JWK to *rsa.PublicKey, *ecdsa.PublicKey or ed25519.PublicKey.
+func (j JWK) PublicKey() (publicKey interface{}, err error) {
+	switch j.Kty {
+	case "RSA":
+		n, nErr := base64.RawURLEncoding.DecodeString(j.N)
+		e, eErr := base64.RawURLEncoding.DecodeString(j.E)
+		if nErr != nil || eErr != nil || len(n) == 0 || len(e) == 0 {
+			return nil, fmt.Errorf("invalid RSA JWK")
+		}
+		publicKey = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	case "EC":
+		if j.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported curve: %s", j.Crv)
+		}
+		x, xErr := base64.RawURLEncoding.DecodeString(j.X)
+		y, yErr := base64.RawURLEncoding.DecodeString(j.Y)
+		if xErr != nil || yErr != nil {
+			return nil, fmt.Errorf("invalid EC JWK")
+		}
+		key := &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}
+		if !key.Curve.IsOnCurve(key.X, key.Y) {
+			return nil, fmt.Errorf("invalid EC JWK: point not on curve")
+		}
+		publicKey = key
+	case "OKP":
+		x, xErr := base64.RawURLEncoding.DecodeString(j.X)
+		if j.Crv != "Ed25519" || xErr != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid OKP JWK")
+		}
+		publicKey = ed25519.PublicKey(x)
+	default:
+		err = fmt.Errorf("unsupported key type: %s", j.Kty)
+	}
+	return
+}
+
+// JWTKeySet signing keys by kid, the newest key signs and older keys still verify.
+type JWTKeySet struct {
+	mu   sync.RWMutex
+	keys []*JWTKey
+}
+
+// NewJWTKeySet create key set, the first key is used for signing.
+func NewJWTKeySet(keys ...*JWTKey) *JWTKeySet {
+	return &JWTKeySet{keys: keys}
+}
+
+// Rotate make key the signing key, previous keys are kept for verification
+// until removed.
+func (ks *JWTKeySet) Rotate(key *JWTKey) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	ks.keys = append([]*JWTKey{key}, ks.keys...)
+}
+
+// Remove drop key of kid, tokens signed by it no longer verify.
+func (ks *JWTKeySet) Remove(kid string) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	for i, key := range ks.keys {
+		if key.ID == kid {
+			ks.keys = append(ks.keys[:i:i], ks.keys[i+1:]...)
+			return
+		}
+	}
+}
+
+// SigningKey return current signing key, nil when set is empty.
+func (ks *JWTKeySet) SigningKey() *JWTKey {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	if len(ks.keys) == 0 {
+		return nil
+	}
+	return ks.keys[0]
+}
+
+// Key return key of kid, nil if not found.
+func (ks *JWTKeySet) Key(kid string) *JWTKey {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	for _, key := range ks.keys {
+		if key.ID == kid {
+			return key
+		}
+	}
+	return nil
+}
+
+// Sign encode claims with signing key.
+func (ks *JWTKeySet) Sign(claims interface{}, typ ...string) (string, error) {
+	key := ks.SigningKey()
+	if key == nil {
+		return "", fmt.Errorf("empty JWT key set")
+	}
+	return key.Sign(claims, typ...)
+}
+
+// Verify check signature by key of token kid and decode claims.
+func (ks *JWTKeySet) Verify(token string, claims interface{}) (header JWTHeader, err error) {
+	return VerifyJWT(token, claims, func(header JWTHeader) (*JWTKey, error) {
+		if key := ks.Key(header.Kid); key != nil {
+			return key, nil
+		}
+		return nil, fmt.Errorf("unknown kid: %s", header.Kid)
+	})
+}
+
+// JWKS return public keys of set, symmetric keys are not published.
+func (ks *JWTKeySet) JWKS() (set JWKSet) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	set.Keys = []JWK{}
+	for _, key := range ks.keys {
+		if jwk, err := key.JWK(); err == nil {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+	return
+}
+
+// JWKSHandler serve key set as /.well-known/jwks.json
+func (ks *JWTKeySet) JWKSHandler(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+	return c.Status(http.StatusOK).JSON(ks.JWKS())
+}
@@ -0,0 +1,514 @@
+package helpers
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/segmentio/encoding/json"
+)
+
+// JWTAlgorithm JWS signing algorithm (RFC 7518)
+type JWTAlgorithm string
+
+// JWTAlgorithm constant
+const (
+	RS256 JWTAlgorithm = "RS256"
+	ES256 JWTAlgorithm = "ES256"
+	EdDSA JWTAlgorithm = "EdDSA"
+	HS256 JWTAlgorithm = "HS256"
+)
+
+// JWT type header values
+const (
+	JWTTypeJWT         = "JWT"
+	JWTTypeAccessToken = "at+jwt"
+)
+
+// JWTHeader JOSE header
+type JWTHeader struct {
+	Alg JWTAlgorithm `json:"alg"`
+	Kid string       `json:"kid,omitempty"`
+	Typ string       `json:"typ,omitempty"`
+	JWK *JWK         `json:"jwk,omitempty"`
+}
+
+// JWTKey signing or verification key identified by kid.
+type JWTKey struct {
+	ID        string
+	Algorithm JWTAlgorithm
+	// *rsa.PrivateKey, *ecdsa.PrivateKey, ed25519.PrivateKey, []byte or nil for verify only key
+	signer interface{}
+	// *rsa.PublicKey, *ecdsa.PublicKey, ed25519.PublicKey or []byte
+	verifier interface{}
+}
+
+// NewJWTKey create key from private key, public key (verify only) or HS256 secret.
+func NewJWTKey(kid string, alg JWTAlgorithm, key interface{}) (jwtKey *JWTKey, err error) {
+	jwtKey = &JWTKey{ID: kid, Algorithm: alg}
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		jwtKey.signer, jwtKey.verifier = k, &k.PublicKey
+	case *rsa.PublicKey:
+		jwtKey.verifier = k
+	case *ecdsa.PrivateKey:
+		jwtKey.signer, jwtKey.verifier = k, &k.PublicKey
+	case *ecdsa.PublicKey:
+		jwtKey.verifier = k
+	case ed25519.PrivateKey:
+		jwtKey.signer, jwtKey.verifier = k, k.Public()
+	case ed25519.PublicKey:
+		jwtKey.verifier = k
+	case []byte:
+		jwtKey.signer, jwtKey.verifier = k, k
+	default:
+		return nil, fmt.Errorf("unsupported key type: %T", key)
+	}
+
+	var ok bool
+	switch alg {
+	case RS256:
+		_, ok = jwtKey.verifier.(*rsa.PublicKey)
+	case ES256:
+		var ecKey *ecdsa.PublicKey
+		if ecKey, ok = jwtKey.verifier.(*ecdsa.PublicKey); ok {
+			ok = ecKey.Curve == elliptic.P256()
+		}
+	case EdDSA:
+		_, ok = jwtKey.verifier.(ed25519.PublicKey)
+	case HS256:
+		var secret []byte
+		if secret, ok = jwtKey.verifier.([]byte); ok {
+			ok = len(secret) >= 32
+		}
+	}
+	if !ok {
+		return nil, fmt.Errorf("key type %T does not match algorithm %s", key, alg)
+	}
+	return
+}
+
+// NewJWTKeyFromJWK create verify only key from JWK.
+func NewJWTKeyFromJWK(jwk JWK) (*JWTKey, error) {
+	publicKey, err := jwk.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+	alg := JWTAlgorithm(jwk.Alg)
+	if alg == "" {
+		switch publicKey.(type) {
+		case *rsa.PublicKey:
+			alg = RS256
+		case *ecdsa.PublicKey:
+			alg = ES256
+		case ed25519.PublicKey:
+			alg = EdDSA
+		}
+	}
+	return NewJWTKey(jwk.Kid, alg, publicKey)
+}
+
+// GenerateJWTKey create new random key with random kid.
+func GenerateJWTKey(alg JWTAlgorithm) (*JWTKey, error) {
+	kid, err := RandomKey()
+	if err != nil {
+		return nil, err
+	}
+	var key interface{}
+	switch alg {
+	case RS256:
+		key, err = rsa.GenerateKey(rand.Reader, 2048)
+	case ES256:
+		key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case EdDSA:
+		_, key, err = ed25519.GenerateKey(rand.Reader)
+	case HS256:
+		secret := make([]byte, 32)
+		_, err = rand.Read(secret)
+		key = secret
+	default:
+		err = fmt.Errorf("unsupported algorithm: %s", alg)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return NewJWTKey(kid, alg, key)
+}
+
+// PublicKey return verification key, the secret for HS256.
+func (k *JWTKey) PublicKey() interface{} {
+	return k.verifier
+}
+
+// JWK return public JWK of key, HS256 keys have none.
+func (k *JWTKey) JWK() (jwk JWK, err error) {
+	if k.Algorithm == HS256 {
+		err = fmt.Errorf("symmetric key has no public JWK")
+		return
+	}
+	if jwk, err = NewJWK(k.verifier); err != nil {
+		return
+	}
+	jwk.Kid = k.ID
+	jwk.Alg = string(k.Algorithm)
+	jwk.Use = "sig"
+	return
+}
+
+// Sign encode claims as compact JWS, typ default "JWT".
+func (k *JWTKey) Sign(claims interface{}, typ ...string) (token string, err error) {
+	header := JWTHeader{Alg: k.Algorithm, Kid: k.ID, Typ: JWTTypeJWT}
+	if len(typ) != 0 {
+		header.Typ = typ[0]
+	}
+	return k.SignWithHeader(header, claims)
+}
+
+// SignWithHeader encode claims with custom header, alg is always set from key.
+func (k *JWTKey) SignWithHeader(header JWTHeader, claims interface{}) (token string, err error) {
+	if k.signer == nil {
+		return "", fmt.Errorf("key %s is verify only", k.ID)
+	}
+	header.Alg = k.Algorithm
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return
+	}
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(headerJSON) + "." + base64.RawURLEncoding.EncodeToString(claimsJSON)
+	signature, err := k.sign([]byte(signingInput))
+	if err != nil {
+		return
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+func (k *JWTKey) sign(input []byte) ([]byte, error) {
+	digest := sha256.Sum256(input)
+	switch key := k.signer.(type) {
+	case *rsa.PrivateKey:
+		return rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+		if err != nil {
+			return nil, err
+		}
+		signature := make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+		return signature, nil
+	case ed25519.PrivateKey:
+		return ed25519.Sign(key, input), nil
+	case []byte:
+		mac := hmac.New(sha256.New, key)
+		mac.Write(input)
+		return mac.Sum(nil), nil
+	}
+	return nil, fmt.Errorf("unsupported signing key: %T", k.signer)
+}
+
+func (k *JWTKey) verify(input, signature []byte) bool {
+	digest := sha256.Sum256(input)
+	switch key := k.verifier.(type) {
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) == nil
+	case *ecdsa.PublicKey:
+		if len(signature) != 64 {
+			return false
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		return ecdsa.Verify(key, digest[:], r, s)
+	case ed25519.PublicKey:
+		return ed25519.Verify(key, input, signature)
+	case []byte:
+		mac := hmac.New(sha256.New, key)
+		mac.Write(input)
+		return hmac.Equal(mac.Sum(nil), signature)
+	}
+	return false
+}
+
+// ParseJWT decode header and claims without verifying signature.
+func ParseJWT(token string, claims interface{}) (header JWTHeader, err error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		err = fiber.NewError(http.StatusUnauthorized, "malformed JWT")
+		return
+	}
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		err = fiber.NewError(http.StatusUnauthorized, "malformed JWT header")
+		return
+	}
+	if err = json.Unmarshal(headerJSON, &header); err != nil {
+		err = fiber.NewError(http.StatusUnauthorized, "malformed JWT header")
+		return
+	}
+	claimsJSON, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		err = fiber.NewError(http.StatusUnauthorized, "malformed JWT claims")
+		return
+	}
+	if err = json.Unmarshal(claimsJSON, claims); err != nil {
+		err = fiber.NewError(http.StatusUnauthorized, "malformed JWT claims")
+	}
+	return
+}
+
+// VerifyJWT check signature with key chosen by keyFunc and decode claims.
+//
+// The key algorithm must match the header alg, "none" is never accepted.
+func VerifyJWT(token string, claims interface{}, keyFunc func(header JWTHeader) (*JWTKey, error)) (header JWTHeader, err error) {
+	if header, err = ParseJWT(token, claims); err != nil {
+		return
+	}
+	key, err := keyFunc(header)
+	if err != nil {
+		err = fiber.NewError(http.StatusUnauthorized, err.Error())
+		return
+	}
+	if key.Algorithm != header.Alg {
+		err = fiber.NewError(http.StatusUnauthorized, fmt.Sprintf("unexpected JWT alg: %s", header.Alg))
+		return
+	}
+	i := strings.LastIndex(token, ".")
+	signature, err := base64.RawURLEncoding.DecodeString(token[i+1:])
+	if err != nil || !key.verify([]byte(token[:i]), signature) {
+		err = fiber.NewError(http.StatusUnauthorized, "invalid JWT signature")
+	}
+	return
+}
+
+// JWTAudience aud claim, encoded as string when single value.
+type JWTAudience []string
+
+func (a JWTAudience) MarshalJSON() ([]byte, error) {
+	if len(a) == 1 {
+		return json.Marshal(a[0])
+	}
+	return json.Marshal([]string(a))
+}
+
+func (a *JWTAudience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = JWTAudience{single}
+		return nil
+	}
+	var multi []string
+	if err := json.Unmarshal(data, &multi); err != nil {
+		return err
+	}
+	*a = multi
+	return nil
+}
+
+// Contains report whether audience includes aud.
+func (a JWTAudience) Contains(aud string) bool {
+	for _, v := range a {
+		if v == aud {
+			return true
+		}
+	}
+	return false
+}
+
+// JWTClaims registered claims and JWT access token claims (RFC 9068)
+type JWTClaims struct {
+	Issuer    string      `json:"iss,omitempty"`
+	Subject   string      `json:"sub,omitempty"`
+	Audience  JWTAudience `json:"aud,omitempty"`
+	ExpiresAt int64       `json:"exp,omitempty"`
+	NotBefore int64       `json:"nbf,omitempty"`
+	IssuedAt  int64       `json:"iat,omitempty"`
+	ID        string      `json:"jti,omitempty"`
+	ClientID  string      `json:"client_id,omitempty"`
+	Scope     string      `json:"scope,omitempty"`
+	Act       *JWTActor   `json:"act,omitempty"`
+	// Cnf key the token is bound to (RFC 7800)
+	Cnf *JWTConfirmation `json:"cnf,omitempty"`
+}
+
+// JWTActor act claim, nested act are prior actors in delegation chain (RFC 8693 section 4.1)
+type JWTActor struct {
+	Subject  string    `json:"sub"`
+	Issuer   string    `json:"iss,omitempty"`
+	ClientID string    `json:"client_id,omitempty"`
+	Act      *JWTActor `json:"act,omitempty"`
+}
+
+// Validate check iss, aud, exp and nbf, empty issuer or audience is not checked.
+func (c *JWTClaims) Validate(issuer, audience string, leeway time.Duration) error {
+	now := time.Now()
+	switch {
+	case issuer != "" && c.Issuer != issuer:
+		return fiber.NewError(http.StatusUnauthorized, "unexpected JWT issuer")
+	case audience != "" && !c.Audience.Contains(audience):
+		return fiber.NewError(http.StatusUnauthorized, "unexpected JWT audience")
+	case c.ExpiresAt != 0 && now.After(time.Unix(c.ExpiresAt, 0).Add(leeway)):
+		return fiber.NewError(http.StatusUnauthorized, "JWT expired")
+	case c.NotBefore != 0 && now.Add(leeway).Before(time.Unix(c.NotBefore, 0)):
+		return fiber.NewError(http.StatusUnauthorized, "JWT not valid yet")
+	}
+	return nil
+}
+
+// Info return claims as validated token info.
+func (c *JWTClaims) Info() *OauthTokenInfo {
+	tokenType := "Bearer"
+	if c.Cnf != nil && c.Cnf.JKT != "" {
+		tokenType = "DPoP"
+	}
+	return &OauthTokenInfo{
+		Active:    true,
+		Scope:     c.Scope,
+		ClientID:  c.ClientID,
+		TokenType: tokenType,
+		Exp:       c.ExpiresAt,
+		Iat:       c.IssuedAt,
+		Nbf:       c.NotBefore,
+		Sub:       c.Subject,
+		Aud:       strings.Join(c.Audience, " "),
+		Iss:       c.Issuer,
+		Jti:       c.ID,
+		Act:       c.Act,
+		Cnf:       c.Cnf,
+	}
+}
+
+// JWTAccessToken return OauthServerConfig.GenerateAccessToken issuing JWT access tokens (RFC 9068).
+func JWTAccessToken(keys *JWTKeySet, issuer string, audience ...string) func(ctx context.Context, token *OauthToken) (string, error) {
+	return func(ctx context.Context, token *OauthToken) (string, error) {
+		jti, err := UUIDv4()
+		if err != nil {
+			return "", err
+		}
+		subject := token.UserID
+		if subject == "" {
+			subject = token.ClientID
+		}
+		aud := audience
+		if len(token.Audience) > 0 {
+			aud = token.Audience
+		}
+		return keys.Sign(JWTClaims{
+			Issuer:    issuer,
+			Subject:   subject,
+			Audience:  aud,
+			Act:       token.Actor,
+			Cnf:       token.Confirmation(),
+			ExpiresAt: token.ExpiresAt.Unix(),
+			IssuedAt:  token.IssuedAt.Unix(),
+			ID:        jti,
+			ClientID:  token.ClientID,
+			Scope:     token.Scope,
+		}, JWTTypeAccessToken)
+	}
+}
+
+// JWTConfig defines the config for JWTAuth middleware.
+type JWTConfig struct {
+	// Next defines a function to skip this middleware when returned true.
+	Next func(c *fiber.Ctx) bool
+
+	Keys *JWTKeySet
+
+	// Expected iss and aud, not checked when empty.
+	Issuer   string
+	Audience string
+
+	// Scopes required, all must be granted.
+	Scopes []string
+
+	// Leeway for exp and nbf clock skew
+	Leeway time.Duration
+
+	// Realm of WWW-Authenticate challenge
+	Realm string
+
+	// DPoP validate proofs of DPoP bound tokens
+	//
+	// Optional. Default: NewDPoPVerifier()
+	DPoP *DPoPVerifier
+}
+
+const localsJWTClaims = "helpers.jwt_claims"
+
+// JWTAuth creates a middleware that requires valid JWT bearer or DPoP access token,
+// claims are available by Ctx.JWTClaims and Ctx.TokenInfo.
+func JWTAuth(config JWTConfig) fiber.Handler {
+	if config.DPoP == nil {
+		config.DPoP = NewDPoPVerifier()
+	}
+	return func(c *fiber.Ctx) error {
+		if config.Next != nil && config.Next(c) {
+			return c.Next()
+		}
+
+		auth, err := ExtractAuthString(c.Get(fiber.HeaderAuthorization))
+		if err != nil || (auth.Type != BearerToken && auth.Type != DPoPToken) {
+			return resourceError(c, config.Realm, "", config.DPoP, nil)
+		}
+
+		claims := new(JWTClaims)
+		header, err := config.Keys.Verify(auth.Token, claims)
+		if err == nil {
+			err = validateAccessTokenJWT(header, claims)
+		}
+		if err == nil {
+			err = claims.Validate(config.Issuer, config.Audience, config.Leeway)
+		}
+		if err != nil {
+			return resourceError(c, config.Realm, auth.Type, config.DPoP, NewOauthError(InvalidToken, err.Error()))
+		}
+		if oauthErr := verifyDPoPAccess(c, config.DPoP, auth, claims.Cnf); oauthErr != nil {
+			return resourceError(c, config.Realm, auth.Type, config.DPoP, oauthErr)
+		}
+
+		if !ParseScope(claims.Scope).HasAll(config.Scopes...) {
+			return resourceError(c, config.Realm, auth.Type, config.DPoP, NewOauthError(InsufficientScope, "insufficient scope"), config.Scopes...)
+		}
+
+		c.Locals(localsJWTClaims, claims)
+		c.Locals(localsOauthTokenInfo, claims.Info())
+		return c.Next()
+	}
+}
+
+// validateAccessTokenJWT check typ, iss and exp required of JWT access token (RFC 9068 section 4),
+// so ID tokens or other JWTs signed by the same keys are not accepted.
+func validateAccessTokenJWT(header JWTHeader, claims *JWTClaims) error {
+	typ := strings.ToLower(header.Typ)
+	switch {
+	case typ != JWTTypeAccessToken && typ != "application/"+JWTTypeAccessToken:
+		return fmt.Errorf("unexpected typ: %s", header.Typ)
+	case claims.Issuer == "":
+		return fmt.Errorf("missing iss")
+	case claims.ExpiresAt == 0:
+		return fmt.Errorf("missing exp")
+	}
+	return nil
+}
+
+// JWTClaims returns claims verified by JWTAuth middleware, nil when not present.
+func (c *Ctx) JWTClaims() *JWTClaims {
+	claims, _ := c.Locals(localsJWTClaims).(*JWTClaims)
+	return claims
+}
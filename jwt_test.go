@@ -0,0 +1,133 @@
+package helpers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestJWTSignVerify(t *testing.T) {
+	t.Parallel()
+
+	for _, alg := range []JWTAlgorithm{RS256, ES256, EdDSA, HS256} {
+		key, err := GenerateJWTKey(alg)
+		utils.AssertEqual(t, nil, err)
+		keys := NewJWTKeySet(key)
+
+		token, err := keys.Sign(JWTClaims{Subject: "user-1", Scope: "orders"}, JWTTypeAccessToken)
+		utils.AssertEqual(t, nil, err)
+
+		var claims JWTClaims
+		header, err := keys.Verify(token, &claims)
+		utils.AssertEqual(t, nil, err, string(alg))
+		utils.AssertEqual(t, JWTTypeAccessToken, header.Typ)
+		utils.AssertEqual(t, "user-1", claims.Subject)
+
+		// tampered signature
+		i := strings.LastIndex(token, ".") + 1
+		tampered := []byte(token)
+		tampered[i] ^= 'A' ^ 'B'
+		_, err = keys.Verify(string(tampered), &claims)
+		utils.AssertEqual(t, true, err != nil, string(alg))
+	}
+}
+
+func TestJWTKeyRotation(t *testing.T) {
+	t.Parallel()
+
+	oldKey, err := GenerateJWTKey(ES256)
+	utils.AssertEqual(t, nil, err)
+	keys := NewJWTKeySet(oldKey)
+	oldToken, err := keys.Sign(JWTClaims{Subject: "user-1"})
+	utils.AssertEqual(t, nil, err)
+
+	newKey, err := GenerateJWTKey(EdDSA)
+	utils.AssertEqual(t, nil, err)
+	keys.Rotate(newKey)
+	utils.AssertEqual(t, newKey.ID, keys.SigningKey().ID)
+	utils.AssertEqual(t, 2, len(keys.JWKS().Keys))
+
+	var claims JWTClaims
+	_, err = keys.Verify(oldToken, &claims)
+	utils.AssertEqual(t, nil, err)
+
+	// verify with key published in JWKS
+	jwk := keys.JWKS().Key(oldKey.ID)
+	utils.AssertEqual(t, true, jwk != nil)
+	publicKey, err := NewJWTKeyFromJWK(*jwk)
+	utils.AssertEqual(t, nil, err)
+	_, err = NewJWTKeySet(publicKey).Verify(oldToken, &claims)
+	utils.AssertEqual(t, nil, err)
+
+	keys.Remove(oldKey.ID)
+	_, err = keys.Verify(oldToken, &claims)
+	utils.AssertEqual(t, true, err != nil)
+}
+
+func TestJWTAuth(t *testing.T) {
+	t.Parallel()
+
+	key, err := GenerateJWTKey(RS256)
+	utils.AssertEqual(t, nil, err)
+	keys := NewJWTKeySet(key)
+
+	app := fiber.New()
+	app.Get("/orders", JWTAuth(JWTConfig{
+		Keys:     keys,
+		Issuer:   "https://auth.example.com",
+		Audience: "orders-api",
+		Scopes:   []string{"orders:read"},
+	}), func(c *fiber.Ctx) error {
+		cc := Ctx{c}
+		return c.SendString(cc.JWTClaims().Subject)
+	})
+
+	call := func(claims JWTClaims) int {
+		token, err := keys.Sign(claims, JWTTypeAccessToken)
+		utils.AssertEqual(t, nil, err)
+		req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+		req.Header.Set(fiber.HeaderAuthorization, "Bearer "+token)
+		resp, err := app.Test(req)
+		utils.AssertEqual(t, nil, err)
+		return resp.StatusCode
+	}
+
+	valid := JWTClaims{
+		Issuer:    "https://auth.example.com",
+		Subject:   "user-1",
+		Audience:  JWTAudience{"orders-api"},
+		ExpiresAt: time.Now().Add(time.Minute).Unix(),
+		Scope:     "orders:read orders:write",
+	}
+	utils.AssertEqual(t, http.StatusOK, call(valid))
+
+	expired := valid
+	expired.ExpiresAt = time.Now().Add(-time.Minute).Unix()
+	utils.AssertEqual(t, http.StatusUnauthorized, call(expired))
+
+	otherAudience := valid
+	otherAudience.Audience = JWTAudience{"billing-api"}
+	utils.AssertEqual(t, http.StatusUnauthorized, call(otherAudience))
+
+	noScope := valid
+	noScope.Scope = "profile"
+	utils.AssertEqual(t, http.StatusForbidden, call(noScope))
+
+	noExpiry := valid
+	noExpiry.ExpiresAt = 0
+	utils.AssertEqual(t, http.StatusUnauthorized, call(noExpiry))
+
+	// ID token signed by the same keys is not an access token
+	idToken, err := keys.Sign(valid)
+	utils.AssertEqual(t, nil, err)
+	req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+	req.Header.Set(fiber.HeaderAuthorization, "Bearer "+idToken)
+	resp, err := app.Test(req)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, http.StatusUnauthorized, resp.StatusCode)
+}
@@ -25,6 +25,10 @@ type AuthType string
 const (
 	BasicAuth   AuthType = "basic"
 	BearerToken AuthType = "bearer"
+	DPoPToken   AuthType = "dpop"
+	APIKeyAuth  AuthType = "apikey"
+	DigestAuth  AuthType = "digest"
+	HMACAuth    AuthType = "hmac-sha256"
 )
 
 type HttpAuth struct {
@@ -32,4 +36,9 @@ type HttpAuth struct {
 	Token    string   `json:"token,omitempty"`
 	Username string   `json:"username,omitempty"`
 	Password string   `json:"password,omitempty"`
+	// KeyID and Signature of HMAC-SHA256 credentials
+	KeyID     string `json:"key_id,omitempty"`
+	Signature string `json:"signature,omitempty"`
+	// Params auth-params of credentials, names are lower case
+	Params map[string]string `json:"params,omitempty"`
 }
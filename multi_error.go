@@ -0,0 +1,144 @@
+package helpers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ItemError is an error of one item in batch operation.
+type ItemError struct {
+	Index int    `json:"index"`
+	Key   string `json:"key,omitempty"`
+	Err   *Error `json:"error"`
+}
+
+// Source identifies the item, key when set otherwise index.
+func (e ItemError) Source() string {
+	if e.Key != "" {
+		return e.Key
+	}
+	return strconv.Itoa(e.Index)
+}
+
+// MultiError collects errors of batch operation items.
+type MultiError struct {
+	// Total number of items in batch, used to tell partial from full failure.
+	Total int
+	Items []ItemError
+}
+
+// NewMultiError create MultiError for batch of total items.
+func NewMultiError(total int) *MultiError {
+	return &MultiError{Total: total}
+}
+
+// Add record error of item at index, nil err is ignored.
+func (m *MultiError) Add(index int, err error) {
+	if err == nil {
+		return
+	}
+	m.Items = append(m.Items, ItemError{Index: index, Err: AsError(err)})
+}
+
+// AddKey record error of item identified by key, nil err is ignored.
+func (m *MultiError) AddKey(index int, key string, err error) {
+	if err == nil {
+		return
+	}
+	m.Items = append(m.Items, ItemError{Index: index, Key: key, Err: AsError(err)})
+}
+
+// Len return number of failed items.
+func (m *MultiError) Len() int {
+	return len(m.Items)
+}
+
+// ErrorOrNil return nil when no item failed.
+func (m *MultiError) ErrorOrNil() error {
+	if m == nil || len(m.Items) == 0 {
+		return nil
+	}
+	return m
+}
+
+func (m *MultiError) Error() string {
+	msgs := make([]string, len(m.Items))
+	for i, item := range m.Items {
+		msgs[i] = fmt.Sprintf("item %s: %s", item.Source(), item.Err.Message)
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Is report whether any item error matches target, errors.Is follows Unwrap() []error only since Go 1.20.
+func (m *MultiError) Is(target error) bool {
+	for _, item := range m.Items {
+		if errors.Is(item.Err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As find first item error matching target, errors.As follows Unwrap() []error only since Go 1.20.
+func (m *MultiError) As(target interface{}) bool {
+	for _, item := range m.Items {
+		if errors.As(item.Err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// Unwrap return item errors for errors.Is and errors.As.
+func (m *MultiError) Unwrap() []error {
+	errs := make([]error, len(m.Items))
+	for i, item := range m.Items {
+		errs[i] = item.Err
+	}
+	return errs
+}
+
+// StatusCode choose overall HTTP status of batch.
+//
+// 200 when nothing failed, 207 when some items succeeded,
+// otherwise 500 if any item is server error, the shared code
+// if all items have same code, or 400.
+func (m *MultiError) StatusCode() int {
+	if len(m.Items) == 0 {
+		return http.StatusOK
+	}
+	if m.Total > len(m.Items) {
+		return http.StatusMultiStatus
+	}
+	code := m.Items[0].Err.Code
+	for _, item := range m.Items {
+		if item.Err.Code >= http.StatusInternalServerError {
+			return http.StatusInternalServerError
+		}
+		if item.Err.Code != code {
+			code = http.StatusBadRequest
+		}
+	}
+	return code
+}
+
+// ResponseErrors return item errors with Source set to the item.
+func (m *MultiError) ResponseErrors() (errs []ResponseError) {
+	errs = make([]ResponseError, len(m.Items))
+	for i, item := range m.Items {
+		errs[i] = ResponseError(*item.Err)
+		errs[i].Source = item.Source()
+	}
+	return
+}
+
+// ResponseForm render batch result, Success is true only when nothing failed.
+func (m *MultiError) ResponseForm() ResponseForm {
+	return ResponseForm{
+		Success: len(m.Items) == 0,
+		Errors:  m.ResponseErrors(),
+	}
+}
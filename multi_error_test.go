@@ -0,0 +1,44 @@
+package helpers
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestMultiError(t *testing.T) {
+	t.Parallel()
+
+	multiErr := NewMultiError(10)
+	utils.AssertEqual(t, nil, multiErr.ErrorOrNil())
+	utils.AssertEqual(t, http.StatusOK, multiErr.StatusCode())
+
+	notFound := NewError(http.StatusNotFound)
+	multiErr.Add(3, notFound)
+	multiErr.AddKey(7, "sku-7", fiber.ErrBadRequest)
+	multiErr.Add(8, nil)
+
+	utils.AssertEqual(t, 2, multiErr.Len())
+	utils.AssertEqual(t, http.StatusMultiStatus, multiErr.StatusCode())
+	utils.AssertEqual(t, true, errors.Is(multiErr, notFound))
+	// Is and As do not rely on Unwrap() []error of Go 1.20
+	utils.AssertEqual(t, true, multiErr.Is(notFound))
+	utils.AssertEqual(t, false, multiErr.Is(fiber.ErrConflict))
+	var itemErr *Error
+	utils.AssertEqual(t, true, multiErr.As(&itemErr))
+	utils.AssertEqual(t, http.StatusNotFound, itemErr.Code)
+
+	errs := multiErr.ResponseErrors()
+	utils.AssertEqual(t, "3", errs[0].Source)
+	utils.AssertEqual(t, "sku-7", errs[1].Source)
+	utils.AssertEqual(t, http.StatusBadRequest, errs[1].Code)
+
+	multiErr.Total = 2
+	utils.AssertEqual(t, http.StatusBadRequest, multiErr.StatusCode())
+	multiErr.Add(9, errors.New("db down"))
+	multiErr.Total = 3
+	utils.AssertEqual(t, http.StatusInternalServerError, multiErr.StatusCode())
+}
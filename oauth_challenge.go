@@ -0,0 +1,158 @@
+package helpers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// AuthChallenge WWW-Authenticate challenge (RFC 7235 section 4.1),
+// error parameters follow RFC 6750 section 3 for Bearer and RFC 9449 section 7.1 for DPoP.
+type AuthChallenge struct {
+	Scheme AuthType
+	Realm  string
+	// Error is omitted when request has no credentials (RFC 6750 section 3.1)
+	Error            OauthErr
+	ErrorDescription string
+	// Scope required by resource, e.g. with insufficient_scope
+	Scope string
+	// Algs accepted DPoP proof algorithms
+	Algs []JWTAlgorithm
+}
+
+// Name return scheme name as registered, e.g. Bearer.
+func (t AuthType) Name() string {
+	switch t {
+	case BasicAuth:
+		return "Basic"
+	case BearerToken:
+		return "Bearer"
+	case DPoPToken:
+		return "DPoP"
+	case APIKeyAuth:
+		return "ApiKey"
+	case DigestAuth:
+		return "Digest"
+	case HMACAuth:
+		return "HMAC-SHA256"
+	}
+	return string(t)
+}
+
+// String render challenge as WWW-Authenticate value.
+func (a AuthChallenge) String() string {
+	var params []string
+	if a.Realm != "" {
+		params = append(params, "realm="+quoteAuthParam(a.Realm))
+	}
+	if a.Scheme == BasicAuth {
+		params = append(params, `charset="UTF-8"`)
+	}
+	if a.Error != "" {
+		params = append(params, "error="+quoteAuthParam(string(a.Error)))
+	}
+	if a.ErrorDescription != "" {
+		params = append(params, "error_description="+quoteAuthParam(a.ErrorDescription))
+	}
+	if a.Scope != "" {
+		params = append(params, "scope="+quoteAuthParam(a.Scope))
+	}
+	if len(a.Algs) != 0 {
+		algs := make([]string, len(a.Algs))
+		for i, alg := range a.Algs {
+			algs[i] = string(alg)
+		}
+		params = append(params, "algs="+quoteAuthParam(strings.Join(algs, " ")))
+	}
+	if len(params) == 0 {
+		return a.Scheme.Name()
+	}
+	return a.Scheme.Name() + " " + strings.Join(params, ", ")
+}
+
+// quoteAuthParam quote value as quoted-string, characters outside
+// of RFC 6750 error_description charset are replaced.
+func quoteAuthParam(value string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range value {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x20 || r > 0x7e:
+			b.WriteByte('?')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// SetAuthChallenge set WWW-Authenticate of response, several challenges are comma separated.
+func SetAuthChallenge(c *fiber.Ctx, challenges ...AuthChallenge) {
+	values := make([]string, len(challenges))
+	for i, challenge := range challenges {
+		values[i] = challenge.String()
+	}
+	c.Set(fiber.HeaderWWWAuthenticate, strings.Join(values, ", "))
+}
+
+// ResourceStatus return HTTP status of error at protected resource,
+// invalid_dpop_proof is 401 unlike at token endpoint (RFC 9449 section 7.1).
+func (e OauthErr) ResourceStatus() int {
+	if e == InvalidDPoPProof {
+		return http.StatusUnauthorized
+	}
+	return e.HTTPStatus()
+}
+
+// writeResourceError send OAuth error of endpoint protected by bearer token,
+// e.g. userinfo and client configuration, with matching challenge.
+func writeResourceError(c *fiber.Ctx, realm string, oauthErr *OauthError, scopes ...string) error {
+	SetAuthChallenge(c, AuthChallenge{
+		Scheme:           BearerToken,
+		Realm:            realm,
+		Error:            oauthErr.Err,
+		ErrorDescription: oauthErr.Description,
+		Scope:            strings.Join(scopes, " "),
+	})
+	oauthErr.Status = oauthErr.Err.ResourceStatus()
+	return writeOauthError(c, oauthErr)
+}
+
+// resourceError set challenge of scheme used by request and return error with resource status,
+// request without credentials is challenged for Bearer and, when dpop is set, DPoP.
+func resourceError(c *fiber.Ctx, realm string, scheme AuthType, dpop *DPoPVerifier, oauthErr *OauthError, scopes ...string) error {
+	challenge := AuthChallenge{
+		Scheme: BearerToken,
+		Realm:  realm,
+		Scope:  strings.Join(scopes, " "),
+	}
+	if oauthErr != nil {
+		challenge.Error = oauthErr.Err
+		challenge.ErrorDescription = oauthErr.Description
+	}
+	challenges := []AuthChallenge{challenge}
+	if dpop != nil {
+		switch scheme {
+		case DPoPToken:
+			challenges[0].Scheme = DPoPToken
+			challenges[0].Algs = dpop.Algorithms()
+		case "":
+			challenges = append(challenges, AuthChallenge{Scheme: DPoPToken, Realm: realm, Algs: dpop.Algorithms()})
+		}
+	}
+	SetAuthChallenge(c, challenges...)
+
+	if oauthErr == nil {
+		return fiber.NewError(http.StatusUnauthorized, "missing access token")
+	}
+	message := oauthErr.Description
+	if message == "" {
+		message = string(oauthErr.Err)
+	}
+	return fiber.NewError(oauthErr.Err.ResourceStatus(), message)
+}
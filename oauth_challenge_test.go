@@ -0,0 +1,127 @@
+package helpers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestAuthChallengeString(t *testing.T) {
+	t.Parallel()
+
+	for expected, challenge := range map[string]AuthChallenge{
+		`Bearer`:                           {Scheme: BearerToken},
+		`Bearer realm="example"`:           {Scheme: BearerToken, Realm: "example"},
+		`Basic realm="a", charset="UTF-8"`: {Scheme: BasicAuth, Realm: "a"},
+		`Bearer realm="example", error="invalid_token", error_description="token \"expired\""`: {
+			Scheme:           BearerToken,
+			Realm:            "example",
+			Error:            InvalidToken,
+			ErrorDescription: `token "expired"`,
+		},
+		`Bearer error="insufficient_scope", scope="orders:read orders:write"`: {
+			Scheme: BearerToken,
+			Error:  InsufficientScope,
+			Scope:  "orders:read orders:write",
+		},
+		`DPoP error_description="caf? \\ x", algs="ES256 EdDSA"`: {
+			Scheme:           DPoPToken,
+			ErrorDescription: "café \\ x",
+			Algs:             []JWTAlgorithm{ES256, EdDSA},
+		},
+	} {
+		utils.AssertEqual(t, expected, challenge.String())
+	}
+}
+
+func TestResourceChallenge(t *testing.T) {
+	t.Parallel()
+	key, err := GenerateJWTKey(ES256)
+	utils.AssertEqual(t, nil, err)
+	keys := NewJWTKeySet(key)
+
+	app := fiber.New()
+	app.Get("/orders", JWTAuth(JWTConfig{Keys: keys, Realm: "api", Scopes: []string{"orders:read"}}), func(c *fiber.Ctx) error {
+		return c.SendStatus(http.StatusOK)
+	})
+	app.Get("/basic", func(c *fiber.Ctx) error {
+		if err := (&Ctx{c}).BasicAuth("user", "passwd"); err != nil {
+			return err
+		}
+		return c.SendStatus(http.StatusOK)
+	})
+	app.Get("/admin", func(c *fiber.Ctx) error {
+		if err := (&Ctx{c}).BasicAuth("user", "passwd", "admin"); err != nil {
+			return err
+		}
+		return c.SendStatus(http.StatusOK)
+	})
+	server := NewOauthServer(OauthServerConfig{Issuer: "https://auth.example.com", Keys: keys})
+	app.Get("/userinfo", server.UserInfoHandler)
+
+	request := func(path, authorization string) *http.Response {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if authorization != "" {
+			req.Header.Set(fiber.HeaderAuthorization, authorization)
+		}
+		resp, err := app.Test(req)
+		utils.AssertEqual(t, nil, err)
+		return resp
+	}
+
+	// no credentials, no error code (RFC 6750 section 3.1)
+	resp := request("/orders", "")
+	utils.AssertEqual(t, http.StatusUnauthorized, resp.StatusCode)
+	utils.AssertEqual(t, `Bearer realm="api", DPoP realm="api", algs="RS256 ES256 EdDSA"`, resp.Header.Get(fiber.HeaderWWWAuthenticate))
+
+	resp = request("/orders", "Bearer invalid")
+	utils.AssertEqual(t, http.StatusUnauthorized, resp.StatusCode)
+	utils.AssertEqual(t, true, strings.HasPrefix(resp.Header.Get(fiber.HeaderWWWAuthenticate), `Bearer realm="api", error="invalid_token"`))
+
+	token, err := key.Sign(JWTClaims{
+		Issuer:    "https://auth.example.com",
+		Subject:   "user-1",
+		ExpiresAt: time.Now().Add(time.Minute).Unix(),
+		Scope:     "profile",
+	}, JWTTypeAccessToken)
+	utils.AssertEqual(t, nil, err)
+	resp = request("/orders", "Bearer "+token)
+	utils.AssertEqual(t, http.StatusForbidden, resp.StatusCode)
+	utils.AssertEqual(t, `Bearer realm="api", error="insufficient_scope", error_description="insufficient scope", scope="orders:read"`, resp.Header.Get(fiber.HeaderWWWAuthenticate))
+
+	resp = request("/basic", "Basic invalid")
+	utils.AssertEqual(t, http.StatusUnauthorized, resp.StatusCode)
+	utils.AssertEqual(t, `Basic realm="Restricted", charset="UTF-8"`, resp.Header.Get(fiber.HeaderWWWAuthenticate))
+	resp = request("/admin", "")
+	utils.AssertEqual(t, `Basic realm="admin", charset="UTF-8"`, resp.Header.Get(fiber.HeaderWWWAuthenticate))
+
+	// userinfo without token is challenged without error code or invalid_token body
+	resp = request("/userinfo", "")
+	utils.AssertEqual(t, http.StatusUnauthorized, resp.StatusCode)
+	utils.AssertEqual(t, `Bearer realm="https://auth.example.com", DPoP realm="https://auth.example.com", algs="RS256 ES256 EdDSA"`, resp.Header.Get(fiber.HeaderWWWAuthenticate))
+	body, _ := io.ReadAll(resp.Body)
+	utils.AssertEqual(t, "missing access token", string(body))
+}
+
+func TestAsOauthErrorHidesCause(t *testing.T) {
+	t.Parallel()
+
+	cause := errors.New("dial tcp 10.0.0.5:5432: connection refused")
+	err := AsOauthError(fmt.Errorf("save token: %w", cause))
+	utils.AssertEqual(t, ServerError, err.Err)
+	utils.AssertEqual(t, "internal server error", err.Description)
+	utils.AssertEqual(t, http.StatusInternalServerError, err.Status)
+	utils.AssertEqual(t, true, errors.Is(err, cause))
+	utils.AssertEqual(t, false, strings.Contains(err.Response().ErrorDesc, "10.0.0.5"))
+
+	oauthErr := NewOauthError(InvalidGrant, "code expired")
+	utils.AssertEqual(t, oauthErr, AsOauthError(fmt.Errorf("exchange: %w", oauthErr)))
+}
@@ -0,0 +1,317 @@
+package helpers
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/segmentio/encoding/json"
+)
+
+// ClientAssertionTypeJWT client_assertion_type of private_key_jwt (RFC 7523 section 2.2)
+const ClientAssertionTypeJWT = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+
+// authenticateClient verify client credentials of token, introspection, revocation
+// and device authorization requests.
+//
+// Credentials may come from HTTP Basic (client_secret_basic), form (client_secret_post)
+// or signed JWT assertion (private_key_jwt, RFC 7523), using more than one is rejected.
+// Failure is invalid_client with WWW-Authenticate challenge (RFC 6749 section 5.2).
+func (s *OauthServer) authenticateClient(c *fiber.Ctx, req *OauthRequest) (client *OauthClient, oauthErr *OauthError) {
+	client, oauthErr = s.verifyClient(c, req)
+	if oauthErr != nil && oauthErr.Err == InvalidClient {
+		oauthErr.Status = http.StatusUnauthorized
+		SetAuthChallenge(c, AuthChallenge{Scheme: BasicAuth, Realm: s.cfg.Issuer})
+	}
+	return
+}
+
+func (s *OauthServer) verifyClient(c *fiber.Ctx, req *OauthRequest) (client *OauthClient, oauthErr *OauthError) {
+	ctx := c.UserContext()
+
+	var methods []ClientAuthMethod
+	var basic HttpAuth
+	if header := c.Get(fiber.HeaderAuthorization); header != "" {
+		var err error
+		if basic, err = ExtractAuthString(header); err != nil || basic.Type != BasicAuth {
+			return nil, NewOauthError(InvalidClient, "unsupported authorization header")
+		}
+		methods = append(methods, ClientSecretBasic)
+	}
+	if req.APISecret != "" {
+		methods = append(methods, ClientSecretPost)
+	}
+	if req.ClientAssertion != "" || req.ClientAssertionType != "" {
+		methods = append(methods, PrivateKeyJWT)
+	}
+	if len(methods) > 1 {
+		return nil, NewOauthError(InvalidClient, "multiple client authentication methods")
+	}
+
+	method := ClientAuthNone
+	if len(methods) == 1 {
+		method = methods[0]
+	}
+	clientID := req.APIKey
+	switch method {
+	case ClientSecretBasic:
+		// credentials are form-urlencoded before base64 (RFC 6749 section 2.3.1)
+		username, err1 := url.QueryUnescape(basic.Username)
+		password, err2 := url.QueryUnescape(basic.Password)
+		if err1 != nil || err2 != nil || username == "" {
+			return nil, NewOauthError(InvalidClient, "malformed basic credentials")
+		}
+		if clientID != "" && clientID != username {
+			return nil, NewOauthError(InvalidClient, "client_id mismatch")
+		}
+		clientID = username
+		req.APIKey = username
+		req.APISecret = password
+	case PrivateKeyJWT:
+		if req.ClientAssertionType != ClientAssertionTypeJWT {
+			return nil, NewOauthError(InvalidClient, "unsupported client_assertion_type")
+		}
+		var claims JWTClaims
+		if _, err := ParseJWT(req.ClientAssertion, &claims); err != nil {
+			return nil, NewOauthError(InvalidClient, "malformed client_assertion")
+		}
+		if clientID != "" && clientID != claims.Subject {
+			return nil, NewOauthError(InvalidClient, "client_id mismatch")
+		}
+		clientID = claims.Subject
+		req.APIKey = clientID
+	}
+
+	if clientID == "" {
+		return nil, NewOauthError(InvalidClient, "missing client_id")
+	}
+	client, err := s.cfg.Clients.GetClient(ctx, clientID)
+	if err != nil {
+		return nil, NewOauthError(InvalidClient, "unknown client")
+	}
+
+	registered := client.AuthMethod
+	if registered == "" {
+		// statically configured clients
+		registered = ClientSecretBasic
+		if client.Public {
+			registered = ClientAuthNone
+		}
+	}
+	switch {
+	case registered == method:
+	case registered == ClientSecretBasic && method == ClientSecretPost && client.AuthMethod == "":
+		// static clients may use either secret method
+	default:
+		return nil, NewOauthError(InvalidClient, fmt.Sprintf("client must authenticate with %s", registered))
+	}
+
+	switch method {
+	case ClientSecretBasic, ClientSecretPost:
+		if !client.VerifySecret(req.APISecret) {
+			return nil, NewOauthError(InvalidClient, "invalid client credentials")
+		}
+	case PrivateKeyJWT:
+		if oauthErr = s.verifyClientAssertion(c, client, req.ClientAssertion); oauthErr != nil {
+			return nil, oauthErr
+		}
+	}
+	return client, nil
+}
+
+// verifyClientAssertion verify JWT assertion against client keys (RFC 7523 section 3).
+func (s *OauthServer) verifyClientAssertion(c *fiber.Ctx, client *OauthClient, assertion string) *OauthError {
+	keys, err := s.clientKeys(c.UserContext(), client)
+	if err != nil {
+		return NewOauthError(InvalidClient, "client keys unavailable")
+	}
+	var claims JWTClaims
+	if _, err = VerifyJWT(assertion, &claims, clientKeyFunc(keys)); err != nil {
+		return NewOauthError(InvalidClient, "invalid client_assertion signature")
+	}
+	if err = claims.Validate("", "", time.Minute); err != nil {
+		return NewOauthError(InvalidClient, err.Error())
+	}
+
+	switch {
+	case claims.Issuer != client.ID || claims.Subject != client.ID:
+		return NewOauthError(InvalidClient, "client_assertion iss and sub must be client_id")
+	case claims.ExpiresAt == 0:
+		return NewOauthError(InvalidClient, "client_assertion missing exp")
+	case claims.ID == "":
+		return NewOauthError(InvalidClient, "client_assertion missing jti")
+	}
+	audienceOK := false
+	for _, aud := range s.assertionAudiences() {
+		if claims.Audience.Contains(aud) {
+			audienceOK = true
+			break
+		}
+	}
+	if !audienceOK {
+		return NewOauthError(InvalidClient, "unexpected client_assertion audience")
+	}
+	if !s.replays.Add(client.ID+":"+claims.ID, time.Unix(claims.ExpiresAt, 0).Add(time.Minute)) {
+		return NewOauthError(InvalidClient, "client_assertion replayed")
+	}
+	return nil
+}
+
+// assertionAudiences accepted aud of client assertion: configured issuer and its token endpoint,
+// never URL of request which is built from client controlled Host header.
+func (s *OauthServer) assertionAudiences() []string {
+	if s.cfg.Issuer == "" {
+		return nil
+	}
+	issuer := strings.TrimSuffix(s.cfg.Issuer, "/")
+	return []string{s.cfg.Issuer, issuer, issuer + "/token"}
+}
+
+// clientKeys return registered JWKS of client, jwks_uri is fetched and cached.
+func (s *OauthServer) clientKeys(ctx context.Context, client *OauthClient) (keys JWKSet, err error) {
+	if client.JWKS != nil {
+		return *client.JWKS, nil
+	}
+	if client.JWKSURI == "" {
+		return keys, fiber.ErrNotFound
+	}
+	return s.remoteKeys.Get(ctx, client.JWKSURI)
+}
+
+// clientKeyFunc select key of client JWKS by kid, kid may be omitted when client has single key.
+func clientKeyFunc(keys JWKSet) func(header JWTHeader) (*JWTKey, error) {
+	return func(header JWTHeader) (*JWTKey, error) {
+		jwk := keys.Key(header.Kid)
+		if jwk == nil && header.Kid == "" && len(keys.Keys) == 1 {
+			jwk = &keys.Keys[0]
+		}
+		if jwk == nil {
+			return nil, fmt.Errorf("unknown kid %q", header.Kid)
+		}
+		return NewJWTKeyFromJWK(*jwk)
+	}
+}
+
+// replayCache remember seen values until expiry, e.g. jti of assertions and proofs.
+type replayCache struct {
+	mu     sync.Mutex
+	values map[string]time.Time
+	// expiry min-heap of values, so each Add only pops what has expired
+	expiry replayExpiry
+}
+
+type replayEntry struct {
+	value     string
+	expiresAt time.Time
+}
+
+// replayExpiry implements heap.Interface ordered by expiresAt
+type replayExpiry []replayEntry
+
+func (h replayExpiry) Len() int            { return len(h) }
+func (h replayExpiry) Less(i, j int) bool  { return h[i].expiresAt.Before(h[j].expiresAt) }
+func (h replayExpiry) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *replayExpiry) Push(x interface{}) { *h = append(*h, x.(replayEntry)) }
+func (h *replayExpiry) Pop() interface{} {
+	old := *h
+	entry := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return entry
+}
+
+func newReplayCache() *replayCache {
+	return &replayCache{values: make(map[string]time.Time)}
+}
+
+// Add record value until expiresAt, false when value was already seen.
+func (r *replayCache) Add(value string, expiresAt time.Time) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	for len(r.expiry) != 0 && now.After(r.expiry[0].expiresAt) {
+		entry := heap.Pop(&r.expiry).(replayEntry)
+		delete(r.values, entry.value)
+	}
+	if _, ok := r.values[value]; ok {
+		return false
+	}
+	r.values[value] = expiresAt
+	heap.Push(&r.expiry, replayEntry{value: value, expiresAt: expiresAt})
+	return true
+}
+
+// jwksCache fetch remote JWKS and keep it for TTL.
+type jwksCache struct {
+	HTTPClient *http.Client
+	TTL        time.Duration
+
+	mu   sync.Mutex
+	sets map[string]jwksCacheEntry
+}
+
+type jwksCacheEntry struct {
+	keys      JWKSet
+	expiresAt time.Time
+}
+
+// maxJWKSSize limit body of remote JWKS
+const maxJWKSSize = 1 << 20
+
+func (j *jwksCache) Get(ctx context.Context, uri string) (keys JWKSet, err error) {
+	if !isHTTPSURI(uri) {
+		return keys, fiber.NewError(http.StatusBadRequest, "jwks_uri must use https")
+	}
+	j.mu.Lock()
+	entry, ok := j.sets[uri]
+	j.mu.Unlock()
+	if ok && time.Now().Before(entry.expiresAt) {
+		return entry.keys, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return
+	}
+	req.Header.Set(fiber.HeaderAccept, fiber.MIMEApplicationJSON)
+	httpClient := j.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		err = fiber.NewError(http.StatusBadGateway, fmt.Sprintf("jwks_uri returned %d", resp.StatusCode))
+		return
+	}
+	if err = json.NewDecoder(io.LimitReader(resp.Body, maxJWKSSize)).Decode(&keys); err != nil {
+		return
+	}
+
+	ttl := j.TTL
+	if ttl <= 0 {
+		ttl = 5 * time.Minute
+	}
+	j.mu.Lock()
+	if j.sets == nil {
+		j.sets = make(map[string]jwksCacheEntry)
+	}
+	j.sets[uri] = jwksCacheEntry{keys: keys, expiresAt: time.Now().Add(ttl)}
+	j.mu.Unlock()
+	return
+}
+
+// isHTTPSURI report whether uri is absolute https URL with host.
+func isHTTPSURI(uri string) bool {
+	u, err := url.Parse(uri)
+	return err == nil && u.Scheme == "https" && u.Host != ""
+}
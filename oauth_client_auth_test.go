@@ -0,0 +1,124 @@
+package helpers
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestOauthServerClientAuthentication(t *testing.T) {
+	t.Parallel()
+	app, _, store := newTestOauthServer(t)
+
+	basic := func(id, secret string) string {
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(url.QueryEscape(id)+":"+url.QueryEscape(secret)))
+	}
+	form := url.Values{"grant_type": {"client_credentials"}}
+
+	status, _ := oauthTokenRequest(t, app, form, fiber.HeaderAuthorization, basic("web", "web-secret"))
+	utils.AssertEqual(t, http.StatusOK, status)
+
+	status, resp := oauthTokenRequest(t, app, form, fiber.HeaderAuthorization, basic("web", "wrong"))
+	utils.AssertEqual(t, http.StatusUnauthorized, status)
+	utils.AssertEqual(t, InvalidClient, resp.Error)
+
+	// mixing basic and post is rejected
+	mixed := url.Values{"grant_type": {"client_credentials"}, "client_id": {"web"}, "client_secret": {"web-secret"}}
+	req := oauthFormRequest("/token", mixed, fiber.HeaderAuthorization, basic("web", "web-secret"))
+	httpResp, err := app.Test(req)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, http.StatusUnauthorized, httpResp.StatusCode)
+	utils.AssertEqual(t, `Basic realm="https://auth.example.com", charset="UTF-8"`, httpResp.Header.Get(fiber.HeaderWWWAuthenticate))
+
+	// private_key_jwt
+	key, err := GenerateJWTKey(ES256)
+	utils.AssertEqual(t, nil, err)
+	jwk, err := key.JWK()
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, nil, store.SaveClient(context.Background(), &OauthClient{
+		ID:         "service",
+		AuthMethod: PrivateKeyJWT,
+		JWKS:       &JWKSet{Keys: []JWK{jwk}},
+		GrantTypes: []GrantType{GrantTypeClient},
+	}))
+	assertion := func(aud, jti string) string {
+		token, err := key.Sign(JWTClaims{
+			Issuer:    "service",
+			Subject:   "service",
+			Audience:  JWTAudience{aud},
+			ExpiresAt: time.Now().Add(time.Minute).Unix(),
+			ID:        jti,
+		})
+		utils.AssertEqual(t, nil, err)
+		return token
+	}
+	jwtForm := func(token string) url.Values {
+		return url.Values{
+			"grant_type":            {"client_credentials"},
+			"client_assertion_type": {ClientAssertionTypeJWT},
+			"client_assertion":      {token},
+		}
+	}
+
+	token := assertion("https://auth.example.com/token", "1")
+	status, resp = oauthTokenRequest(t, app, jwtForm(token))
+	utils.AssertEqual(t, http.StatusOK, status, resp.ErrorDesc)
+
+	status, resp = oauthTokenRequest(t, app, jwtForm(token))
+	utils.AssertEqual(t, http.StatusUnauthorized, status)
+	utils.AssertEqual(t, "client_assertion replayed", resp.ErrorDesc)
+
+	status, _ = oauthTokenRequest(t, app, jwtForm(assertion("https://other.example.com", "2")))
+	utils.AssertEqual(t, http.StatusUnauthorized, status)
+	// URL built from Host header of request is not trusted as audience
+	status, _ = oauthTokenRequest(t, app, jwtForm(assertion("http://example.com/token", "4")))
+	utils.AssertEqual(t, http.StatusUnauthorized, status)
+
+	// registered method is enforced
+	other, err := GenerateJWTKey(ES256)
+	utils.AssertEqual(t, nil, err)
+	forged, err := other.Sign(JWTClaims{Issuer: "service", Subject: "service", Audience: JWTAudience{"https://auth.example.com"}, ExpiresAt: time.Now().Add(time.Minute).Unix(), ID: "3"})
+	utils.AssertEqual(t, nil, err)
+	status, _ = oauthTokenRequest(t, app, jwtForm(forged))
+	utils.AssertEqual(t, http.StatusUnauthorized, status)
+	status, _ = oauthTokenRequest(t, app, url.Values{"grant_type": {"client_credentials"}, "client_id": {"service"}, "client_secret": {"x"}})
+	utils.AssertEqual(t, http.StatusUnauthorized, status)
+}
+
+func TestReplayCache(t *testing.T) {
+	t.Parallel()
+	cache := newReplayCache()
+	now := time.Now()
+	utils.AssertEqual(t, true, cache.Add("jti-1", now.Add(-time.Second)))
+	utils.AssertEqual(t, true, cache.Add("jti-2", now.Add(time.Minute)))
+	utils.AssertEqual(t, false, cache.Add("jti-2", now.Add(time.Minute)))
+
+	// expired values are popped on next add without scanning live ones
+	utils.AssertEqual(t, true, cache.Add("jti-1", now.Add(time.Minute)))
+	utils.AssertEqual(t, 2, len(cache.values))
+	utils.AssertEqual(t, 2, cache.expiry.Len())
+}
+
+func TestJWKSCache(t *testing.T) {
+	t.Parallel()
+	cache := &jwksCache{}
+	_, err := cache.Get(context.Background(), "http://client.example.com/jwks.json")
+	utils.AssertEqual(t, "jwks_uri must use https", err.Error())
+
+	// oversized body is cut off instead of read into memory
+	remote := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"keys":[{"kty":"` + strings.Repeat("A", maxJWKSSize) + `"}]}`))
+	}))
+	defer remote.Close()
+	cache.HTTPClient = remote.Client()
+	_, err = cache.Get(context.Background(), remote.URL)
+	utils.AssertEqual(t, true, err != nil)
+}
@@ -0,0 +1,218 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// OauthConsent scopes a user granted to a client
+type OauthConsent struct {
+	UserID    string    `json:"user_id"`
+	ClientID  string    `json:"client_id"`
+	Scope     string    `json:"scope"`
+	GrantedAt time.Time `json:"granted_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// OauthConsentRequest consent asked from user by ConsentUser hook
+type OauthConsentRequest struct {
+	Client  *OauthClient
+	UserID  string
+	Request *OauthRequest
+	// Scope requested by client
+	Scope string
+	// Granted scope of previous consent, empty at first authorization
+	Granted string
+}
+
+// OauthConsentStore record consents of users.
+//
+// Stores return fiber.ErrNotFound when record does not exist.
+type OauthConsentStore interface {
+	SaveConsent(ctx context.Context, consent *OauthConsent) error
+	GetConsent(ctx context.Context, userID, clientID string) (*OauthConsent, error)
+	ListConsents(ctx context.Context, userID string) ([]*OauthConsent, error)
+	RemoveConsent(ctx context.Context, userID, clientID string) error
+}
+
+// OauthGrantRevoker revoke tokens of user issued to client, the token store
+// implements it to revoke tokens together with consent.
+type OauthGrantRevoker interface {
+	RevokeGrantTokens(ctx context.Context, userID, clientID string) error
+}
+
+// consent return scope granted by user, ConsentUser is asked when requested scope
+// was not granted to client before, also for request without scope. responded is true
+// when the hook has responded itself.
+func (s *OauthServer) consent(c *fiber.Ctx, client *OauthClient, userID string, req *OauthRequest) (scope string, responded bool, err error) {
+	if s.cfg.ConsentUser == nil {
+		return req.Scope, false, nil
+	}
+	ctx := c.UserContext()
+	requested := ParseScope(req.Scope)
+	previous, err := s.cfg.Consents.GetConsent(ctx, userID, client.ID)
+	if errors.Is(err, fiber.ErrNotFound) {
+		previous, err = nil, nil
+	}
+	if err != nil {
+		return
+	}
+	if previous != nil && ParseScope(previous.Scope).Contains(requested) {
+		return requested.String(), false, nil
+	}
+
+	consent := &OauthConsentRequest{
+		Client:  client,
+		UserID:  userID,
+		Request: req,
+		Scope:   requested.String(),
+	}
+	if previous != nil {
+		consent.Granted = previous.Scope
+	}
+	granted, err := s.cfg.ConsentUser(c, consent)
+	if err != nil {
+		return
+	}
+	if granted == "" {
+		return "", true, nil
+	}
+	// user may grant part of requested scope
+	if scope = ParseScope(granted).Intersect(requested).String(); scope == "" && len(requested) != 0 {
+		return "", false, NewOauthError(AccessDenied, "no scope granted")
+	}
+
+	now := time.Now()
+	record := &OauthConsent{
+		UserID:    userID,
+		ClientID:  client.ID,
+		Scope:     scope,
+		GrantedAt: now,
+		UpdatedAt: now,
+	}
+	if previous != nil {
+		record.Scope = ParseScope(previous.Scope + " " + scope).String()
+		record.GrantedAt = previous.GrantedAt
+	}
+	err = s.cfg.Consents.SaveConsent(ctx, record)
+	return
+}
+
+// ListGrants return consents of user.
+func (s *OauthServer) ListGrants(ctx context.Context, userID string) ([]*OauthConsent, error) {
+	return s.cfg.Consents.ListConsents(ctx, userID)
+}
+
+// RevokeGrant remove consent of user to client and revoke tokens issued by it.
+func (s *OauthServer) RevokeGrant(ctx context.Context, userID, clientID string) (err error) {
+	if _, err = s.cfg.Consents.GetConsent(ctx, userID, clientID); err != nil {
+		return
+	}
+	if err = s.cfg.Consents.RemoveConsent(ctx, userID, clientID); err != nil {
+		return
+	}
+	if revoker, ok := s.cfg.Tokens.(OauthGrantRevoker); ok {
+		if err = revoker.RevokeGrantTokens(ctx, userID, clientID); err != nil {
+			return
+		}
+	}
+	s.emit(ctx, OauthEvent{Type: EventGrantRevoked, ClientID: clientID, UserID: userID})
+	return
+}
+
+// ListGrantsHandler list grants of user returned by GrantsUser
+func (s *OauthServer) ListGrantsHandler(c *fiber.Ctx) error {
+	userID, err := s.cfg.GrantsUser(c)
+	if err != nil {
+		return err
+	}
+	if userID == "" {
+		return nil
+	}
+	consents, err := s.ListGrants(c.UserContext(), userID)
+	if err != nil {
+		return err
+	}
+	if consents == nil {
+		consents = []*OauthConsent{}
+	}
+	return c.JSON(consents)
+}
+
+// RevokeGrantHandler revoke grant of user returned by GrantsUser to client of path
+func (s *OauthServer) RevokeGrantHandler(c *fiber.Ctx) error {
+	userID, err := s.cfg.GrantsUser(c)
+	if err != nil {
+		return err
+	}
+	if userID == "" {
+		return nil
+	}
+	if err = s.RevokeGrant(c.UserContext(), userID, c.Params("client_id")); err != nil {
+		return err
+	}
+	return c.SendStatus(http.StatusNoContent)
+}
+
+func (s *MemoryOauthStore) SaveConsent(ctx context.Context, consent *OauthConsent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.consents[consent.UserID] == nil {
+		s.consents[consent.UserID] = make(map[string]*OauthConsent)
+	}
+	record := *consent
+	s.consents[consent.UserID][consent.ClientID] = &record
+	return nil
+}
+
+func (s *MemoryOauthStore) GetConsent(ctx context.Context, userID, clientID string) (*OauthConsent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	consent, ok := s.consents[userID][clientID]
+	if !ok {
+		return nil, fiber.ErrNotFound
+	}
+	record := *consent
+	return &record, nil
+}
+
+func (s *MemoryOauthStore) ListConsents(ctx context.Context, userID string) (consents []*OauthConsent, err error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, consent := range s.consents[userID] {
+		record := *consent
+		consents = append(consents, &record)
+	}
+	sort.Slice(consents, func(i, j int) bool {
+		return consents[i].ClientID < consents[j].ClientID
+	})
+	return
+}
+
+func (s *MemoryOauthStore) RemoveConsent(ctx context.Context, userID, clientID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.consents[userID], clientID)
+	return nil
+}
+
+func (s *MemoryOauthStore) RevokeGrantTokens(ctx context.Context, userID, clientID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, token := range s.accessTokens {
+		if token.UserID == userID && token.ClientID == clientID {
+			delete(s.accessTokens, key)
+		}
+	}
+	for key, token := range s.refreshTokens {
+		if token.UserID == userID && token.ClientID == clientID {
+			delete(s.refreshTokens, key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package helpers
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/segmentio/encoding/json"
+)
+
+func TestOauthServerConsent(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	store := NewMemoryOauthStore()
+	utils.AssertEqual(t, nil, store.SaveClient(ctx, &OauthClient{
+		ID:           "web",
+		Secret:       "web-secret",
+		RedirectURIs: []string{"https://client.example.com/cb"},
+		Scope:        "profile orders",
+	}))
+	var asked []string
+	server := NewOauthServer(OauthServerConfig{
+		Issuer:  "https://auth.example.com",
+		Clients: store,
+		Tokens:  store,
+		AuthorizeUser: func(c *fiber.Ctx, req *OauthRequest) (string, error) {
+			if c.Query("user") != "" {
+				return c.Query("user"), nil
+			}
+			return "user-1", nil
+		},
+		ConsentUser: func(c *fiber.Ctx, consent *OauthConsentRequest) (string, error) {
+			asked = append(asked, consent.Scope)
+			switch c.Query("consent") {
+			case "":
+				return "", c.SendString("consent page")
+			case "deny":
+				return "", NewOauthError(AccessDenied)
+			}
+			return c.Query("consent"), nil
+		},
+		GrantsUser: func(c *fiber.Ctx) (string, error) {
+			if c.Get("X-User") == "" {
+				return "", fiber.ErrUnauthorized
+			}
+			return c.Get("X-User"), nil
+		},
+	})
+	app := fiber.New()
+	server.Register(app)
+
+	authorize := func(scope, consent string) *http.Response {
+		query := url.Values{
+			"client_id":     {"web"},
+			"response_type": {"code"},
+			"scope":         {scope},
+			"consent":       {consent},
+		}
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/authorize?"+query.Encode(), nil))
+		utils.AssertEqual(t, nil, err)
+		return resp
+	}
+
+	resp := authorize("profile orders", "")
+	utils.AssertEqual(t, http.StatusOK, resp.StatusCode)
+	body, _ := io.ReadAll(resp.Body)
+	utils.AssertEqual(t, "consent page", string(body))
+
+	// partial grant narrows scope of issued token
+	resp = authorize("profile orders", "profile")
+	utils.AssertEqual(t, http.StatusFound, resp.StatusCode)
+	location, err := url.Parse(resp.Header.Get(fiber.HeaderLocation))
+	utils.AssertEqual(t, nil, err)
+	status, token := oauthTokenRequest(t, app, url.Values{
+		"grant_type":    {"authorization_code"},
+		"client_id":     {"web"},
+		"client_secret": {"web-secret"},
+		"code":          {location.Query().Get("code")},
+	})
+	utils.AssertEqual(t, http.StatusOK, status, token.ErrorDesc)
+	utils.AssertEqual(t, "profile", token.Scope)
+
+	// granted scope is not asked again
+	asked = nil
+	resp = authorize("profile", "")
+	utils.AssertEqual(t, http.StatusFound, resp.StatusCode)
+	utils.AssertEqual(t, 0, len(asked))
+
+	resp = authorize("orders", "deny")
+	utils.AssertEqual(t, http.StatusFound, resp.StatusCode)
+	location, err = url.Parse(resp.Header.Get(fiber.HeaderLocation))
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, string(AccessDenied), location.Query().Get("error"))
+	utils.AssertEqual(t, []string{"orders"}, asked)
+
+	// request without scope still needs consent of new user
+	asked = nil
+	query := url.Values{"client_id": {"web"}, "response_type": {"code"}, "user": {"user-2"}}
+	resp, err = app.Test(httptest.NewRequest(http.MethodGet, "/authorize?"+query.Encode(), nil))
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, http.StatusOK, resp.StatusCode)
+	query.Set("consent", "*")
+	resp, err = app.Test(httptest.NewRequest(http.MethodGet, "/authorize?"+query.Encode(), nil))
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, http.StatusFound, resp.StatusCode)
+	query.Del("consent")
+	resp, err = app.Test(httptest.NewRequest(http.MethodGet, "/authorize?"+query.Encode(), nil))
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, http.StatusFound, resp.StatusCode)
+	utils.AssertEqual(t, []string{"", ""}, asked)
+
+	grants := func() (consents []*OauthConsent) {
+		req := httptest.NewRequest(http.MethodGet, "/grants", nil)
+		req.Header.Set("X-User", "user-1")
+		resp, err := app.Test(req)
+		utils.AssertEqual(t, nil, err)
+		utils.AssertEqual(t, http.StatusOK, resp.StatusCode)
+		utils.AssertEqual(t, nil, json.NewDecoder(resp.Body).Decode(&consents))
+		return
+	}
+	consents := grants()
+	utils.AssertEqual(t, 1, len(consents))
+	utils.AssertEqual(t, "web", consents[0].ClientID)
+	utils.AssertEqual(t, "profile", consents[0].Scope)
+
+	// revoking grant revokes tokens of the client
+	req := httptest.NewRequest(http.MethodDelete, "/grants/web", nil)
+	req.Header.Set("X-User", "user-1")
+	resp, err = app.Test(req)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, http.StatusNoContent, resp.StatusCode)
+	utils.AssertEqual(t, 0, len(grants()))
+	info, err := server.Validator().ValidateToken(ctx, token.AccessToken)
+	utils.AssertEqual(t, false, err == nil && info.Active)
+
+	resp, err = app.Test(httptest.NewRequest(http.MethodDelete, "/grants/web", nil))
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, http.StatusUnauthorized, resp.StatusCode)
+}
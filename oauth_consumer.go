@@ -0,0 +1,337 @@
+package helpers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/segmentio/encoding/json"
+)
+
+// OauthConsumerConfig config of OauthConsumer for external provider
+type OauthConsumerConfig struct {
+	ClientID     string
+	ClientSecret string
+
+	// AuthURL authorization endpoint of provider
+	AuthURL string
+
+	// TokenURL token endpoint of provider
+	TokenURL string
+
+	// RedirectURI callback registered with provider
+	RedirectURI string
+
+	// AuthMethod client authentication at token endpoint, client_secret_post or client_secret_basic
+	//
+	// Optional. Default: ClientSecretPost
+	AuthMethod ClientAuthMethod
+
+	// Scope default scope of authorization request
+	Scope string
+
+	// DisablePKCE omit code_challenge for providers rejecting it
+	//
+	// Optional. Default: false
+	DisablePKCE bool
+
+	// States manage state, nonce and PKCE verifier of authorization requests
+	//
+	// Optional. Default: NewOauthStateManager()
+	States *OauthStateManager
+
+	// HTTPClient call token endpoint
+	//
+	// Optional. Default: http.DefaultClient
+	HTTPClient *http.Client
+
+	// ExpiryDelta treat token as expired this long before ExpiresIn
+	//
+	// Optional. Default: 30 seconds
+	ExpiryDelta time.Duration
+}
+
+// OauthConsumerToken token issued by external provider
+type OauthConsumerToken struct {
+	AccessToken  string    `json:"access_token"`
+	TokenType    string    `json:"token_type,omitempty"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	IDToken      string    `json:"id_token,omitempty"`
+	Scope        string    `json:"scope,omitempty"`
+	Expiry       time.Time `json:"expiry,omitempty"`
+}
+
+// Valid report whether token has access token that does not expire within delta.
+func (t *OauthConsumerToken) Valid(delta time.Duration) bool {
+	if t == nil || t.AccessToken == "" {
+		return false
+	}
+	return t.Expiry.IsZero() || time.Now().Add(delta).Before(t.Expiry)
+}
+
+// OauthConsumer OAuth client to log in with external providers.
+type OauthConsumer struct {
+	cfg OauthConsumerConfig
+
+	mu          sync.Mutex
+	credentials map[string]*OauthConsumerToken
+}
+
+// NewOauthConsumer create OauthConsumer with defaults of unset config.
+func NewOauthConsumer(config OauthConsumerConfig) *OauthConsumer {
+	if config.States == nil {
+		config.States = NewOauthStateManager()
+	}
+	if config.HTTPClient == nil {
+		config.HTTPClient = http.DefaultClient
+	}
+	if config.ExpiryDelta <= 0 {
+		config.ExpiryDelta = 30 * time.Second
+	}
+	if config.AuthMethod == "" {
+		config.AuthMethod = ClientSecretPost
+	}
+	return &OauthConsumer{
+		cfg:         config,
+		credentials: make(map[string]*OauthConsumerToken),
+	}
+}
+
+// States return state manager of authorization requests.
+func (o *OauthConsumer) States() *OauthStateManager {
+	return o.cfg.States
+}
+
+// AuthorizationRequest return authorization code request of state.
+func (o *OauthConsumer) AuthorizationRequest(state *OauthState) OauthRequest {
+	req := OauthRequest{
+		APIKey:       o.cfg.ClientID,
+		ResponseType: string(ResponseTypeCode),
+		RedirectURI:  o.cfg.RedirectURI,
+		Scope:        o.cfg.Scope,
+		State:        state.State,
+	}
+	if state.RedirectURI != "" {
+		req.RedirectURI = state.RedirectURI
+	}
+	if ParseScope(req.Scope).Has(ScopeOpenID) {
+		req.Nonce = state.Nonce
+	}
+	if !o.cfg.DisablePKCE && state.CodeVerifier != "" {
+		req.CodeChallenge = PKCEChallenge(state.CodeVerifier, PKCES256)
+		req.CodeChallengeMethod = string(PKCES256)
+	}
+	return req
+}
+
+// AuthCodeURL build authorization url from OauthRequest fields,
+// req is usually result of AuthorizationRequest with extra fields set.
+func (o *OauthConsumer) AuthCodeURL(req OauthRequest) string {
+	sep := "?"
+	if strings.Contains(o.cfg.AuthURL, "?") {
+		sep = "&"
+	}
+	return o.cfg.AuthURL + sep + req.Values().Encode()
+}
+
+// Begin redirect user agent to provider with new state bound by cookie.
+func (o *OauthConsumer) Begin(c *fiber.Ctx) error {
+	state, err := o.cfg.States.Begin(c, o.cfg.RedirectURI)
+	if err != nil {
+		return err
+	}
+	return c.Redirect(o.AuthCodeURL(o.AuthorizationRequest(state)), http.StatusFound)
+}
+
+// Callback verify state of provider callback and exchange code for token,
+// nonce of ID token should be checked by state.VerifyNonce.
+func (o *OauthConsumer) Callback(c *fiber.Ctx) (token *OauthConsumerToken, state *OauthState, err error) {
+	if state, err = o.cfg.States.Callback(c); err != nil {
+		return
+	}
+	verifier := state.CodeVerifier
+	if o.cfg.DisablePKCE {
+		verifier = ""
+	}
+	token, err = o.Exchange(c.UserContext(), c.Query("code"), state.RedirectURI, verifier)
+	return
+}
+
+// Exchange authorization code for token.
+func (o *OauthConsumer) Exchange(ctx context.Context, code, redirectURI, codeVerifier string) (*OauthConsumerToken, error) {
+	if redirectURI == "" {
+		redirectURI = o.cfg.RedirectURI
+	}
+	return o.tokenRequest(ctx, OauthRequest{
+		GrantType:    string(GrantTypeCode),
+		Code:         code,
+		RedirectURI:  redirectURI,
+		CodeVerifier: codeVerifier,
+	})
+}
+
+// Refresh exchange refresh token for new token, refresh token is kept when provider does not rotate it.
+func (o *OauthConsumer) Refresh(ctx context.Context, refreshToken string) (token *OauthConsumerToken, err error) {
+	if token, err = o.tokenRequest(ctx, OauthRequest{
+		GrantType:    string(GrantTypeRefresh),
+		RefreshToken: refreshToken,
+	}); err != nil {
+		return
+	}
+	if token.RefreshToken == "" {
+		token.RefreshToken = refreshToken
+	}
+	return
+}
+
+// ClientCredentials return token of client_credentials grant,
+// cached per scope until ExpiryDelta before it expires.
+func (o *OauthConsumer) ClientCredentials(ctx context.Context, scope string) (token *OauthConsumerToken, err error) {
+	key := ParseScope(scope).String()
+	o.mu.Lock()
+	token = o.credentials[key]
+	o.mu.Unlock()
+	if token.Valid(o.cfg.ExpiryDelta) {
+		return
+	}
+
+	if token, err = o.tokenRequest(ctx, OauthRequest{
+		GrantType: string(GrantTypeClient),
+		Scope:     scope,
+	}); err != nil {
+		return
+	}
+	o.mu.Lock()
+	o.credentials[key] = token
+	o.mu.Unlock()
+	return
+}
+
+// TokenSource return OauthTokenSource that refreshes token transparently.
+func (o *OauthConsumer) TokenSource(token *OauthConsumerToken) *OauthTokenSource {
+	return &OauthTokenSource{consumer: o, token: token}
+}
+
+func (o *OauthConsumer) tokenRequest(ctx context.Context, oauthReq OauthRequest) (token *OauthConsumerToken, err error) {
+	if o.cfg.AuthMethod != ClientSecretBasic {
+		oauthReq.APIKey = o.cfg.ClientID
+		oauthReq.APISecret = o.cfg.ClientSecret
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.cfg.TokenURL, strings.NewReader(oauthReq.Values().Encode()))
+	if err != nil {
+		return
+	}
+	if o.cfg.AuthMethod == ClientSecretBasic {
+		req.SetBasicAuth(url.QueryEscape(o.cfg.ClientID), url.QueryEscape(o.cfg.ClientSecret))
+	}
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationForm)
+	req.Header.Set(fiber.HeaderAccept, fiber.MIMEApplicationJSON)
+
+	resp, err := o.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return
+	}
+
+	var oauthResp OauthResponse
+	if jsonErr := json.Unmarshal(body, &oauthResp); jsonErr != nil {
+		// some providers answer form encoded
+		values, formErr := url.ParseQuery(string(body))
+		if formErr != nil || (resp.StatusCode != http.StatusOK && values.Get("error") == "") {
+			return nil, fiber.NewError(http.StatusBadGateway, fmt.Sprintf("token endpoint returned %d", resp.StatusCode))
+		}
+		oauthResp = OauthResponse{
+			Error:        OauthErr(values.Get("error")),
+			ErrorDesc:    values.Get("error_description"),
+			ErrorURI:     values.Get("error_uri"),
+			AccessToken:  values.Get("access_token"),
+			IDToken:      values.Get("id_token"),
+			TokenType:    values.Get("token_type"),
+			RefreshToken: values.Get("refresh_token"),
+			Scope:        values.Get("scope"),
+		}
+		oauthResp.ExpiresIn, _ = strconv.Atoi(values.Get("expires_in"))
+	}
+
+	if oauthResp.Error != "" {
+		oauthErr := NewOauthError(oauthResp.Error, oauthResp.ErrorDesc)
+		oauthErr.URI = oauthResp.ErrorURI
+		oauthErr.Status = resp.StatusCode
+		return nil, oauthErr
+	}
+	if resp.StatusCode != http.StatusOK || oauthResp.AccessToken == "" {
+		return nil, fiber.NewError(http.StatusBadGateway, fmt.Sprintf("token endpoint returned %d without access_token", resp.StatusCode))
+	}
+
+	token = &OauthConsumerToken{
+		AccessToken:  oauthResp.AccessToken,
+		TokenType:    oauthResp.TokenType,
+		RefreshToken: oauthResp.RefreshToken,
+		IDToken:      oauthResp.IDToken,
+		Scope:        oauthResp.Scope,
+	}
+	if token.Scope == "" {
+		token.Scope = oauthReq.Scope
+	}
+	if oauthResp.ExpiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(oauthResp.ExpiresIn) * time.Second)
+	}
+	return
+}
+
+// OauthTokenSource hold token of user and refresh it when expired.
+type OauthTokenSource struct {
+	consumer *OauthConsumer
+
+	mu    sync.Mutex
+	token *OauthConsumerToken
+}
+
+// Token return valid token, refreshing it with refresh token when needed.
+func (s *OauthTokenSource) Token(ctx context.Context) (*OauthConsumerToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token.Valid(s.consumer.cfg.ExpiryDelta) {
+		return s.token, nil
+	}
+	if s.token == nil || s.token.RefreshToken == "" {
+		return nil, NewOauthError(InvalidGrant, "token expired without refresh_token")
+	}
+	token, err := s.consumer.Refresh(ctx, s.token.RefreshToken)
+	if err != nil {
+		return nil, err
+	}
+	s.token = token
+	return token, nil
+}
+
+// RoundTrip add bearer token to request, so OauthTokenSource can be
+// used as Transport of http.Client.
+func (s *OauthTokenSource) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := s.Token(req.Context())
+	if err != nil {
+		return nil, err
+	}
+	req = req.Clone(req.Context())
+	tokenType := token.TokenType
+	if tokenType == "" || strings.EqualFold(tokenType, "bearer") {
+		tokenType = "Bearer"
+	}
+	req.Header.Set(fiber.HeaderAuthorization, tokenType+" "+token.AccessToken)
+	transport := s.consumer.cfg.HTTPClient.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	return transport.RoundTrip(req)
+}
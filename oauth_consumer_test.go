@@ -0,0 +1,129 @@
+package helpers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+// fiberTransport serve client requests by fiber app without network.
+type fiberTransport struct {
+	app   *fiber.App
+	calls int32
+}
+
+func (t *fiberTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	atomic.AddInt32(&t.calls, 1)
+	return t.app.Test(req, -1)
+}
+
+func TestOauthConsumer(t *testing.T) {
+	t.Parallel()
+	app, _, _ := newTestOauthServer(t)
+	transport := &fiberTransport{app: app}
+	ctx := context.Background()
+
+	consumer := NewOauthConsumer(OauthConsumerConfig{
+		ClientID:     "web",
+		ClientSecret: "web-secret",
+		AuthURL:      "https://auth.example.com/authorize",
+		TokenURL:     "https://auth.example.com/token",
+		RedirectURI:  "https://client.example.com/cb",
+		Scope:        "profile",
+		HTTPClient:   &http.Client{Transport: transport},
+	})
+
+	state, err := consumer.States().New(ctx, "")
+	utils.AssertEqual(t, nil, err)
+	authURL, err := url.Parse(consumer.AuthCodeURL(consumer.AuthorizationRequest(state)))
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, string(PKCES256), authURL.Query().Get("code_challenge_method"))
+
+	location := oauthAuthorize(t, app, authURL.Query())
+	utils.AssertEqual(t, state.State, location.Query().Get("state"))
+
+	token, err := consumer.Exchange(ctx, location.Query().Get("code"), "", state.CodeVerifier)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, "profile", token.Scope)
+	utils.AssertEqual(t, true, token.Valid(0))
+
+	// expired token is refreshed transparently
+	token.Expiry = time.Now().Add(-time.Second)
+	source := consumer.TokenSource(token)
+	refreshed, err := source.Token(ctx)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, true, refreshed.AccessToken != token.AccessToken)
+
+	// provider errors are typed
+	_, err = consumer.Exchange(ctx, "bad-code", "", "")
+	utils.AssertEqual(t, InvalidGrant, AsOauthError(err).Err)
+	utils.AssertEqual(t, http.StatusBadRequest, AsOauthError(err).Status)
+
+	// client_credentials token is cached
+	calls := atomic.LoadInt32(&transport.calls)
+	first, err := consumer.ClientCredentials(ctx, "orders")
+	utils.AssertEqual(t, nil, err)
+	second, err := consumer.ClientCredentials(ctx, "orders")
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, first.AccessToken, second.AccessToken)
+	utils.AssertEqual(t, calls+1, atomic.LoadInt32(&transport.calls))
+
+	bad := NewOauthConsumer(OauthConsumerConfig{
+		ClientID:     "web",
+		ClientSecret: "wrong",
+		AuthMethod:   ClientSecretBasic,
+		TokenURL:     "https://auth.example.com/token",
+		HTTPClient:   &http.Client{Transport: transport},
+	})
+	_, err = bad.ClientCredentials(ctx, "")
+	utils.AssertEqual(t, InvalidClient, AsOauthError(err).Err)
+}
+
+func TestOauthConsumerCallback(t *testing.T) {
+	t.Parallel()
+
+	provider := fiber.New()
+	provider.Post("/token", func(c *fiber.Ctx) error {
+		if c.FormValue("code_verifier") == "" {
+			return c.Status(http.StatusBadRequest).JSON(NewOauthError(InvalidRequest).Response())
+		}
+		return c.JSON(OauthResponse{AccessToken: "at", TokenType: "Bearer", ExpiresIn: 3600})
+	})
+	consumer := NewOauthConsumer(OauthConsumerConfig{
+		ClientID:    "app",
+		AuthURL:     "https://provider.example.com/authorize",
+		TokenURL:    "https://provider.example.com/token",
+		RedirectURI: "https://app.example.com/cb",
+		HTTPClient:  &http.Client{Transport: &fiberTransport{app: provider}},
+	})
+
+	app := fiber.New()
+	app.Get("/login", consumer.Begin)
+	app.Get("/cb", func(c *fiber.Ctx) error {
+		token, _, err := consumer.Callback(c)
+		if err != nil {
+			return c.Status(http.StatusBadRequest).SendString(err.Error())
+		}
+		return c.SendString(token.AccessToken)
+	})
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/login", nil))
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, http.StatusFound, resp.StatusCode)
+	location, err := url.Parse(resp.Header.Get(fiber.HeaderLocation))
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, "provider.example.com", location.Host)
+
+	req := httptest.NewRequest(http.MethodGet, "/cb?code=abc&state="+location.Query().Get("state"), nil)
+	req.AddCookie(resp.Cookies()[0])
+	resp, err = app.Test(req)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, http.StatusOK, resp.StatusCode)
+}
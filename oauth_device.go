@@ -0,0 +1,341 @@
+package helpers
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// userCodeAlphabet base-20 consonants, no vowels to avoid words and no look-alike characters (RFC 8628 section 6.1)
+const userCodeAlphabet = "BCDFGHJKLMNPQRSTVWXZ"
+
+// DeviceStatus state of device authorization
+type DeviceStatus string
+
+// DeviceStatus constant
+const (
+	DevicePending  DeviceStatus = "pending"
+	DeviceApproved DeviceStatus = "approved"
+	DeviceDenied   DeviceStatus = "denied"
+)
+
+// OauthDeviceCode device authorization request (RFC 8628)
+type OauthDeviceCode struct {
+	DeviceCode   string        `json:"device_code"`
+	UserCode     string        `json:"user_code"`
+	ClientID     string        `json:"client_id"`
+	Scope        string        `json:"scope,omitempty"`
+	Status       DeviceStatus  `json:"status"`
+	UserID       string        `json:"user_id,omitempty"`
+	Interval     time.Duration `json:"interval"`
+	LastPolledAt time.Time     `json:"last_polled_at,omitempty"`
+	ExpiresAt    time.Time     `json:"expires_at"`
+}
+
+// OauthDeviceResponse device authorization response (RFC 8628 section 3.2)
+type OauthDeviceResponse struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete,omitempty"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval,omitempty"`
+}
+
+// OauthDeviceStore keep device authorization requests.
+//
+// Stores return fiber.ErrNotFound when device code does not exist or has expired.
+type OauthDeviceStore interface {
+	SaveDeviceCode(ctx context.Context, device *OauthDeviceCode) error
+	GetDeviceCode(ctx context.Context, deviceCode string) (*OauthDeviceCode, error)
+	GetDeviceCodeByUserCode(ctx context.Context, userCode string) (*OauthDeviceCode, error)
+	// UpdateDeviceCode replace device only while stored status is still from (compare-and-set),
+	// fiber.ErrNotFound when status was changed by concurrent decision or poll.
+	UpdateDeviceCode(ctx context.Context, device *OauthDeviceCode, from DeviceStatus) error
+	// ConsumeDeviceCode remove and return device code, only one of concurrent callers get it.
+	ConsumeDeviceCode(ctx context.Context, deviceCode string) (*OauthDeviceCode, error)
+	RemoveDeviceCode(ctx context.Context, deviceCode string) error
+}
+
+// GenerateUserCode create 8 chars user code formatted as XXXX-XXXX.
+func GenerateUserCode() (string, error) {
+	code := make([]byte, 8)
+	max := big.NewInt(int64(len(userCodeAlphabet)))
+	for i := range code {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		code[i] = userCodeAlphabet[n.Int64()]
+	}
+	return string(code[:4]) + "-" + string(code[4:]), nil
+}
+
+// NormalizeUserCode uppercase user input and drop separators, e.g. "bcdf ghjk" to "BCDFGHJK".
+func NormalizeUserCode(userCode string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		}
+		return -1
+	}, userCode)
+}
+
+// DeviceAuthorizationHandler handle device authorization endpoint (RFC 8628 section 3.1)
+func (s *OauthServer) DeviceAuthorizationHandler(c *fiber.Ctx) (err error) {
+	ctx := c.UserContext()
+	c.Set(fiber.HeaderCacheControl, "no-store")
+
+	var req OauthRequest
+	if err = parseOauthRequest(c, &req); err != nil {
+		return writeOauthError(c, NewOauthError(InvalidRequest, err.Error()))
+	}
+	client, oauthErr := s.authenticateClient(c, &req)
+	if oauthErr != nil {
+		return writeOauthError(c, oauthErr)
+	}
+	if !client.AllowGrant(GrantTypeDevice) {
+		return writeOauthError(c, NewOauthError(UnauthorizedClient, "client may not use device authorization"))
+	}
+	if !scopeAllowed(req.Scope, client.Scope) {
+		return writeOauthError(c, NewOauthError(InvalidScope))
+	}
+
+	device := &OauthDeviceCode{
+		ClientID:  client.ID,
+		Scope:     req.Scope,
+		Status:    DevicePending,
+		Interval:  s.cfg.DevicePollInterval,
+		ExpiresAt: time.Now().Add(s.cfg.DeviceCodeTTL),
+	}
+	if device.DeviceCode, err = RandomHash(); err != nil {
+		return writeOauthError(c, AsOauthError(err))
+	}
+	if device.UserCode, err = GenerateUserCode(); err != nil {
+		return writeOauthError(c, AsOauthError(err))
+	}
+	if err = s.cfg.Devices.SaveDeviceCode(ctx, device); err != nil {
+		return writeOauthError(c, AsOauthError(err))
+	}
+
+	return c.JSON(OauthDeviceResponse{
+		DeviceCode:              device.DeviceCode,
+		UserCode:                device.UserCode,
+		VerificationURI:         s.cfg.VerificationURI,
+		VerificationURIComplete: s.cfg.VerificationURI + "?" + url.Values{"user_code": {device.UserCode}}.Encode(),
+		ExpiresIn:               int(s.cfg.DeviceCodeTTL.Seconds()),
+		Interval:                int(device.Interval.Seconds()),
+	})
+}
+
+// DeviceVerifyHandler handle verification page, GET let VerifyDevice hook render user code form,
+// POST pass device of user_code from form to hook to approve or deny it.
+func (s *OauthServer) DeviceVerifyHandler(c *fiber.Ctx) (err error) {
+	ctx := c.UserContext()
+	cc := Ctx{c}
+
+	// decision is only taken from POST, so cross site link or image can not approve device
+	if c.Method() != fiber.MethodPost {
+		_, err = s.cfg.VerifyDevice(c, nil)
+		return
+	}
+
+	userCode := cc.FormValueTrim("user_code")
+	if userCode == "" {
+		userCode = strings.TrimSpace(c.Query("user_code"))
+	}
+
+	var device *OauthDeviceCode
+	if userCode != "" {
+		if device, err = s.cfg.Devices.GetDeviceCodeByUserCode(ctx, userCode); err != nil || device.Status != DevicePending {
+			device = nil
+		}
+	}
+	if device == nil && userCode != "" {
+		return NewError(http.StatusNotFound, "invalid or expired user_code")
+	}
+
+	// nil device let hook render user code input form
+	userID, err := s.cfg.VerifyDevice(c, device)
+	if device == nil || (userID == "" && err == nil) {
+		return err
+	}
+	if err != nil {
+		if AsOauthError(err).Err != AccessDenied {
+			return err
+		}
+		if err = s.DenyDevice(ctx, device.UserCode); err != nil {
+			return err
+		}
+		return c.JSON(ResponseForm{Success: false, Messages: []string{"device denied"}})
+	}
+	if err = s.ApproveDevice(ctx, device.UserCode, userID); err != nil {
+		return err
+	}
+	return c.JSON(ResponseForm{Success: true, Messages: []string{"device approved"}})
+}
+
+// ApproveDevice grant pending device authorization of user code to user.
+func (s *OauthServer) ApproveDevice(ctx context.Context, userCode, userID string) error {
+	return s.decideDevice(ctx, userCode, DeviceApproved, userID)
+}
+
+// DenyDevice reject pending device authorization of user code.
+func (s *OauthServer) DenyDevice(ctx context.Context, userCode string) error {
+	return s.decideDevice(ctx, userCode, DeviceDenied, "")
+}
+
+func (s *OauthServer) decideDevice(ctx context.Context, userCode string, status DeviceStatus, userID string) error {
+	device, err := s.cfg.Devices.GetDeviceCodeByUserCode(ctx, userCode)
+	if err != nil || device.Status != DevicePending {
+		return NewError(http.StatusNotFound, "invalid or expired user_code")
+	}
+	device.Status = status
+	device.UserID = userID
+	// decision made concurrently wins, this one is not saved over it
+	if err = s.cfg.Devices.UpdateDeviceCode(ctx, device, DevicePending); errors.Is(err, fiber.ErrNotFound) {
+		return NewError(http.StatusNotFound, "invalid or expired user_code")
+	}
+	return err
+}
+
+// grantDevice poll of device code at token endpoint (RFC 8628 section 3.4)
+func (s *OauthServer) grantDevice(ctx context.Context, client *OauthClient, req *OauthRequest) (token *OauthToken, err error) {
+	if req.DeviceCode == "" {
+		return nil, NewOauthError(InvalidRequest, "missing device_code")
+	}
+	device, err := s.cfg.Devices.GetDeviceCode(ctx, req.DeviceCode)
+	if err != nil {
+		return nil, NewOauthError(InvalidGrant, "invalid device_code")
+	}
+	if device.ClientID != client.ID {
+		return nil, NewOauthError(InvalidGrant, "device_code was issued to another client")
+	}
+
+	now := time.Now()
+	if now.After(device.ExpiresAt) {
+		_ = s.cfg.Devices.RemoveDeviceCode(ctx, device.DeviceCode)
+		return nil, NewOauthError(ExpiredToken)
+	}
+
+	switch device.Status {
+	case DeviceDenied:
+		_ = s.cfg.Devices.RemoveDeviceCode(ctx, device.DeviceCode)
+		return nil, NewOauthError(AccessDenied)
+	case DeviceApproved:
+		// concurrent polls of approved code race here, only the one consuming it get token
+		if device, err = s.cfg.Devices.ConsumeDeviceCode(ctx, device.DeviceCode); errors.Is(err, fiber.ErrNotFound) {
+			return nil, NewOauthError(InvalidGrant, "device_code was already used")
+		} else if err != nil {
+			return
+		}
+		if token, err = s.issueToken(ctx, client.ID, device.UserID, device.Scope, true); err != nil {
+			return
+		}
+		err = s.issueIDToken(token, "", now)
+		return
+	}
+
+	tooFast := !device.LastPolledAt.IsZero() && now.Sub(device.LastPolledAt) < device.Interval
+	device.LastPolledAt = now
+	if tooFast {
+		device.Interval += 5 * time.Second
+	}
+	// approval or denial saved since device was read is kept, it is returned on next poll
+	if err = s.cfg.Devices.UpdateDeviceCode(ctx, device, DevicePending); errors.Is(err, fiber.ErrNotFound) {
+		return nil, NewOauthError(AuthorizationPending)
+	} else if err != nil {
+		return
+	}
+	if tooFast {
+		return nil, NewOauthError(SlowDown)
+	}
+	return nil, NewOauthError(AuthorizationPending)
+}
+
+func (s *MemoryOauthStore) SaveDeviceCode(ctx context.Context, device *OauthDeviceCode) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sweepExpired(time.Now())
+	if old, ok := s.deviceCodes[device.DeviceCode]; ok {
+		delete(s.userCodes, NormalizeUserCode(old.UserCode))
+	}
+	record := *device
+	s.deviceCodes[device.DeviceCode] = &record
+	s.userCodes[NormalizeUserCode(device.UserCode)] = device.DeviceCode
+	return nil
+}
+
+func (s *MemoryOauthStore) GetDeviceCode(ctx context.Context, deviceCode string) (*OauthDeviceCode, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	device, ok := s.deviceCodes[deviceCode]
+	if !ok || time.Now().After(device.ExpiresAt) {
+		return nil, fiber.ErrNotFound
+	}
+	record := *device
+	return &record, nil
+}
+
+func (s *MemoryOauthStore) GetDeviceCodeByUserCode(ctx context.Context, userCode string) (*OauthDeviceCode, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	device, ok := s.deviceCodes[s.userCodes[NormalizeUserCode(userCode)]]
+	if !ok || time.Now().After(device.ExpiresAt) {
+		return nil, fiber.ErrNotFound
+	}
+	record := *device
+	return &record, nil
+}
+
+func (s *MemoryOauthStore) UpdateDeviceCode(ctx context.Context, device *OauthDeviceCode, from DeviceStatus) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current, ok := s.deviceCodes[device.DeviceCode]
+	if !ok || current.Status != from || time.Now().After(current.ExpiresAt) {
+		return fiber.ErrNotFound
+	}
+	record := *device
+	record.UserCode = current.UserCode
+	s.deviceCodes[device.DeviceCode] = &record
+	return nil
+}
+
+func (s *MemoryOauthStore) ConsumeDeviceCode(ctx context.Context, deviceCode string) (*OauthDeviceCode, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	device, ok := s.deviceCodes[deviceCode]
+	if !ok {
+		return nil, fiber.ErrNotFound
+	}
+	s.removeDeviceCode(deviceCode)
+	if time.Now().After(device.ExpiresAt) {
+		return nil, fiber.ErrNotFound
+	}
+	return device, nil
+}
+
+func (s *MemoryOauthStore) RemoveDeviceCode(ctx context.Context, deviceCode string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.removeDeviceCode(deviceCode)
+	return nil
+}
+
+// removeDeviceCode delete device code and its user code index, caller must hold lock.
+func (s *MemoryOauthStore) removeDeviceCode(deviceCode string) {
+	if device, ok := s.deviceCodes[deviceCode]; ok {
+		delete(s.userCodes, NormalizeUserCode(device.UserCode))
+		delete(s.deviceCodes, deviceCode)
+	}
+}
@@ -0,0 +1,228 @@
+package helpers
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// DPoP header and proof type (RFC 9449)
+const (
+	HeaderDPoP  = "DPoP"
+	JWTTypeDPoP = "dpop+jwt"
+)
+
+// JWTConfirmation cnf claim, key the token is bound to (RFC 7800)
+type JWTConfirmation struct {
+	// JKT JWK SHA-256 thumbprint of DPoP key (RFC 9449 section 6.1)
+	JKT string `json:"jkt,omitempty"`
+}
+
+// DPoPClaims claims of DPoP proof (RFC 9449 section 4.2)
+type DPoPClaims struct {
+	ID       string `json:"jti"`
+	Method   string `json:"htm"`
+	URI      string `json:"htu"`
+	IssuedAt int64  `json:"iat"`
+	// AccessTokenHash hash of access token presented with proof to resource server
+	AccessTokenHash string `json:"ath,omitempty"`
+}
+
+// DPoPConfig defines the config for DPoPVerifier.
+type DPoPConfig struct {
+	// Algorithms accepted for proof signature
+	//
+	// Optional. Default: RS256, ES256, EdDSA
+	Algorithms []JWTAlgorithm
+
+	// MaxAge reject proofs with older iat, jti are remembered this long
+	//
+	// Optional. Default: 5 minutes
+	MaxAge time.Duration
+
+	// Leeway accept iat in the future for clock skew
+	//
+	// Optional. Default: 5 seconds
+	Leeway time.Duration
+}
+
+// DPoPVerifier validate DPoP proofs and reject replayed proofs.
+type DPoPVerifier struct {
+	cfg     DPoPConfig
+	replays *replayCache
+}
+
+// NewDPoPVerifier create DPoPVerifier with defaults of unset config.
+func NewDPoPVerifier(config ...DPoPConfig) *DPoPVerifier {
+	var cfg DPoPConfig
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+	if len(cfg.Algorithms) == 0 {
+		cfg.Algorithms = []JWTAlgorithm{RS256, ES256, EdDSA}
+	}
+	if cfg.MaxAge <= 0 {
+		cfg.MaxAge = 5 * time.Minute
+	}
+	if cfg.Leeway <= 0 {
+		cfg.Leeway = 5 * time.Second
+	}
+	return &DPoPVerifier{cfg: cfg, replays: newReplayCache()}
+}
+
+// Algorithms return accepted proof algorithms, e.g. for dpop_signing_alg_values_supported.
+func (v *DPoPVerifier) Algorithms() []JWTAlgorithm {
+	return v.cfg.Algorithms
+}
+
+// Verify check proof of request method and uri (RFC 9449 section 4.3) and return thumbprint of proof key.
+// accessToken is the token presented with proof to resource server, empty at token endpoint.
+// Failure is *OauthError invalid_dpop_proof.
+func (v *DPoPVerifier) Verify(proof, method, uri, accessToken string) (jkt string, err error) {
+	claims := new(DPoPClaims)
+	if _, err = VerifyJWT(proof, claims, func(header JWTHeader) (*JWTKey, error) {
+		switch {
+		case header.Typ != JWTTypeDPoP:
+			return nil, fmt.Errorf("unexpected typ: %s", header.Typ)
+		case !v.allowAlgorithm(header.Alg):
+			return nil, fmt.Errorf("unsupported alg: %s", header.Alg)
+		case header.JWK == nil:
+			return nil, fmt.Errorf("missing jwk")
+		}
+		jwk := *header.JWK
+		jwk.Alg = string(header.Alg)
+		key, err := NewJWTKeyFromJWK(jwk)
+		if err != nil {
+			return nil, err
+		}
+		if jkt, err = jwk.Thumbprint(); err != nil {
+			return nil, err
+		}
+		return key, nil
+	}); err != nil {
+		return "", NewOauthError(InvalidDPoPProof, err.Error())
+	}
+
+	now := time.Now()
+	issuedAt := time.Unix(claims.IssuedAt, 0)
+	switch {
+	case claims.ID == "":
+		return "", NewOauthError(InvalidDPoPProof, "missing jti")
+	case claims.Method != method:
+		return "", NewOauthError(InvalidDPoPProof, "htm mismatch")
+	case dpopURI(claims.URI) == "" || dpopURI(claims.URI) != dpopURI(uri):
+		return "", NewOauthError(InvalidDPoPProof, "htu mismatch")
+	case claims.IssuedAt == 0 || issuedAt.Before(now.Add(-v.cfg.MaxAge)) || issuedAt.After(now.Add(v.cfg.Leeway)):
+		return "", NewOauthError(InvalidDPoPProof, "iat out of range")
+	case accessToken != "" && subtle.ConstantTimeCompare([]byte(claims.AccessTokenHash), []byte(DPoPAccessTokenHash(accessToken))) != 1:
+		return "", NewOauthError(InvalidDPoPProof, "ath mismatch")
+	}
+	if !v.replays.Add(jkt+" "+claims.ID, issuedAt.Add(v.cfg.MaxAge+v.cfg.Leeway)) {
+		return "", NewOauthError(InvalidDPoPProof, "replayed proof")
+	}
+	return
+}
+
+func (v *DPoPVerifier) allowAlgorithm(alg JWTAlgorithm) bool {
+	for _, allowed := range v.cfg.Algorithms {
+		if alg == allowed && alg != HS256 {
+			return true
+		}
+	}
+	return false
+}
+
+// NewDPoPProof create proof of request signed by client key, accessToken is set when calling resource server.
+func NewDPoPProof(key *JWTKey, method, uri, accessToken string) (proof string, err error) {
+	jwk, err := key.JWK()
+	if err != nil {
+		return
+	}
+	jwk.Kid, jwk.Use, jwk.Alg = "", "", ""
+	jti, err := UUIDv4()
+	if err != nil {
+		return
+	}
+	claims := DPoPClaims{
+		ID:       jti,
+		Method:   method,
+		URI:      uri,
+		IssuedAt: time.Now().Unix(),
+	}
+	if accessToken != "" {
+		claims.AccessTokenHash = DPoPAccessTokenHash(accessToken)
+	}
+	return key.SignWithHeader(JWTHeader{Typ: JWTTypeDPoP, JWK: &jwk}, claims)
+}
+
+// DPoPAccessTokenHash return ath of access token.
+func DPoPAccessTokenHash(accessToken string) string {
+	sum := sha256.Sum256([]byte(accessToken))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// dpopURI normalize htu, query and fragment are ignored (RFC 9449 section 4.3)
+func dpopURI(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || !u.IsAbs() || u.Host == "" {
+		return ""
+	}
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	return strings.ToLower(u.Scheme) + "://" + strings.ToLower(u.Host) + path
+}
+
+// dpopRequestURI return htu of current request.
+func dpopRequestURI(c *fiber.Ctx) string {
+	return c.BaseURL() + string(c.Request().URI().PathOriginal())
+}
+
+type dpopKeyContext struct{}
+
+// withDPoPKey bind tokens issued within ctx to thumbprint of proof key.
+func withDPoPKey(ctx context.Context, jkt string) context.Context {
+	return context.WithValue(ctx, dpopKeyContext{}, jkt)
+}
+
+// dpopKey return thumbprint of verified proof key, empty without proof.
+func dpopKey(ctx context.Context) string {
+	jkt, _ := ctx.Value(dpopKeyContext{}).(string)
+	return jkt
+}
+
+// verifyDPoPAccess check authorization scheme against token binding (RFC 9449 section 7.1),
+// DPoP bound token requires DPoP scheme with proof of the same key,
+// token without binding may not be presented with DPoP scheme.
+func verifyDPoPAccess(c *fiber.Ctx, verifier *DPoPVerifier, auth HttpAuth, cnf *JWTConfirmation) *OauthError {
+	bound := cnf != nil && cnf.JKT != ""
+	if auth.Type != DPoPToken {
+		if bound {
+			return NewOauthError(InvalidToken, "DPoP bound token requires DPoP scheme")
+		}
+		return nil
+	}
+	if !bound {
+		return NewOauthError(InvalidToken, "token is not DPoP bound")
+	}
+	proof := c.Get(HeaderDPoP)
+	if proof == "" {
+		return NewOauthError(InvalidDPoPProof, "missing DPoP proof")
+	}
+	jkt, err := verifier.Verify(proof, c.Method(), dpopRequestURI(c), auth.Token)
+	if err != nil {
+		return AsOauthError(err)
+	}
+	if jkt != cnf.JKT {
+		return NewOauthError(InvalidToken, "DPoP key mismatch")
+	}
+	return nil
+}
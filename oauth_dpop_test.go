@@ -0,0 +1,125 @@
+package helpers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestOauthServerDPoP(t *testing.T) {
+	t.Parallel()
+	app, server, _ := newTestOauthServer(t)
+	app.Get("/orders", OauthResource(OauthResourceConfig{Validator: server.Validator()}), func(c *fiber.Ctx) error {
+		return c.SendString((&Ctx{c}).TokenInfo().Sub)
+	})
+	app.Get("/userinfo", server.UserInfoHandler)
+	key, err := GenerateJWTKey(ES256)
+	utils.AssertEqual(t, nil, err)
+	otherKey, err := GenerateJWTKey(EdDSA)
+	utils.AssertEqual(t, nil, err)
+
+	tokenRequest := func(key *JWTKey, method string, form url.Values) (int, OauthResponse) {
+		proof, err := NewDPoPProof(key, method, "http://example.com/token", "")
+		utils.AssertEqual(t, nil, err)
+		return oauthTokenRequest(t, app, form, HeaderDPoP, proof)
+	}
+	passwordForm := url.Values{
+		"grant_type": {"password"},
+		"client_id":  {"spa"},
+		"username":   {"alice"},
+		"password":   {"secret"},
+		"scope":      {"openid"},
+	}
+
+	status, resp := tokenRequest(key, http.MethodPost, passwordForm)
+	utils.AssertEqual(t, http.StatusOK, status, resp.ErrorDesc)
+	utils.AssertEqual(t, "DPoP", resp.TokenType)
+
+	status, bad := tokenRequest(key, http.MethodGet, passwordForm)
+	utils.AssertEqual(t, http.StatusBadRequest, status)
+	utils.AssertEqual(t, InvalidDPoPProof, bad.Error)
+
+	resource := func(path, scheme string, proofKey *JWTKey, uri string) *http.Response {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set(fiber.HeaderAuthorization, scheme+" "+resp.AccessToken)
+		if proofKey != nil {
+			proof, err := NewDPoPProof(proofKey, http.MethodGet, uri, resp.AccessToken)
+			utils.AssertEqual(t, nil, err)
+			req.Header.Set(HeaderDPoP, proof)
+		}
+		httpResp, err := app.Test(req)
+		utils.AssertEqual(t, nil, err)
+		return httpResp
+	}
+	utils.AssertEqual(t, http.StatusOK, resource("/orders", "DPoP", key, "http://example.com/orders").StatusCode)
+
+	httpResp := resource("/orders", "Bearer", nil, "")
+	utils.AssertEqual(t, http.StatusUnauthorized, httpResp.StatusCode)
+	utils.AssertEqual(t, `Bearer error="invalid_token", error_description="DPoP bound token requires DPoP scheme"`, httpResp.Header.Get(fiber.HeaderWWWAuthenticate))
+
+	httpResp = resource("/orders", "DPoP", otherKey, "http://example.com/orders")
+	utils.AssertEqual(t, http.StatusUnauthorized, httpResp.StatusCode)
+	utils.AssertEqual(t, `DPoP error="invalid_token", error_description="DPoP key mismatch", algs="RS256 ES256 EdDSA"`, httpResp.Header.Get(fiber.HeaderWWWAuthenticate))
+
+	httpResp = resource("/orders", "DPoP", key, "http://example.com/other")
+	utils.AssertEqual(t, http.StatusUnauthorized, httpResp.StatusCode)
+	utils.AssertEqual(t, `DPoP error="invalid_dpop_proof", error_description="htu mismatch", algs="RS256 ES256 EdDSA"`, httpResp.Header.Get(fiber.HeaderWWWAuthenticate))
+
+	// userinfo accept DPoP scheme and reject bound token presented as Bearer
+	utils.AssertEqual(t, http.StatusOK, resource("/userinfo", "DPoP", key, "http://example.com/userinfo").StatusCode)
+	httpResp = resource("/userinfo", "Bearer", nil, "")
+	utils.AssertEqual(t, http.StatusUnauthorized, httpResp.StatusCode)
+	utils.AssertEqual(t, `Bearer realm="https://auth.example.com", error="invalid_token", error_description="DPoP bound token requires DPoP scheme"`, httpResp.Header.Get(fiber.HeaderWWWAuthenticate))
+	httpResp = resource("/userinfo", "DPoP", otherKey, "http://example.com/userinfo")
+	utils.AssertEqual(t, `DPoP realm="https://auth.example.com", error="invalid_token", error_description="DPoP key mismatch", algs="RS256 ES256 EdDSA"`, httpResp.Header.Get(fiber.HeaderWWWAuthenticate))
+
+	// refresh token of public client needs proof of the same key
+	refreshForm := url.Values{
+		"grant_type":    {"refresh_token"},
+		"client_id":     {"spa"},
+		"refresh_token": {resp.RefreshToken},
+	}
+	status, bad = tokenRequest(otherKey, http.MethodPost, refreshForm)
+	utils.AssertEqual(t, InvalidGrant, bad.Error)
+	status, refreshed := tokenRequest(key, http.MethodPost, refreshForm)
+	utils.AssertEqual(t, http.StatusOK, status, refreshed.ErrorDesc)
+	utils.AssertEqual(t, "DPoP", refreshed.TokenType)
+}
+
+func TestDPoPVerifier(t *testing.T) {
+	t.Parallel()
+	verifier := NewDPoPVerifier()
+	key, err := GenerateJWTKey(RS256)
+	utils.AssertEqual(t, nil, err)
+	jwk, err := key.JWK()
+	utils.AssertEqual(t, nil, err)
+	thumbprint, err := jwk.Thumbprint()
+	utils.AssertEqual(t, nil, err)
+
+	proof, err := NewDPoPProof(key, http.MethodPost, "https://server.example.com/token?x=1", "")
+	utils.AssertEqual(t, nil, err)
+	jkt, err := verifier.Verify(proof, http.MethodPost, "https://SERVER.example.com/token", "")
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, thumbprint, jkt)
+
+	_, err = verifier.Verify(proof, http.MethodPost, "https://server.example.com/token", "")
+	utils.AssertEqual(t, "replayed proof", AsOauthError(err).Description)
+
+	// proof must carry hash of presented access token
+	proof, err = NewDPoPProof(key, http.MethodGet, "https://server.example.com/api", "token-a")
+	utils.AssertEqual(t, nil, err)
+	_, err = verifier.Verify(proof, http.MethodGet, "https://server.example.com/api", "token-b")
+	utils.AssertEqual(t, "ath mismatch", AsOauthError(err).Description)
+
+	// symmetric keys are never accepted
+	secret, err := GenerateJWTKey(HS256)
+	utils.AssertEqual(t, nil, err)
+	proof, err = secret.SignWithHeader(JWTHeader{Typ: JWTTypeDPoP, JWK: &jwk}, DPoPClaims{ID: "1", Method: http.MethodGet, URI: "https://server.example.com/api"})
+	utils.AssertEqual(t, nil, err)
+	_, err = verifier.Verify(proof, http.MethodGet, "https://server.example.com/api", "")
+	utils.AssertEqual(t, InvalidDPoPProof, AsOauthError(err).Err)
+}
@@ -0,0 +1,167 @@
+package helpers
+
+import (
+	"context"
+	"net/url"
+	"time"
+)
+
+// Token type identifier (RFC 8693 section 3)
+const (
+	TokenTypeAccessToken  = "urn:ietf:params:oauth:token-type:access_token"
+	TokenTypeRefreshToken = "urn:ietf:params:oauth:token-type:refresh_token"
+	TokenTypeIDToken      = "urn:ietf:params:oauth:token-type:id_token"
+	TokenTypeJWT          = "urn:ietf:params:oauth:token-type:jwt"
+)
+
+// TokenExchangeRequest validated token exchange request passed to TokenExchangePolicy.
+type TokenExchangeRequest struct {
+	Client *OauthClient
+	// Subject token info, the party the new token represents
+	Subject *OauthTokenInfo
+	// Actor token info, nil for impersonation
+	Actor *OauthTokenInfo
+	// Audience and Resource of new token, the policy may narrow them
+	Audience []string
+	Resource []string
+	// Scope of new token, the policy may narrow it
+	Scope string
+}
+
+// TokenExchangePolicy decide whether client may exchange subject token,
+// return *OauthError, e.g. invalid_target, to reject, other errors are unauthorized_client.
+type TokenExchangePolicy func(ctx context.Context, req *TokenExchangeRequest) error
+
+// grantTokenExchange exchange subject token for token of another audience (RFC 8693)
+func (s *OauthServer) grantTokenExchange(ctx context.Context, client *OauthClient, req *OauthRequest) (token *OauthToken, err error) {
+	switch {
+	case req.SubjectToken == "" || req.SubjectTokenType == "":
+		return nil, NewOauthError(InvalidRequest, "missing subject_token or subject_token_type")
+	case req.ActorToken != "" && req.ActorTokenType == "":
+		return nil, NewOauthError(InvalidRequest, "missing actor_token_type")
+	case req.ActorToken == "" && req.ActorTokenType != "":
+		return nil, NewOauthError(InvalidRequest, "actor_token_type without actor_token")
+	}
+	issuedType := req.RequestedTokenType
+	switch issuedType {
+	case "":
+		issuedType = TokenTypeAccessToken
+	case TokenTypeAccessToken:
+	case TokenTypeJWT:
+		if s.cfg.Keys == nil {
+			return nil, NewOauthError(InvalidRequest, "unsupported requested_token_type")
+		}
+	default:
+		return nil, NewOauthError(InvalidRequest, "unsupported requested_token_type")
+	}
+	for _, resource := range req.Resource {
+		// resource must be absolute uri without fragment (RFC 8707 section 2)
+		if u, err := url.Parse(resource); err != nil || !u.IsAbs() || u.Fragment != "" {
+			return nil, NewOauthError(InvalidTarget, "invalid resource")
+		}
+	}
+
+	subject, err := s.exchangeTokenInfo(ctx, req.SubjectToken, req.SubjectTokenType)
+	if err != nil {
+		return nil, exchangeTokenError(err, "invalid subject_token")
+	}
+	exchange := &TokenExchangeRequest{
+		Client:   client,
+		Subject:  subject,
+		Audience: req.Audience,
+		Resource: req.Resource,
+	}
+	if req.ActorToken != "" {
+		if exchange.Actor, err = s.exchangeTokenInfo(ctx, req.ActorToken, req.ActorTokenType); err != nil {
+			return nil, exchangeTokenError(err, "invalid actor_token")
+		}
+	}
+	scope, err := ParseScope(subject.Scope).Downscope(req.Scope)
+	if err != nil {
+		return
+	}
+	if !scopeAllowed(scope.String(), client.Scope) {
+		return nil, NewOauthError(InvalidScope)
+	}
+	exchange.Scope = scope.String()
+
+	if err = s.cfg.TokenExchangePolicy(ctx, exchange); err != nil {
+		oauthErr := AsOauthError(err)
+		if oauthErr.Err == ServerError {
+			oauthErr = NewOauthError(UnauthorizedClient, err.Error())
+		}
+		return nil, oauthErr
+	}
+
+	if token, err = s.newToken(ctx, client.ID, subject.Sub, exchange.Scope, func(token *OauthToken) {
+		token.Audience = append(append([]string{}, exchange.Audience...), exchange.Resource...)
+		if exchange.Actor != nil {
+			token.Actor = &JWTActor{
+				Subject:  exchange.Actor.Sub,
+				Issuer:   exchange.Actor.Iss,
+				ClientID: exchange.Actor.ClientID,
+				// prior actors of subject token are nested (RFC 8693 section 4.1)
+				Act: subject.Act,
+			}
+		}
+		token.IssuedTokenType = issuedType
+	}); err != nil {
+		return
+	}
+	err = s.cfg.Tokens.SaveToken(ctx, token)
+	return
+}
+
+// exchangeTokenInfo validate subject or actor token of type issued by the server.
+func (s *OauthServer) exchangeTokenInfo(ctx context.Context, value, tokenType string) (info *OauthTokenInfo, err error) {
+	switch tokenType {
+	case TokenTypeAccessToken:
+		info, err = s.Validator().ValidateToken(ctx, value)
+	case TokenTypeRefreshToken:
+		var token *OauthToken
+		if token, err = s.cfg.Tokens.GetRefreshToken(ctx, value); err == nil {
+			info = token.Info(s.cfg.Issuer)
+			info.Scope = token.GrantScope()
+			// refresh token outlives access token of the same record
+			info.Exp = 0
+			if !token.RefreshExpiresAt.IsZero() {
+				info.Exp = token.RefreshExpiresAt.Unix()
+			}
+		}
+	case TokenTypeIDToken, TokenTypeJWT:
+		if s.cfg.Keys == nil {
+			return nil, NewOauthError(InvalidRequest, "unsupported token type")
+		}
+		claims := new(JWTClaims)
+		if _, err = s.cfg.Keys.Verify(value, claims); err == nil {
+			err = claims.Validate(s.cfg.Issuer, "", 0)
+		}
+		if err == nil {
+			info = claims.Info()
+		}
+	default:
+		return nil, NewOauthError(InvalidRequest, "unsupported token type "+tokenType)
+	}
+	if err != nil {
+		return
+	}
+	if info == nil || !info.Active || (info.Exp != 0 && time.Now().After(time.Unix(info.Exp, 0))) {
+		return nil, NewOauthError(InvalidGrant, "inactive token")
+	}
+	// DPoP bound token is only exchanged with proof of its key (RFC 9449 section 5)
+	if info.Cnf != nil && info.Cnf.JKT != "" && info.Cnf.JKT != dpopKey(ctx) {
+		return nil, NewOauthError(InvalidGrant, "DPoP key mismatch")
+	}
+	if info.Sub == "" {
+		info.Sub = info.ClientID
+	}
+	return
+}
+
+// exchangeTokenError keep invalid_request of unsupported token type, other failures are invalid_grant.
+func exchangeTokenError(err error, description string) *OauthError {
+	if oauthErr := AsOauthError(err); oauthErr.Err == InvalidRequest {
+		return oauthErr
+	}
+	return NewOauthError(InvalidGrant, description)
+}
@@ -0,0 +1,192 @@
+package helpers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestOauthServerTokenExchange(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	store := NewMemoryOauthStore()
+	utils.AssertEqual(t, nil, store.SaveClient(ctx, &OauthClient{ID: "gateway", Secret: "gateway-secret"}))
+	utils.AssertEqual(t, nil, store.SaveClient(ctx, &OauthClient{ID: "orders", Secret: "orders-secret"}))
+	utils.AssertEqual(t, nil, store.SaveClient(ctx, &OauthClient{ID: "spa", Public: true}))
+	key, err := GenerateJWTKey(ES256)
+	utils.AssertEqual(t, nil, err)
+	keys := NewJWTKeySet(key)
+
+	server := NewOauthServer(OauthServerConfig{
+		Issuer:  "https://auth.example.com",
+		Clients: store,
+		Tokens:  store,
+		Keys:    keys,
+		AuthenticateUser: func(ctx context.Context, username, password string) (string, error) {
+			return "user-1", nil
+		},
+		TokenExchangePolicy: func(ctx context.Context, req *TokenExchangeRequest) error {
+			if req.Client.ID == "spa" {
+				return fiber.ErrForbidden
+			}
+			for _, aud := range req.Audience {
+				if aud != "orders" && aud != "billing" {
+					return NewOauthError(InvalidTarget, "audience not allowed")
+				}
+			}
+			return nil
+		},
+	})
+	app := fiber.New()
+	server.Register(app)
+
+	_, resp := oauthTokenRequest(t, app, url.Values{
+		"grant_type": {"password"},
+		"client_id":  {"spa"},
+		"username":   {"alice"},
+		"password":   {"secret"},
+		"scope":      {"orders:read profile"},
+	})
+	userToken := resp.AccessToken
+	_, resp = oauthTokenRequest(t, app, url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {"gateway"},
+		"client_secret": {"gateway-secret"},
+	})
+	gatewayToken := resp.AccessToken
+
+	exchange := func(clientID, subject, actor, audience, scope string) (int, OauthResponse) {
+		form := url.Values{
+			"grant_type":         {string(GrantTypeTokenExchange)},
+			"client_id":          {clientID},
+			"subject_token":      {subject},
+			"subject_token_type": {TokenTypeAccessToken},
+			"audience":           {audience},
+			"scope":              {scope},
+		}
+		if clientID != "spa" {
+			form.Set("client_secret", clientID+"-secret")
+		}
+		if actor != "" {
+			form.Set("actor_token", actor)
+			form.Set("actor_token_type", TokenTypeAccessToken)
+		}
+		return oauthTokenRequest(t, app, form)
+	}
+
+	status, resp := exchange("gateway", userToken, gatewayToken, "orders", "orders:read")
+	utils.AssertEqual(t, http.StatusOK, status, resp.ErrorDesc)
+	utils.AssertEqual(t, TokenTypeAccessToken, resp.IssuedTokenType)
+	utils.AssertEqual(t, "orders:read", resp.Scope)
+
+	delegated := resp.AccessToken
+
+	claims := new(JWTClaims)
+	_, err = keys.Verify(delegated, claims)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, "user-1", claims.Subject)
+	utils.AssertEqual(t, JWTAudience{"orders"}, claims.Audience)
+	utils.AssertEqual(t, "gateway", claims.Act.Subject)
+
+	// resource server accept only tokens of its audience
+	for audience, expected := range map[string]int{"orders": http.StatusOK, "billing": http.StatusUnauthorized} {
+		resource := fiber.New()
+		resource.Get("/", OauthResource(OauthResourceConfig{Validator: server.Validator(), Audience: audience}), func(c *fiber.Ctx) error {
+			return c.SendStatus(http.StatusOK)
+		})
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(fiber.HeaderAuthorization, "Bearer "+delegated)
+		httpResp, err := resource.Test(req)
+		utils.AssertEqual(t, nil, err)
+		utils.AssertEqual(t, expected, httpResp.StatusCode, audience)
+	}
+
+	// orders service calls billing on behalf of user, gateway is prior actor
+	_, resp = oauthTokenRequest(t, app, url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {"orders"},
+		"client_secret": {"orders-secret"},
+	})
+	status, resp = exchange("orders", delegated, resp.AccessToken, "billing", "")
+	utils.AssertEqual(t, http.StatusOK, status, resp.ErrorDesc)
+	claims = new(JWTClaims)
+	_, err = keys.Verify(resp.AccessToken, claims)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, "orders", claims.Act.Subject)
+	utils.AssertEqual(t, "gateway", claims.Act.Act.Subject)
+
+	status, resp = exchange("gateway", userToken, "", "payroll", "")
+	utils.AssertEqual(t, http.StatusBadRequest, status)
+	utils.AssertEqual(t, InvalidTarget, resp.Error)
+
+	status, resp = exchange("gateway", userToken, "", "orders", "admin")
+	utils.AssertEqual(t, InvalidScope, resp.Error)
+
+	status, resp = exchange("spa", userToken, "", "orders", "")
+	utils.AssertEqual(t, http.StatusBadRequest, status)
+	utils.AssertEqual(t, UnauthorizedClient, resp.Error)
+
+	status, resp = exchange("gateway", "bogus", "", "orders", "")
+	utils.AssertEqual(t, InvalidGrant, resp.Error)
+
+	// DPoP bound subject token is exchanged only with proof of its key
+	boundKey, err := GenerateJWTKey(ES256)
+	utils.AssertEqual(t, nil, err)
+	dpopProof := func(key *JWTKey) string {
+		proof, err := NewDPoPProof(key, http.MethodPost, "http://example.com/token", "")
+		utils.AssertEqual(t, nil, err)
+		return proof
+	}
+	status, resp = oauthTokenRequest(t, app, url.Values{
+		"grant_type": {"password"},
+		"client_id":  {"spa"},
+		"username":   {"alice"},
+		"password":   {"secret"},
+		"scope":      {"orders:read"},
+	}, HeaderDPoP, dpopProof(boundKey))
+	utils.AssertEqual(t, http.StatusOK, status, resp.ErrorDesc)
+	boundForm := url.Values{
+		"grant_type":         {string(GrantTypeTokenExchange)},
+		"client_id":          {"gateway"},
+		"client_secret":      {"gateway-secret"},
+		"subject_token":      {resp.AccessToken},
+		"subject_token_type": {TokenTypeAccessToken},
+		"audience":           {"orders"},
+	}
+	status, resp = oauthTokenRequest(t, app, boundForm)
+	utils.AssertEqual(t, http.StatusBadRequest, status)
+	utils.AssertEqual(t, InvalidGrant, resp.Error)
+	otherKey, err := GenerateJWTKey(ES256)
+	utils.AssertEqual(t, nil, err)
+	_, resp = oauthTokenRequest(t, app, boundForm, HeaderDPoP, dpopProof(otherKey))
+	utils.AssertEqual(t, InvalidGrant, resp.Error)
+	status, resp = oauthTokenRequest(t, app, boundForm, HeaderDPoP, dpopProof(boundKey))
+	utils.AssertEqual(t, http.StatusOK, status, resp.ErrorDesc)
+
+	// refresh token is valid until its own expiry, not expiry of access token
+	utils.AssertEqual(t, nil, store.SaveToken(ctx, &OauthToken{
+		AccessToken:      "expired-access",
+		RefreshToken:     "live-refresh",
+		ClientID:         "spa",
+		UserID:           "user-1",
+		Scope:            "orders:read",
+		IssuedAt:         time.Now().Add(-time.Hour),
+		ExpiresAt:        time.Now().Add(-time.Minute),
+		RefreshExpiresAt: time.Now().Add(time.Hour),
+	}))
+	status, resp = oauthTokenRequest(t, app, url.Values{
+		"grant_type":         {string(GrantTypeTokenExchange)},
+		"client_id":          {"gateway"},
+		"client_secret":      {"gateway-secret"},
+		"subject_token":      {"live-refresh"},
+		"subject_token_type": {TokenTypeRefreshToken},
+		"audience":           {"orders"},
+	})
+	utils.AssertEqual(t, http.StatusOK, status, resp.ErrorDesc)
+}
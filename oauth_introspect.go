@@ -0,0 +1,377 @@
+package helpers
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/segmentio/encoding/json"
+)
+
+// Token type hint (RFC 7009)
+const (
+	TokenTypeHintAccess  = "access_token"
+	TokenTypeHintRefresh = "refresh_token"
+)
+
+// OauthTokenInfo validated token, also the introspection response (RFC 7662 section 2.2)
+type OauthTokenInfo struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	Username  string `json:"username,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	Exp       int64  `json:"exp,omitempty"`
+	Iat       int64  `json:"iat,omitempty"`
+	Nbf       int64  `json:"nbf,omitempty"`
+	Sub       string `json:"sub,omitempty"`
+	Aud       string `json:"aud,omitempty"`
+	Iss       string `json:"iss,omitempty"`
+	Jti       string `json:"jti,omitempty"`
+	// Act acting party of delegated token (RFC 8693 section 4.1)
+	Act *JWTActor `json:"act,omitempty"`
+	// Cnf key the token is bound to, e.g. DPoP (RFC 9449 section 6.2)
+	Cnf *JWTConfirmation `json:"cnf,omitempty"`
+}
+
+// HasAudience report whether token is intended for audience, e.g. identifier of resource server.
+func (i *OauthTokenInfo) HasAudience(audience string) bool {
+	for _, aud := range strings.Fields(i.Aud) {
+		if aud == audience {
+			return true
+		}
+	}
+	return false
+}
+
+// Info return introspection of token record.
+func (t *OauthToken) Info(issuer string) *OauthTokenInfo {
+	return &OauthTokenInfo{
+		Active:    true,
+		Scope:     t.Scope,
+		ClientID:  t.ClientID,
+		TokenType: t.TokenType,
+		Exp:       t.ExpiresAt.Unix(),
+		Iat:       t.IssuedAt.Unix(),
+		Sub:       t.UserID,
+		Aud:       strings.Join(t.Audience, " "),
+		Iss:       issuer,
+		Act:       t.Actor,
+		Cnf:       t.Confirmation(),
+	}
+}
+
+// IntrospectHandler handle token introspection endpoint (RFC 7662)
+func (s *OauthServer) IntrospectHandler(c *fiber.Ctx) (err error) {
+	ctx := c.UserContext()
+	c.Set(fiber.HeaderCacheControl, "no-store")
+
+	var req OauthRequest
+	if err = parseOauthRequest(c, &req); err != nil {
+		return writeOauthError(c, NewOauthError(InvalidRequest, err.Error()))
+	}
+	if _, oauthErr := s.authenticateClient(c, &req); oauthErr != nil {
+		return writeOauthError(c, oauthErr)
+	}
+	if req.Token == "" {
+		return writeOauthError(c, NewOauthError(InvalidRequest, "missing token"))
+	}
+
+	token, isRefresh := s.lookupToken(ctx, req.Token, req.TokenTypeHint)
+	if token == nil {
+		return c.JSON(OauthTokenInfo{Active: false})
+	}
+	info := token.Info(s.cfg.Issuer)
+	if isRefresh {
+		info.TokenType = TokenTypeHintRefresh
+		info.Scope = token.GrantScope()
+		if !token.RefreshExpiresAt.IsZero() {
+			info.Exp = token.RefreshExpiresAt.Unix()
+		}
+	}
+	return c.JSON(info)
+}
+
+// RevokeHandler handle token revocation endpoint (RFC 7009)
+//
+// Revoking either token of a grant removes both access and refresh token.
+func (s *OauthServer) RevokeHandler(c *fiber.Ctx) (err error) {
+	ctx := c.UserContext()
+
+	var req OauthRequest
+	if err = parseOauthRequest(c, &req); err != nil {
+		return writeOauthError(c, NewOauthError(InvalidRequest, err.Error()))
+	}
+	client, oauthErr := s.authenticateClient(c, &req)
+	if oauthErr != nil {
+		return writeOauthError(c, oauthErr)
+	}
+	if req.Token == "" {
+		return writeOauthError(c, NewOauthError(InvalidRequest, "missing token"))
+	}
+
+	// invalid tokens do not cause an error response (RFC 7009 section 2.2)
+	token, isRefresh := s.lookupToken(ctx, req.Token, req.TokenTypeHint)
+	if token == nil {
+		return c.Status(http.StatusOK).Send(nil)
+	}
+	if token.ClientID != client.ID {
+		return writeOauthError(c, NewOauthError(UnauthorizedClient, "token was issued to another client"))
+	}
+	// revoking refresh token also revokes tokens of the same grant (RFC 7009 section 2.1)
+	if isRefresh && token.FamilyID != "" && s.cfg.Families != nil {
+		err = s.cfg.Families.RevokeFamily(ctx, token.FamilyID)
+	} else {
+		err = s.cfg.Tokens.RemoveToken(ctx, token)
+	}
+	if err != nil {
+		return writeOauthError(c, AsOauthError(err))
+	}
+	return c.Status(http.StatusOK).Send(nil)
+}
+
+// lookupToken find token record by access or refresh token, hint is tried first.
+func (s *OauthServer) lookupToken(ctx context.Context, value, hint string) (token *OauthToken, isRefresh bool) {
+	if hint != TokenTypeHintRefresh {
+		if token, err := s.cfg.Tokens.GetAccessToken(ctx, value); err == nil {
+			return token, false
+		}
+	}
+	if token, err := s.cfg.Tokens.GetRefreshToken(ctx, value); err == nil {
+		return token, true
+	}
+	if hint == TokenTypeHintRefresh {
+		if token, err := s.cfg.Tokens.GetAccessToken(ctx, value); err == nil {
+			return token, false
+		}
+	}
+	return nil, false
+}
+
+// OauthTokenValidator validate access token for resource server.
+//
+// Return nil error with inactive info when token is unknown or expired.
+type OauthTokenValidator interface {
+	ValidateToken(ctx context.Context, token string) (*OauthTokenInfo, error)
+}
+
+// StoreTokenValidator validate access token against local token store.
+type StoreTokenValidator struct {
+	Tokens OauthTokenStore
+	Issuer string
+}
+
+func (v StoreTokenValidator) ValidateToken(ctx context.Context, value string) (*OauthTokenInfo, error) {
+	token, err := v.Tokens.GetAccessToken(ctx, value)
+	if err != nil {
+		return &OauthTokenInfo{Active: false}, nil
+	}
+	return token.Info(v.Issuer), nil
+}
+
+// Validator return OauthTokenValidator backed by the server token store.
+func (s *OauthServer) Validator() OauthTokenValidator {
+	return StoreTokenValidator{Tokens: s.cfg.Tokens, Issuer: s.cfg.Issuer}
+}
+
+// RemoteIntrospector validate access token by calling introspection endpoint,
+// active results are cached for CacheTTL but never beyond token expiry.
+type RemoteIntrospector struct {
+	Endpoint     string
+	ClientID     string
+	ClientSecret string
+	HTTPClient   *http.Client
+	// Default 1 minute, negative value disables cache.
+	CacheTTL time.Duration
+	// CacheSize maximum cached tokens, least recently used are evicted
+	//
+	// Optional. Default: 10000
+	CacheSize int
+
+	mu      sync.Mutex
+	cache   map[string]*list.Element
+	lru     *list.List
+	sweptAt time.Time
+}
+
+type introspectCache struct {
+	key       string
+	info      *OauthTokenInfo
+	expiresAt time.Time
+}
+
+func (r *RemoteIntrospector) ValidateToken(ctx context.Context, value string) (info *OauthTokenInfo, err error) {
+	sum := sha256.Sum256([]byte(value))
+	key := hex.EncodeToString(sum[:])
+	now := time.Now()
+
+	r.mu.Lock()
+	if elem, ok := r.cache[key]; ok {
+		if cached := elem.Value.(*introspectCache); now.Before(cached.expiresAt) {
+			r.lru.MoveToFront(elem)
+			r.mu.Unlock()
+			return cached.info, nil
+		}
+		r.lru.Remove(elem)
+		delete(r.cache, key)
+	}
+	r.mu.Unlock()
+
+	if info, err = r.introspect(ctx, value); err != nil {
+		return
+	}
+
+	ttl := r.CacheTTL
+	if ttl == 0 {
+		ttl = time.Minute
+	}
+	// inactive results are not cached, random tokens must not fill the cache
+	if ttl < 0 || !info.Active {
+		return
+	}
+	expiresAt := now.Add(ttl)
+	if info.Exp != 0 && time.Unix(info.Exp, 0).Before(expiresAt) {
+		expiresAt = time.Unix(info.Exp, 0)
+	}
+	r.store(key, info, expiresAt, ttl)
+	return
+}
+
+// store cache result, expired entries are swept once per ttl and
+// least recently used entry is evicted when cache is full.
+func (r *RemoteIntrospector) store(key string, info *OauthTokenInfo, expiresAt time.Time, ttl time.Duration) {
+	size := r.CacheSize
+	if size <= 0 {
+		size = 10000
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cache == nil {
+		r.cache = make(map[string]*list.Element)
+		r.lru = list.New()
+	}
+	now := time.Now()
+	if now.Sub(r.sweptAt) >= ttl {
+		r.sweptAt = now
+		for k, elem := range r.cache {
+			if !now.Before(elem.Value.(*introspectCache).expiresAt) {
+				r.lru.Remove(elem)
+				delete(r.cache, k)
+			}
+		}
+	}
+	if elem, ok := r.cache[key]; ok {
+		r.lru.Remove(elem)
+		delete(r.cache, key)
+	}
+	for r.lru.Len() >= size {
+		oldest := r.lru.Back()
+		r.lru.Remove(oldest)
+		delete(r.cache, oldest.Value.(*introspectCache).key)
+	}
+	r.cache[key] = r.lru.PushFront(&introspectCache{key: key, info: info, expiresAt: expiresAt})
+}
+
+func (r *RemoteIntrospector) introspect(ctx context.Context, value string) (info *OauthTokenInfo, err error) {
+	form := url.Values{
+		"token":           {value},
+		"token_type_hint": {TokenTypeHintAccess},
+		"client_id":       {r.ClientID},
+		"client_secret":   {r.ClientSecret},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.Endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return
+	}
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationForm)
+	req.Header.Set(fiber.HeaderAccept, fiber.MIMEApplicationJSON)
+
+	httpClient := r.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		err = fiber.NewError(http.StatusBadGateway, fmt.Sprintf("introspection endpoint returned %d", resp.StatusCode))
+		return
+	}
+	info = new(OauthTokenInfo)
+	err = json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(info)
+	return
+}
+
+// OauthResourceConfig defines the config for OauthResource middleware.
+type OauthResourceConfig struct {
+	// Next defines a function to skip this middleware when returned true.
+	Next func(c *fiber.Ctx) bool
+
+	Validator OauthTokenValidator
+
+	// Realm of WWW-Authenticate challenge
+	Realm string
+
+	// Audience identifier of this resource server, tokens without it in aud are rejected.
+	// Any audience is accepted when empty.
+	Audience string
+
+	// DPoP validate proofs of DPoP bound tokens
+	//
+	// Optional. Default: NewDPoPVerifier()
+	DPoP *DPoPVerifier
+}
+
+const localsOauthTokenInfo = "helpers.oauth_token_info"
+
+// OauthResource creates a middleware that requires valid bearer or DPoP access token,
+// the token info is available by Ctx.TokenInfo.
+func OauthResource(config OauthResourceConfig) fiber.Handler {
+	if config.DPoP == nil {
+		config.DPoP = NewDPoPVerifier()
+	}
+	return func(c *fiber.Ctx) error {
+		if config.Next != nil && config.Next(c) {
+			return c.Next()
+		}
+
+		auth, err := ExtractAuthString(c.Get(fiber.HeaderAuthorization))
+		if err != nil || (auth.Type != BearerToken && auth.Type != DPoPToken) {
+			return resourceError(c, config.Realm, "", config.DPoP, nil)
+		}
+
+		info, err := config.Validator.ValidateToken(c.UserContext(), auth.Token)
+		if err != nil {
+			return fiber.NewError(http.StatusServiceUnavailable, "token validation unavailable")
+		}
+		if !info.Active || (info.Exp != 0 && time.Now().Unix() >= info.Exp) {
+			return resourceError(c, config.Realm, auth.Type, config.DPoP, NewOauthError(InvalidToken, "invalid or expired token"))
+		}
+		if config.Audience != "" && !info.HasAudience(config.Audience) {
+			return resourceError(c, config.Realm, auth.Type, config.DPoP, NewOauthError(InvalidToken, "token is not intended for this audience"))
+		}
+		if oauthErr := verifyDPoPAccess(c, config.DPoP, auth, info.Cnf); oauthErr != nil {
+			return resourceError(c, config.Realm, auth.Type, config.DPoP, oauthErr)
+		}
+
+		c.Locals(localsOauthTokenInfo, info)
+		return c.Next()
+	}
+}
+
+// TokenInfo returns access token validated by OauthResource middleware, nil when not present.
+func (c *Ctx) TokenInfo() *OauthTokenInfo {
+	info, _ := c.Locals(localsOauthTokenInfo).(*OauthTokenInfo)
+	return info
+}
@@ -0,0 +1,180 @@
+package helpers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RequestURIPrefix prefix of request_uri issued by pushed authorization endpoint (RFC 9126 section 2.2)
+const RequestURIPrefix = "urn:ietf:params:oauth:request_uri:"
+
+// OauthPushedRequest authorization request pushed by authenticated client (RFC 9126)
+type OauthPushedRequest struct {
+	RequestURI string       `json:"request_uri"`
+	ClientID   string       `json:"client_id"`
+	Request    OauthRequest `json:"request"`
+	ExpiresAt  time.Time    `json:"expires_at"`
+}
+
+// OauthPushedResponse pushed authorization response (RFC 9126 section 2.2)
+type OauthPushedResponse struct {
+	RequestURI string `json:"request_uri"`
+	ExpiresIn  int    `json:"expires_in"`
+}
+
+// OauthPushedRequestStore keep pushed authorization requests until used or expired.
+type OauthPushedRequestStore interface {
+	SavePushedRequest(ctx context.Context, req *OauthPushedRequest) error
+	// GetPushedRequest return unexpired request, it is read again when user reloads authorize page.
+	GetPushedRequest(ctx context.Context, requestURI string) (*OauthPushedRequest, error)
+	RemovePushedRequest(ctx context.Context, requestURI string) error
+}
+
+// PushedAuthorizationHandler handle pushed authorization request endpoint (RFC 9126 section 2)
+func (s *OauthServer) PushedAuthorizationHandler(c *fiber.Ctx) (err error) {
+	ctx := c.UserContext()
+	c.Set(fiber.HeaderCacheControl, "no-store")
+
+	var req OauthRequest
+	if err = parseOauthRequest(c, &req); err != nil {
+		return writeOauthError(c, NewOauthError(InvalidRequest, err.Error()))
+	}
+	client, oauthErr := s.authenticateClient(c, &req)
+	if oauthErr != nil {
+		return writeOauthError(c, oauthErr)
+	}
+	if req.RequestURI != "" {
+		return writeOauthError(c, NewOauthError(InvalidRequest, "request_uri must not be pushed"))
+	}
+	if req.Request != "" {
+		if oauthErr = s.resolveRequestObject(ctx, client, &req); oauthErr != nil {
+			return writeOauthError(c, oauthErr)
+		}
+	}
+
+	// same validation as authorize endpoint, errors are returned to client directly
+	if _, _, oauthErr = s.authorizeClient(ctx, &req); oauthErr != nil {
+		return writeOauthError(c, oauthErr)
+	}
+	if oauthErr = s.validateAuthorize(client, &req); oauthErr != nil {
+		return writeOauthError(c, oauthErr)
+	}
+
+	// client credentials are not part of authorization request
+	req.APISecret, req.ClientAssertion, req.ClientAssertionType = "", "", ""
+	pushed := &OauthPushedRequest{
+		ClientID:  client.ID,
+		Request:   req,
+		ExpiresAt: time.Now().Add(s.cfg.PushedRequestTTL),
+	}
+	random, err := RandomHash()
+	if err != nil {
+		return writeOauthError(c, AsOauthError(err))
+	}
+	pushed.RequestURI = RequestURIPrefix + random
+	if err = s.cfg.PushedRequests.SavePushedRequest(ctx, pushed); err != nil {
+		return writeOauthError(c, AsOauthError(err))
+	}
+
+	return c.Status(http.StatusCreated).JSON(OauthPushedResponse{
+		RequestURI: pushed.RequestURI,
+		ExpiresIn:  int(s.cfg.PushedRequestTTL.Seconds()),
+	})
+}
+
+// resolveAuthorizeRequest replace authorize parameters by pushed request (RFC 9126 section 4)
+// or by signed request object (RFC 9101 section 5), the result is validated as plain request.
+func (s *OauthServer) resolveAuthorizeRequest(ctx context.Context, req *OauthRequest) *OauthError {
+	switch {
+	case req.Request != "" && req.RequestURI != "":
+		return NewOauthError(InvalidRequest, "request and request_uri must not both be present")
+	case req.RequestURI != "":
+		if !strings.HasPrefix(req.RequestURI, RequestURIPrefix) {
+			return NewOauthError(InvalidRequestURI, "unsupported request_uri")
+		}
+		pushed, err := s.cfg.PushedRequests.GetPushedRequest(ctx, req.RequestURI)
+		if err != nil {
+			return NewOauthError(InvalidRequestURI, "invalid or expired request_uri")
+		}
+		if pushed.ClientID != req.APIKey {
+			return NewOauthError(InvalidRequestURI, "request_uri was pushed by another client")
+		}
+		requestURI := req.RequestURI
+		*req = pushed.Request
+		req.RequestURI = requestURI
+	case s.cfg.RequirePushedRequests:
+		return NewOauthError(InvalidRequest, "pushed authorization request required")
+	case req.Request != "":
+		client, err := s.cfg.Clients.GetClient(ctx, req.APIKey)
+		if err != nil {
+			oauthErr := NewOauthError(InvalidClient, "unknown client")
+			oauthErr.Status = http.StatusBadRequest
+			return oauthErr
+		}
+		return s.resolveRequestObject(ctx, client, req)
+	}
+	return nil
+}
+
+// resolveRequestObject verify request object signed by client keys (RFC 9101 section 6),
+// only parameters inside request object are used.
+func (s *OauthServer) resolveRequestObject(ctx context.Context, client *OauthClient, req *OauthRequest) *OauthError {
+	keys, err := s.clientKeys(ctx, client)
+	if err != nil {
+		return NewOauthError(InvalidRequestObject, "client keys unavailable")
+	}
+	var claims JWTClaims
+	if _, err = VerifyJWT(req.Request, &claims, clientKeyFunc(keys)); err != nil {
+		return NewOauthError(InvalidRequestObject, "invalid request object signature")
+	}
+	if err = claims.Validate(client.ID, s.cfg.Issuer, time.Minute); err != nil {
+		return NewOauthError(InvalidRequestObject, err.Error())
+	}
+
+	var object OauthRequest
+	if _, err = ParseJWT(req.Request, &object); err != nil {
+		return NewOauthError(InvalidRequestObject, err.Error())
+	}
+	if object.APIKey != "" && object.APIKey != client.ID {
+		return NewOauthError(InvalidRequestObject, "client_id mismatch")
+	}
+	if object.Request != "" || object.RequestURI != "" {
+		return NewOauthError(InvalidRequestObject, "nested request object")
+	}
+	object.APIKey = client.ID
+	*req = object
+	return nil
+}
+
+func (s *MemoryOauthStore) SavePushedRequest(ctx context.Context, req *OauthPushedRequest) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sweepExpired(time.Now())
+	s.pushed[req.RequestURI] = req
+	return nil
+}
+
+func (s *MemoryOauthStore) GetPushedRequest(ctx context.Context, requestURI string) (*OauthPushedRequest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record, ok := s.pushed[requestURI]
+	if !ok {
+		return nil, fiber.ErrNotFound
+	}
+	if time.Now().After(record.ExpiresAt) {
+		delete(s.pushed, requestURI)
+		return nil, fiber.ErrNotFound
+	}
+	return record, nil
+}
+
+func (s *MemoryOauthStore) RemovePushedRequest(ctx context.Context, requestURI string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.pushed, requestURI)
+	return nil
+}
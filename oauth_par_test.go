@@ -0,0 +1,144 @@
+package helpers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/segmentio/encoding/json"
+)
+
+func TestOauthServerPushedAuthorization(t *testing.T) {
+	t.Parallel()
+	app, _, _ := newTestOauthServer(t)
+
+	var pushed OauthPushedResponse
+	httpResp, err := app.Test(oauthFormRequest("/par", url.Values{
+		"client_id":     {"web"},
+		"client_secret": {"web-secret"},
+		"response_type": {"code"},
+		"state":         {"abc"},
+	}))
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, http.StatusCreated, httpResp.StatusCode)
+	utils.AssertEqual(t, nil, json.NewDecoder(httpResp.Body).Decode(&pushed))
+	utils.AssertEqual(t, 60, pushed.ExpiresIn)
+
+	// query parameters other than client_id are taken from pushed request
+	location := oauthAuthorize(t, app, url.Values{
+		"client_id":   {"web"},
+		"request_uri": {pushed.RequestURI},
+		"state":       {"ignored"},
+	})
+	utils.AssertEqual(t, "abc", location.Query().Get("state"))
+	utils.AssertEqual(t, true, location.Query().Get("code") != "")
+
+	// request_uri is removed once code is issued
+	httpResp, err = app.Test(httptest.NewRequest(http.MethodGet, "/authorize?"+url.Values{
+		"client_id":   {"web"},
+		"request_uri": {pushed.RequestURI},
+	}.Encode(), nil))
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, http.StatusBadRequest, httpResp.StatusCode)
+
+	// pushed requests are validated before request_uri is issued
+	status, resp := oauthTokenRequestTo(t, app, "/par", url.Values{
+		"client_id":     {"web"},
+		"client_secret": {"web-secret"},
+		"response_type": {"code"},
+		"redirect_uri":  {"https://evil.example.com/cb"},
+	})
+	utils.AssertEqual(t, http.StatusBadRequest, status)
+	utils.AssertEqual(t, InvalidRequest, resp.Error)
+
+	status, resp = oauthTokenRequestTo(t, app, "/par", url.Values{
+		"client_id":     {"web"},
+		"client_secret": {"wrong"},
+		"response_type": {"code"},
+	})
+	utils.AssertEqual(t, http.StatusUnauthorized, status)
+	utils.AssertEqual(t, InvalidClient, resp.Error)
+}
+
+func TestOauthServerRequestObject(t *testing.T) {
+	t.Parallel()
+	app, _, store := newTestOauthServer(t)
+	key, err := GenerateJWTKey(ES256)
+	utils.AssertEqual(t, nil, err)
+	jwk, err := key.JWK()
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, nil, store.SaveClient(context.Background(), &OauthClient{
+		ID:           "jar",
+		AuthMethod:   PrivateKeyJWT,
+		JWKS:         &JWKSet{Keys: []JWK{jwk}},
+		RedirectURIs: []string{"https://jar.example.com/cb"},
+	}))
+
+	requestObject := func(key *JWTKey, claims map[string]interface{}) string {
+		base := map[string]interface{}{
+			"iss":           "jar",
+			"aud":           "https://auth.example.com",
+			"exp":           time.Now().Add(time.Minute).Unix(),
+			"client_id":     "jar",
+			"response_type": "code",
+			"redirect_uri":  "https://jar.example.com/cb",
+			"state":         "signed",
+		}
+		for k, v := range claims {
+			base[k] = v
+		}
+		token, err := key.Sign(base, "oauth-authz-req+jwt")
+		utils.AssertEqual(t, nil, err)
+		return token
+	}
+	authorize := func(request string) *http.Response {
+		httpResp, err := app.Test(httptest.NewRequest(http.MethodGet, "/authorize?"+url.Values{
+			"client_id": {"jar"},
+			"request":   {request},
+			"state":     {"unsigned"},
+		}.Encode(), nil))
+		utils.AssertEqual(t, nil, err)
+		return httpResp
+	}
+
+	httpResp := authorize(requestObject(key, nil))
+	utils.AssertEqual(t, http.StatusFound, httpResp.StatusCode)
+	location, err := url.Parse(httpResp.Header.Get("Location"))
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, "signed", location.Query().Get("state"))
+
+	otherKey, err := GenerateJWTKey(ES256)
+	utils.AssertEqual(t, nil, err)
+	for _, request := range []string{
+		requestObject(otherKey, nil),
+		requestObject(key, map[string]interface{}{"aud": "https://other.example.com"}),
+		requestObject(key, map[string]interface{}{"iss": "web"}),
+		requestObject(key, map[string]interface{}{"client_id": "web"}),
+	} {
+		httpResp = authorize(request)
+		utils.AssertEqual(t, http.StatusBadRequest, httpResp.StatusCode)
+		var resp OauthResponse
+		utils.AssertEqual(t, nil, json.NewDecoder(httpResp.Body).Decode(&resp))
+		utils.AssertEqual(t, InvalidRequestObject, resp.Error)
+	}
+
+	// request object may be pushed by client authenticated with private_key_jwt
+	assertion, err := key.Sign(JWTClaims{
+		Issuer:    "jar",
+		Subject:   "jar",
+		Audience:  JWTAudience{"https://auth.example.com"},
+		ExpiresAt: time.Now().Add(time.Minute).Unix(),
+		ID:        "assertion-1",
+	})
+	utils.AssertEqual(t, nil, err)
+	status, resp := oauthTokenRequestTo(t, app, "/par", url.Values{
+		"client_assertion_type": {ClientAssertionTypeJWT},
+		"client_assertion":      {assertion},
+		"request":               {requestObject(key, nil)},
+	})
+	utils.AssertEqual(t, http.StatusCreated, status, resp.ErrorDesc)
+}
@@ -0,0 +1,59 @@
+package helpers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+)
+
+// PKCEMethod code challenge method (RFC 7636)
+type PKCEMethod string
+
+// PKCEMethod constant
+const (
+	PKCEPlain PKCEMethod = "plain"
+	PKCES256  PKCEMethod = "S256"
+)
+
+// GeneratePKCEVerifier create random code verifier of 43 chars.
+func GeneratePKCEVerifier() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// PKCEChallenge derive code challenge from verifier.
+func PKCEChallenge(verifier string, method PKCEMethod) string {
+	if method == PKCES256 {
+		sum := sha256.Sum256([]byte(verifier))
+		return base64.RawURLEncoding.EncodeToString(sum[:])
+	}
+	return verifier
+}
+
+// VerifyPKCE check verifier against challenge in constant time.
+func VerifyPKCE(verifier, challenge string, method PKCEMethod) bool {
+	if !validPKCEValue(verifier) {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(PKCEChallenge(verifier, method)), []byte(challenge)) == 1
+}
+
+// validPKCEValue check length and unreserved characters of verifier or challenge.
+func validPKCEValue(value string) bool {
+	if len(value) < 43 || len(value) > 128 {
+		return false
+	}
+	for _, r := range value {
+		switch {
+		case r >= 'A' && r <= 'Z', r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+		case r == '-', r == '.', r == '_', r == '~':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,89 @@
+package helpers
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// ValidateRedirectURI check redirect uri for client registration.
+//
+// It must be absolute without fragment or user info, and use https,
+// http on loopback address (RFC 8252 section 7.3) or a private-use scheme
+// of native app such as com.example.app:/callback (RFC 8252 section 7.1).
+func ValidateRedirectURI(redirectURI string) error {
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		return NewOauthError(InvalidRequest, fmt.Sprintf("invalid redirect_uri: %s", err.Error()))
+	}
+	switch {
+	case !u.IsAbs():
+		return NewOauthError(InvalidRequest, "redirect_uri must be absolute")
+	case u.Fragment != "" || strings.Contains(redirectURI, "#"):
+		return NewOauthError(InvalidRequest, "redirect_uri must not contain fragment")
+	case u.User != nil:
+		return NewOauthError(InvalidRequest, "redirect_uri must not contain user info")
+	}
+	switch u.Scheme {
+	case "https":
+		if u.Host == "" {
+			return NewOauthError(InvalidRequest, "redirect_uri missing host")
+		}
+	case "http":
+		if !isLoopbackHost(u.Hostname()) {
+			return NewOauthError(InvalidRequest, "http redirect_uri is allowed for loopback address only")
+		}
+	case "javascript", "data", "file", "vbscript":
+		return NewOauthError(InvalidRequest, "redirect_uri scheme not allowed")
+	default:
+		// private-use scheme must be reverse domain name
+		if !strings.Contains(u.Scheme, ".") {
+			return NewOauthError(InvalidRequest, "private-use redirect_uri scheme must be reverse domain name")
+		}
+	}
+	return nil
+}
+
+// MatchRedirectURI find registered redirect uri matching requested one.
+//
+// Matching is exact string comparison, except loopback http uris
+// where any port is accepted (RFC 8252 section 7.3).
+func MatchRedirectURI(registered []string, requested string) (matched string, ok bool) {
+	if strings.Contains(requested, "#") {
+		return
+	}
+	for _, uri := range registered {
+		if uri == requested {
+			return uri, true
+		}
+	}
+
+	reqURL, err := url.Parse(requested)
+	if err != nil || reqURL.Scheme != "http" || reqURL.User != nil || !isLoopbackIP(reqURL.Hostname()) {
+		return
+	}
+	for _, uri := range registered {
+		regURL, err := url.Parse(uri)
+		if err != nil || regURL.Scheme != "http" || !isLoopbackIP(regURL.Hostname()) {
+			continue
+		}
+		if regURL.Hostname() == reqURL.Hostname() &&
+			regURL.EscapedPath() == reqURL.EscapedPath() &&
+			regURL.RawQuery == reqURL.RawQuery {
+			return requested, true
+		}
+	}
+	return
+}
+
+// isLoopbackIP report whether host is loopback IP literal.
+func isLoopbackIP(host string) bool {
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// isLoopbackHost report whether host is loopback IP literal or localhost.
+func isLoopbackHost(host string) bool {
+	return host == "localhost" || isLoopbackIP(host)
+}
@@ -0,0 +1,144 @@
+package helpers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestMatchRedirectURI(t *testing.T) {
+	t.Parallel()
+
+	registered := []string{"https://app.example.com/cb", "http://127.0.0.1/cb", "http://[::1]:8080/cb"}
+
+	matched, ok := MatchRedirectURI(registered, "https://app.example.com/cb")
+	utils.AssertEqual(t, true, ok)
+	utils.AssertEqual(t, "https://app.example.com/cb", matched)
+
+	matched, ok = MatchRedirectURI(registered, "http://127.0.0.1:51234/cb")
+	utils.AssertEqual(t, true, ok)
+	utils.AssertEqual(t, "http://127.0.0.1:51234/cb", matched)
+	_, ok = MatchRedirectURI(registered, "http://[::1]:9999/cb")
+	utils.AssertEqual(t, true, ok)
+
+	for _, uri := range []string{
+		"https://app.example.com/cb/",
+		"https://app.example.com/cb?next=https://evil.example",
+		"https://app.example.com/cb#frag",
+		"https://app.example.com.evil.example/cb",
+		"http://127.0.0.1:51234/other",
+		"http://user@127.0.0.1:51234/cb",
+		"http://localhost:51234/cb",
+	} {
+		_, ok = MatchRedirectURI(registered, uri)
+		utils.AssertEqual(t, false, ok, uri)
+	}
+}
+
+func TestValidateRedirectURI(t *testing.T) {
+	t.Parallel()
+
+	for _, uri := range []string{"https://app.example.com/cb", "http://127.0.0.1/cb", "http://localhost:3000/cb", "com.example.app:/cb"} {
+		utils.AssertEqual(t, nil, ValidateRedirectURI(uri), uri)
+	}
+	for _, uri := range []string{"/cb", "https://app.example.com/cb#x", "http://app.example.com/cb", "https://u:p@app.example.com/cb", "javascript:alert(1)", "myapp:/cb"} {
+		utils.AssertEqual(t, InvalidRequest, AsOauthError(ValidateRedirectURI(uri)).Err, uri)
+	}
+}
+
+func TestOauthStateManager(t *testing.T) {
+	t.Parallel()
+
+	manager := NewOauthStateManager()
+	app := fiber.New()
+	app.Get("/login", func(c *fiber.Ctx) error {
+		state, err := manager.Begin(c, "https://app.example.com/cb")
+		if err != nil {
+			return err
+		}
+		return c.SendString(state.State)
+	})
+	app.Get("/cb", func(c *fiber.Ctx) error {
+		state, err := manager.Callback(c)
+		if err != nil {
+			return c.Status(http.StatusBadRequest).SendString(string(AsOauthError(err).Err))
+		}
+		return c.SendString(state.RedirectURI)
+	})
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/login", nil))
+	utils.AssertEqual(t, nil, err)
+	cookie := resp.Cookies()[0]
+	body := make([]byte, resp.ContentLength)
+	_, _ = resp.Body.Read(body)
+	state := string(body)
+	utils.AssertEqual(t, state, cookie.Value)
+
+	callback := func(query string, withCookie bool) (int, string) {
+		req := httptest.NewRequest(http.MethodGet, "/cb?"+query, nil)
+		if withCookie {
+			req.AddCookie(cookie)
+		}
+		resp, err := app.Test(req)
+		utils.AssertEqual(t, nil, err)
+		body := make([]byte, resp.ContentLength)
+		_, _ = resp.Body.Read(body)
+		return resp.StatusCode, string(body)
+	}
+
+	// state without cookie of same user agent
+	status, body2 := callback("code=abc&state="+state, false)
+	utils.AssertEqual(t, http.StatusBadRequest, status)
+	utils.AssertEqual(t, string(InvalidRequest), body2)
+
+	status, body2 = callback("code=abc&state="+state, true)
+	utils.AssertEqual(t, http.StatusOK, status)
+	utils.AssertEqual(t, "https://app.example.com/cb", body2)
+
+	// state is used once
+	status, _ = callback("code=abc&state="+state, true)
+	utils.AssertEqual(t, http.StatusBadRequest, status)
+
+	st, err := manager.New(context.Background(), "")
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, nil, st.VerifyNonce(st.Nonce))
+	utils.AssertEqual(t, InvalidToken, AsOauthError(st.VerifyNonce("other")).Err)
+}
+
+func TestMemoryOauthStoreSweepExpired(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	store := NewMemoryOauthStore()
+	expired := time.Now().Add(-time.Second)
+	utils.AssertEqual(t, nil, store.SaveState(ctx, &OauthState{State: "state-1", ExpiresAt: expired}))
+	utils.AssertEqual(t, nil, store.SaveCode(ctx, &OauthCode{Code: "code-1", ExpiresAt: expired}))
+	utils.AssertEqual(t, nil, store.SavePushedRequest(ctx, &OauthPushedRequest{RequestURI: "urn:1", ExpiresAt: expired}))
+	utils.AssertEqual(t, nil, store.SaveDeviceCode(ctx, &OauthDeviceCode{DeviceCode: "device-1", UserCode: "BCDF-GHJK", ExpiresAt: expired}))
+	utils.AssertEqual(t, nil, store.SaveToken(ctx, &OauthToken{AccessToken: "access-1", ExpiresAt: expired}))
+	utils.AssertEqual(t, nil, store.SaveToken(ctx, &OauthToken{AccessToken: "access-2", RefreshToken: "refresh-2", ExpiresAt: expired, RefreshExpiresAt: expired}))
+	utils.AssertEqual(t, nil, store.SaveToken(ctx, &OauthToken{AccessToken: "access-3", RefreshToken: "refresh-3", ExpiresAt: expired, FamilyExpiresAt: expired}))
+	// refresh token still usable keep its record
+	utils.AssertEqual(t, nil, store.SaveToken(ctx, &OauthToken{AccessToken: "access-4", RefreshToken: "refresh-4", ExpiresAt: expired, RefreshExpiresAt: time.Now().Add(time.Hour)}))
+	used, err := store.MarkRefreshTokenUsed(ctx, &OauthToken{RefreshToken: "refresh-0", FamilyID: "family-0", FamilyExpiresAt: expired})
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, false, used)
+
+	// next save after sweep interval drop expired records never looked up again
+	store.sweptAt = time.Now().Add(-memoryStoreSweepInterval)
+	utils.AssertEqual(t, nil, store.SaveState(ctx, &OauthState{State: "state-2", ExpiresAt: time.Now().Add(time.Minute)}))
+	utils.AssertEqual(t, 1, len(store.states))
+	utils.AssertEqual(t, 0, len(store.codes))
+	utils.AssertEqual(t, 0, len(store.pushed))
+	utils.AssertEqual(t, 0, len(store.deviceCodes))
+	utils.AssertEqual(t, 0, len(store.userCodes))
+	utils.AssertEqual(t, 1, len(store.accessTokens))
+	utils.AssertEqual(t, 1, len(store.refreshTokens))
+	utils.AssertEqual(t, 0, len(store.usedRefresh))
+	_, err = store.GetRefreshToken(ctx, "refresh-4")
+	utils.AssertEqual(t, nil, err)
+}
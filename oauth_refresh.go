@@ -0,0 +1,188 @@
+package helpers
+
+import (
+	"context"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// OauthEventType type of audit event
+type OauthEventType string
+
+// OauthEventType constant
+const (
+	EventRefreshRotated OauthEventType = "refresh_token.rotated"
+	EventRefreshReused  OauthEventType = "refresh_token.reused"
+	EventRefreshExpired OauthEventType = "refresh_token.expired"
+	EventFamilyRevoked  OauthEventType = "refresh_token.family_revoked"
+	EventGrantRevoked   OauthEventType = "grant.revoked"
+)
+
+// OauthEvent audit event of authorization server
+type OauthEvent struct {
+	Type     OauthEventType `json:"type"`
+	ClientID string         `json:"client_id,omitempty"`
+	UserID   string         `json:"user_id,omitempty"`
+	FamilyID string         `json:"family_id,omitempty"`
+	Time     time.Time      `json:"time"`
+	Detail   string         `json:"detail,omitempty"`
+}
+
+// OauthRefreshFamilyStore remember rotated refresh tokens to detect reuse.
+type OauthRefreshFamilyStore interface {
+	// MarkRefreshTokenUsed record refresh token of token as rotated,
+	// used is true when it was already rotated, e.g. by concurrent request.
+	MarkRefreshTokenUsed(ctx context.Context, token *OauthToken) (used bool, err error)
+	// GetRefreshFamily return family id of rotated refresh token.
+	GetRefreshFamily(ctx context.Context, refreshToken string) (familyID string, err error)
+	// RevokeFamily delete every token of family.
+	RevokeFamily(ctx context.Context, familyID string) error
+}
+
+// grantRefresh rotate refresh token, old token is invalidated and
+// reuse of rotated token revokes its family (OAuth 2.0 Security BCP section 4.14).
+func (s *OauthServer) grantRefresh(ctx context.Context, client *OauthClient, req *OauthRequest) (token *OauthToken, err error) {
+	if req.RefreshToken == "" {
+		return nil, NewOauthError(InvalidRequest, "missing refresh_token")
+	}
+	old, err := s.cfg.Tokens.GetRefreshToken(ctx, req.RefreshToken)
+	if err != nil {
+		if s.cfg.Families != nil {
+			if familyID, err := s.cfg.Families.GetRefreshFamily(ctx, req.RefreshToken); err == nil {
+				s.revokeFamily(ctx, client.ID, "", familyID)
+			}
+		}
+		return nil, NewOauthError(InvalidGrant, "invalid or expired refresh_token")
+	}
+	if old.ClientID != client.ID {
+		return nil, NewOauthError(InvalidGrant, "refresh_token was issued to another client")
+	}
+
+	now := time.Now()
+	if !old.FamilyExpiresAt.IsZero() && now.After(old.FamilyExpiresAt) {
+		_ = s.cfg.Tokens.RemoveToken(ctx, old)
+		s.emit(ctx, OauthEvent{Type: EventRefreshExpired, ClientID: old.ClientID, UserID: old.UserID, FamilyID: old.FamilyID})
+		return nil, NewOauthError(InvalidGrant, "invalid or expired refresh_token")
+	}
+	// refresh token of public client is bound to its DPoP key (RFC 9449 section 5)
+	if old.JKT != "" && client.Public && old.JKT != dpopKey(ctx) {
+		return nil, NewOauthError(InvalidGrant, "DPoP key mismatch")
+	}
+	// downscope narrow new access token only, refresh token keeps whole grant (RFC 6749 section 6)
+	grant := ParseScope(old.GrantScope())
+	scope, err := grant.Downscope(req.Scope)
+	if err != nil {
+		return
+	}
+	if s.cfg.Families != nil {
+		used, err := s.cfg.Families.MarkRefreshTokenUsed(ctx, old)
+		if err != nil {
+			return nil, err
+		}
+		if used {
+			s.revokeFamily(ctx, old.ClientID, old.UserID, old.FamilyID)
+			return nil, NewOauthError(InvalidGrant, "invalid or expired refresh_token")
+		}
+	}
+
+	if token, err = s.newToken(ctx, client.ID, old.UserID, scope.String()); err != nil {
+		return
+	}
+	if token.RefreshToken, err = RandomHash(); err != nil {
+		return
+	}
+	if scope.String() != grant.String() {
+		token.RefreshScope = grant.String()
+	}
+	token.FamilyID = old.FamilyID
+	token.FamilyExpiresAt = old.FamilyExpiresAt
+	if token.FamilyExpiresAt.IsZero() {
+		token.FamilyExpiresAt = old.RefreshExpiresAt
+	}
+	token.RefreshExpiresAt = s.refreshExpiresAt(now, token.FamilyExpiresAt)
+	if err = s.cfg.Tokens.SaveToken(ctx, token); err != nil {
+		return nil, err
+	}
+	if err = s.cfg.Tokens.RemoveToken(ctx, old); err != nil {
+		return
+	}
+	s.emit(ctx, OauthEvent{Type: EventRefreshRotated, ClientID: token.ClientID, UserID: token.UserID, FamilyID: token.FamilyID})
+	return
+}
+
+// refreshExpiresAt return idle expiry of refresh token capped by family expiry.
+func (s *OauthServer) refreshExpiresAt(now, familyExpiresAt time.Time) time.Time {
+	if s.cfg.RefreshTokenIdleTTL > 0 {
+		if idle := now.Add(s.cfg.RefreshTokenIdleTTL); familyExpiresAt.IsZero() || idle.Before(familyExpiresAt) {
+			return idle
+		}
+	}
+	return familyExpiresAt
+}
+
+// revokeFamily revoke tokens of family after refresh token reuse.
+func (s *OauthServer) revokeFamily(ctx context.Context, clientID, userID, familyID string) {
+	s.emit(ctx, OauthEvent{Type: EventRefreshReused, ClientID: clientID, UserID: userID, FamilyID: familyID})
+	if familyID == "" {
+		return
+	}
+	event := OauthEvent{Type: EventFamilyRevoked, ClientID: clientID, UserID: userID, FamilyID: familyID}
+	if err := s.cfg.Families.RevokeFamily(ctx, familyID); err != nil {
+		event.Detail = err.Error()
+	}
+	s.emit(ctx, event)
+}
+
+// emit send event to OnEvent hook.
+func (s *OauthServer) emit(ctx context.Context, event OauthEvent) {
+	if s.cfg.OnEvent == nil {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	s.cfg.OnEvent(ctx, event)
+}
+
+type usedRefreshToken struct {
+	familyID  string
+	expiresAt time.Time
+}
+
+func (s *MemoryOauthStore) MarkRefreshTokenUsed(ctx context.Context, token *OauthToken) (used bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sweepExpired(time.Now())
+	if _, used = s.usedRefresh[token.RefreshToken]; used {
+		return
+	}
+	s.usedRefresh[token.RefreshToken] = usedRefreshToken{familyID: token.FamilyID, expiresAt: token.FamilyExpiresAt}
+	return
+}
+
+func (s *MemoryOauthStore) GetRefreshFamily(ctx context.Context, refreshToken string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	record, ok := s.usedRefresh[refreshToken]
+	if !ok || (!record.expiresAt.IsZero() && time.Now().After(record.expiresAt)) {
+		return "", fiber.ErrNotFound
+	}
+	return record.familyID, nil
+}
+
+func (s *MemoryOauthStore) RevokeFamily(ctx context.Context, familyID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, token := range s.accessTokens {
+		if token.FamilyID == familyID {
+			delete(s.accessTokens, key)
+		}
+	}
+	for key, token := range s.refreshTokens {
+		if token.FamilyID == familyID {
+			delete(s.refreshTokens, key)
+		}
+	}
+	return nil
+}
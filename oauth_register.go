@@ -0,0 +1,368 @@
+package helpers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/segmentio/encoding/json"
+)
+
+// OauthClientMetadata client metadata of dynamic registration (RFC 7591 section 2)
+type OauthClientMetadata struct {
+	RedirectURIs            []string         `json:"redirect_uris,omitempty"`
+	TokenEndpointAuthMethod ClientAuthMethod `json:"token_endpoint_auth_method,omitempty"`
+	GrantTypes              []GrantType      `json:"grant_types,omitempty"`
+	ResponseTypes           []ResponseType   `json:"response_types,omitempty"`
+	ClientName              string           `json:"client_name,omitempty"`
+	ClientURI               string           `json:"client_uri,omitempty"`
+	Scope                   string           `json:"scope,omitempty"`
+	Contacts                []string         `json:"contacts,omitempty"`
+	JWKSURI                 string           `json:"jwks_uri,omitempty"`
+	JWKS                    *JWKSet          `json:"jwks,omitempty"`
+}
+
+// OauthClientInformation registration response (RFC 7591 section 3.2.1, RFC 7592 section 3)
+type OauthClientInformation struct {
+	OauthClientMetadata
+	ClientID                string `json:"client_id"`
+	ClientSecret            string `json:"client_secret,omitempty"`
+	ClientIDIssuedAt        int64  `json:"client_id_issued_at,omitempty"`
+	ClientSecretExpiresAt   int64  `json:"client_secret_expires_at"`
+	RegistrationAccessToken string `json:"registration_access_token,omitempty"`
+	RegistrationClientURI   string `json:"registration_client_uri,omitempty"`
+}
+
+// Metadata return registered metadata of client.
+func (c *OauthClient) Metadata() OauthClientMetadata {
+	metadata := OauthClientMetadata{
+		RedirectURIs:            c.RedirectURIs,
+		TokenEndpointAuthMethod: c.AuthMethod,
+		GrantTypes:              c.GrantTypes,
+		ClientName:              c.Name,
+		ClientURI:               c.ClientURI,
+		Scope:                   c.Scope,
+		Contacts:                c.Contacts,
+		JWKSURI:                 c.JWKSURI,
+		JWKS:                    c.JWKS,
+	}
+	for _, grantType := range c.GrantTypes {
+		switch grantType {
+		case GrantTypeCode:
+			metadata.ResponseTypes = append(metadata.ResponseTypes, ResponseTypeCode)
+		case GrantTypeImplicit:
+			metadata.ResponseTypes = append(metadata.ResponseTypes, ResponseTypeToken)
+		}
+	}
+	return metadata
+}
+
+// validateClientMetadata check and fill defaults of metadata (RFC 7591 section 2).
+func (s *OauthServer) validateClientMetadata(metadata *OauthClientMetadata) *OauthError {
+	if metadata.TokenEndpointAuthMethod == "" {
+		metadata.TokenEndpointAuthMethod = ClientSecretBasic
+	}
+	switch metadata.TokenEndpointAuthMethod {
+	case ClientAuthNone, ClientSecretBasic, ClientSecretPost:
+	case PrivateKeyJWT:
+		if metadata.JWKSURI == "" && (metadata.JWKS == nil || len(metadata.JWKS.Keys) == 0) {
+			return NewOauthError(InvalidClientMetadata, "private_key_jwt requires jwks or jwks_uri")
+		}
+	default:
+		return NewOauthError(InvalidClientMetadata, "unsupported token_endpoint_auth_method")
+	}
+	if metadata.JWKSURI != "" && metadata.JWKS != nil {
+		return NewOauthError(InvalidClientMetadata, "jwks and jwks_uri must not both be present")
+	}
+	if metadata.JWKSURI != "" && !isHTTPSURI(metadata.JWKSURI) {
+		return NewOauthError(InvalidClientMetadata, "jwks_uri must use https")
+	}
+
+	if len(metadata.GrantTypes) == 0 {
+		metadata.GrantTypes = []GrantType{GrantTypeCode}
+	}
+	supported := s.GrantTypes()
+	needRedirect := false
+	for _, grantType := range metadata.GrantTypes {
+		switch grantType {
+		case GrantTypeCode, GrantTypeImplicit:
+			needRedirect = true
+		case GrantTypeClient:
+			if metadata.TokenEndpointAuthMethod == ClientAuthNone {
+				return NewOauthError(InvalidClientMetadata, "client_credentials requires client authentication")
+			}
+		}
+		if grantType != GrantTypeImplicit && !containsGrantType(supported, grantType) {
+			return NewOauthError(InvalidClientMetadata, "unsupported grant_type "+string(grantType))
+		}
+	}
+	for _, responseType := range metadata.ResponseTypes {
+		if responseType != ResponseTypeCode && responseType != ResponseTypeToken {
+			return NewOauthError(InvalidClientMetadata, "unsupported response_type "+string(responseType))
+		}
+	}
+
+	if metadata.Scope == "" {
+		metadata.Scope = s.cfg.RegistrationScope
+	}
+	requested := ParseScope(metadata.Scope)
+	if len(requested) == 0 {
+		return NewOauthError(InvalidClientMetadata, "scope required")
+	}
+	for _, scope := range requested {
+		if scope == "*" || strings.HasSuffix(scope, ":*") {
+			return NewOauthError(InvalidClientMetadata, "wildcard scope "+scope+" not allowed")
+		}
+	}
+	if !ParseScope(s.cfg.RegistrationScope).Contains(requested) {
+		return NewOauthError(InvalidClientMetadata, "scope exceeds registration scope")
+	}
+	metadata.Scope = requested.String()
+
+	if needRedirect && len(metadata.RedirectURIs) == 0 {
+		return NewOauthError(InvalidRedirectURI, "redirect_uris required for redirect based grants")
+	}
+	for _, redirectURI := range metadata.RedirectURIs {
+		if err := ValidateRedirectURI(redirectURI); err != nil {
+			oauthErr := AsOauthError(err)
+			return NewOauthError(InvalidRedirectURI, oauthErr.Description)
+		}
+	}
+	return nil
+}
+
+// applyClientMetadata copy metadata to client.
+func applyClientMetadata(client *OauthClient, metadata OauthClientMetadata) {
+	client.RedirectURIs = metadata.RedirectURIs
+	client.AuthMethod = metadata.TokenEndpointAuthMethod
+	client.Public = metadata.TokenEndpointAuthMethod == ClientAuthNone
+	client.GrantTypes = metadata.GrantTypes
+	client.Name = metadata.ClientName
+	client.ClientURI = metadata.ClientURI
+	client.Scope = metadata.Scope
+	client.Contacts = metadata.Contacts
+	client.JWKSURI = metadata.JWKSURI
+	client.JWKS = metadata.JWKS
+	// public clients must prove possession of code
+	client.RequirePKCE = client.Public
+}
+
+func containsGrantType(grantTypes []GrantType, grantType GrantType) bool {
+	for _, v := range grantTypes {
+		if v == grantType {
+			return true
+		}
+	}
+	return false
+}
+
+// RegisterClientHandler handle client registration endpoint (RFC 7591 section 3)
+func (s *OauthServer) RegisterClientHandler(c *fiber.Ctx) (err error) {
+	ctx := c.UserContext()
+	c.Set(fiber.HeaderCacheControl, "no-store")
+
+	auth, err := ExtractAuthString(c.Get(fiber.HeaderAuthorization))
+	if err != nil || auth.Type != BearerToken || s.cfg.InitialAccessToken(ctx, auth.Token) != nil {
+		return writeResourceError(c, s.cfg.Issuer, NewOauthError(InvalidToken, "invalid initial access token"))
+	}
+
+	var metadata OauthClientMetadata
+	if err = json.Unmarshal(c.Body(), &metadata); err != nil {
+		return writeOauthError(c, NewOauthError(InvalidClientMetadata, err.Error()))
+	}
+	if oauthErr := s.validateClientMetadata(&metadata); oauthErr != nil {
+		return writeOauthError(c, oauthErr)
+	}
+
+	client := &OauthClient{IssuedAt: time.Now()}
+	if client.ID, err = UUIDv4(); err != nil {
+		return writeOauthError(c, AsOauthError(err))
+	}
+	applyClientMetadata(client, metadata)
+
+	info := OauthClientInformation{
+		ClientID:         client.ID,
+		ClientIDIssuedAt: client.IssuedAt.Unix(),
+	}
+	if client.AuthMethod == ClientSecretBasic || client.AuthMethod == ClientSecretPost {
+		if info.ClientSecret, err = s.addClientSecret(client); err != nil {
+			return writeOauthError(c, AsOauthError(err))
+		}
+	}
+	if info.RegistrationAccessToken, err = RandomHash(); err != nil {
+		return writeOauthError(c, AsOauthError(err))
+	}
+	if client.RegistrationTokenHash, err = HashPasswordString(info.RegistrationAccessToken); err != nil {
+		return writeOauthError(c, AsOauthError(err))
+	}
+	if err = s.cfg.Registry.SaveClient(ctx, client); err != nil {
+		return writeOauthError(c, AsOauthError(err))
+	}
+
+	info.OauthClientMetadata = client.Metadata()
+	info.RegistrationClientURI = s.registrationClientURI(client.ID)
+	return c.Status(http.StatusCreated).JSON(info)
+}
+
+// ReadClientHandler handle client read request (RFC 7592 section 2.1)
+func (s *OauthServer) ReadClientHandler(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	client, oauthErr := s.registeredClient(c)
+	if oauthErr != nil {
+		return writeResourceError(c, s.cfg.Issuer, oauthErr)
+	}
+	return c.JSON(s.clientInformation(client))
+}
+
+// UpdateClientHandler handle client update request, metadata is replaced (RFC 7592 section 2.2)
+func (s *OauthServer) UpdateClientHandler(c *fiber.Ctx) (err error) {
+	ctx := c.UserContext()
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	client, oauthErr := s.registeredClient(c)
+	if oauthErr != nil {
+		return writeResourceError(c, s.cfg.Issuer, oauthErr)
+	}
+
+	var req struct {
+		OauthClientMetadata
+		ClientID     string `json:"client_id"`
+		ClientSecret string `json:"client_secret"`
+	}
+	if err = json.Unmarshal(c.Body(), &req); err != nil {
+		return writeOauthError(c, NewOauthError(InvalidClientMetadata, err.Error()))
+	}
+	if req.ClientID != client.ID {
+		return writeOauthError(c, NewOauthError(InvalidRequest, "client_id mismatch"))
+	}
+	if req.ClientSecret != "" && !client.VerifySecret(req.ClientSecret) {
+		return writeOauthError(c, NewOauthError(InvalidRequest, "client_secret mismatch"))
+	}
+	if oauthErr = s.validateClientMetadata(&req.OauthClientMetadata); oauthErr != nil {
+		return writeOauthError(c, oauthErr)
+	}
+
+	updated := *client
+	applyClientMetadata(&updated, req.OauthClientMetadata)
+	if updated.Public {
+		updated.Secrets = nil
+	}
+	var secret string
+	if !updated.Public && len(updated.Secrets) == 0 && updated.AuthMethod != PrivateKeyJWT {
+		if secret, err = s.addClientSecret(&updated); err != nil {
+			return writeOauthError(c, AsOauthError(err))
+		}
+	}
+	if err = s.cfg.Registry.SaveClient(ctx, &updated); err != nil {
+		return writeOauthError(c, AsOauthError(err))
+	}
+	info := s.clientInformation(&updated)
+	info.ClientSecret = secret
+	return c.JSON(info)
+}
+
+// DeleteClientHandler handle client delete request (RFC 7592 section 2.3)
+func (s *OauthServer) DeleteClientHandler(c *fiber.Ctx) error {
+	client, oauthErr := s.registeredClient(c)
+	if oauthErr != nil {
+		return writeResourceError(c, s.cfg.Issuer, oauthErr)
+	}
+	if err := s.cfg.Registry.RemoveClient(c.UserContext(), client.ID); err != nil {
+		return writeOauthError(c, AsOauthError(err))
+	}
+	return c.SendStatus(http.StatusNoContent)
+}
+
+// RotateClientSecretHandler issue new client secret, previous secrets expire after SecretRotationOverlap.
+func (s *OauthServer) RotateClientSecretHandler(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	client, oauthErr := s.registeredClient(c)
+	if oauthErr != nil {
+		return writeResourceError(c, s.cfg.Issuer, oauthErr)
+	}
+	secret, err := s.RotateClientSecret(c.UserContext(), client.ID)
+	if err != nil {
+		return writeOauthError(c, AsOauthError(err))
+	}
+	info := s.clientInformation(client)
+	info.ClientSecret = secret
+	return c.JSON(info)
+}
+
+// RotateClientSecret add new secret to client and expire current ones after SecretRotationOverlap.
+func (s *OauthServer) RotateClientSecret(ctx context.Context, clientID string) (secret string, err error) {
+	if s.cfg.Registry == nil {
+		return "", NewOauthError(ServerError, "client registry not configured")
+	}
+	client, err := s.cfg.Registry.GetClient(ctx, clientID)
+	if err != nil {
+		return "", NewOauthError(InvalidClient, "unknown client")
+	}
+	if client.Public {
+		return "", NewOauthError(InvalidRequest, "public client has no secret")
+	}
+	rotated := *client
+	now := time.Now()
+	overlap := now.Add(s.cfg.SecretRotationOverlap)
+	rotated.Secrets = nil
+	for _, old := range client.Secrets {
+		if !old.ExpiresAt.IsZero() && now.After(old.ExpiresAt) {
+			continue
+		}
+		if old.ExpiresAt.IsZero() || old.ExpiresAt.After(overlap) {
+			old.ExpiresAt = overlap
+		}
+		rotated.Secrets = append(rotated.Secrets, old)
+	}
+	if secret, err = s.addClientSecret(&rotated); err != nil {
+		return
+	}
+	err = s.cfg.Registry.SaveClient(ctx, &rotated)
+	return
+}
+
+// addClientSecret generate secret and add its hash to client.
+func (s *OauthServer) addClientSecret(client *OauthClient) (secret string, err error) {
+	if secret, err = RandomHash(); err != nil {
+		return
+	}
+	hash, err := HashPasswordString(secret)
+	if err != nil {
+		return "", err
+	}
+	client.Secrets = append(client.Secrets, OauthClientSecret{Hash: hash, CreatedAt: time.Now()})
+	return
+}
+
+// registeredClient authenticate registration access token of client in path.
+func (s *OauthServer) registeredClient(c *fiber.Ctx) (client *OauthClient, oauthErr *OauthError) {
+	// unknown client and invalid token are indistinguishable (RFC 7592 section 2.1)
+	oauthErr = NewOauthError(InvalidToken, "invalid registration access token")
+	auth, err := ExtractAuthString(c.Get(fiber.HeaderAuthorization))
+	if err != nil || auth.Type != BearerToken {
+		return
+	}
+	client, err = s.cfg.Registry.GetClient(c.UserContext(), c.Params("client_id"))
+	if err != nil || client.RegistrationTokenHash == "" ||
+		CheckPasswordHashString(auth.Token, client.RegistrationTokenHash) != nil {
+		return nil, oauthErr
+	}
+	return client, nil
+}
+
+func (s *OauthServer) clientInformation(client *OauthClient) OauthClientInformation {
+	info := OauthClientInformation{
+		OauthClientMetadata:   client.Metadata(),
+		ClientID:              client.ID,
+		RegistrationClientURI: s.registrationClientURI(client.ID),
+	}
+	if !client.IssuedAt.IsZero() {
+		info.ClientIDIssuedAt = client.IssuedAt.Unix()
+	}
+	return info
+}
+
+func (s *OauthServer) registrationClientURI(clientID string) string {
+	return strings.TrimSuffix(s.cfg.Issuer, "/") + "/register/" + clientID
+}
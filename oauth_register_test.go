@@ -0,0 +1,134 @@
+package helpers
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/segmentio/encoding/json"
+)
+
+func TestOauthServerClientRegistration(t *testing.T) {
+	t.Parallel()
+	store := NewMemoryOauthStore()
+	server := NewOauthServer(OauthServerConfig{
+		Issuer:            "https://auth.example.com",
+		Clients:           store,
+		RegistrationScope: "orders profile",
+		InitialAccessToken: func(ctx context.Context, token string) error {
+			if token != "initial" {
+				return fiber.ErrUnauthorized
+			}
+			return nil
+		},
+	})
+	app := fiber.New()
+	server.Register(app)
+
+	call := func(method, path, token string, body interface{}, out interface{}) int {
+		var reader io.Reader
+		if body != nil {
+			b, err := json.Marshal(body)
+			utils.AssertEqual(t, nil, err)
+			reader = bytes.NewReader(b)
+		}
+		req := httptest.NewRequest(method, path, reader)
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+		if token != "" {
+			req.Header.Set(fiber.HeaderAuthorization, "Bearer "+token)
+		}
+		resp, err := app.Test(req, -1)
+		utils.AssertEqual(t, nil, err)
+		if out != nil {
+			utils.AssertEqual(t, nil, json.NewDecoder(resp.Body).Decode(out))
+		}
+		return resp.StatusCode
+	}
+
+	metadata := OauthClientMetadata{
+		RedirectURIs: []string{"https://app.example.com/cb"},
+		GrantTypes:   []GrantType{GrantTypeCode, GrantTypeClient},
+		ClientName:   "app",
+		Scope:        "orders",
+	}
+	utils.AssertEqual(t, http.StatusUnauthorized, call(http.MethodPost, "/register", "wrong", metadata, nil))
+
+	var oauthResp OauthResponse
+	bad := metadata
+	bad.RedirectURIs = []string{"http://app.example.com/cb"}
+	utils.AssertEqual(t, http.StatusBadRequest, call(http.MethodPost, "/register", "initial", bad, &oauthResp))
+	utils.AssertEqual(t, InvalidRedirectURI, oauthResp.Error)
+
+	// registered scope is limited by server, wildcards never allowed
+	bad = metadata
+	bad.Scope = "orders admin"
+	utils.AssertEqual(t, http.StatusBadRequest, call(http.MethodPost, "/register", "initial", bad, &oauthResp))
+	utils.AssertEqual(t, InvalidClientMetadata, oauthResp.Error)
+	bad.Scope = "orders:*"
+	utils.AssertEqual(t, http.StatusBadRequest, call(http.MethodPost, "/register", "initial", bad, &oauthResp))
+	utils.AssertEqual(t, InvalidClientMetadata, oauthResp.Error)
+	var defaulted OauthClientInformation
+	bad.Scope = ""
+	utils.AssertEqual(t, http.StatusCreated, call(http.MethodPost, "/register", "initial", bad, &defaulted))
+	utils.AssertEqual(t, "orders profile", defaulted.Scope)
+
+	var info OauthClientInformation
+	utils.AssertEqual(t, http.StatusCreated, call(http.MethodPost, "/register", "initial", metadata, &info))
+	utils.AssertEqual(t, ClientSecretBasic, info.TokenEndpointAuthMethod)
+	utils.AssertEqual(t, true, info.ClientSecret != "" && info.RegistrationAccessToken != "")
+	utils.AssertEqual(t, "https://auth.example.com/register/"+info.ClientID, info.RegistrationClientURI)
+
+	// secret is stored hashed
+	client, err := store.GetClient(context.Background(), info.ClientID)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, "", client.Secret)
+	utils.AssertEqual(t, false, client.Secrets[0].Hash == info.ClientSecret)
+
+	clientCredentials := func(secret string) int {
+		status, _ := oauthTokenRequest(t, app, url.Values{
+			"grant_type": {"client_credentials"},
+		}, fiber.HeaderAuthorization, "Basic "+base64.StdEncoding.EncodeToString([]byte(info.ClientID+":"+secret)))
+		return status
+	}
+	utils.AssertEqual(t, http.StatusOK, clientCredentials(info.ClientSecret))
+
+	path := "/register/" + info.ClientID
+	var read OauthClientInformation
+	utils.AssertEqual(t, http.StatusUnauthorized, call(http.MethodGet, path, "initial", nil, nil))
+	utils.AssertEqual(t, http.StatusOK, call(http.MethodGet, path, info.RegistrationAccessToken, nil, &read))
+	utils.AssertEqual(t, "", read.ClientSecret)
+	utils.AssertEqual(t, "app", read.ClientName)
+
+	update := struct {
+		OauthClientMetadata
+		ClientID string `json:"client_id"`
+	}{metadata, info.ClientID}
+	update.ClientName = "renamed"
+	utils.AssertEqual(t, http.StatusOK, call(http.MethodPut, path, info.RegistrationAccessToken, update, &read))
+	utils.AssertEqual(t, "renamed", read.ClientName)
+
+	// old secret stays valid during overlap
+	var rotated OauthClientInformation
+	utils.AssertEqual(t, http.StatusOK, call(http.MethodPost, path+"/secret", info.RegistrationAccessToken, nil, &rotated))
+	utils.AssertEqual(t, true, rotated.ClientSecret != info.ClientSecret)
+	utils.AssertEqual(t, http.StatusOK, clientCredentials(info.ClientSecret))
+	utils.AssertEqual(t, http.StatusOK, clientCredentials(rotated.ClientSecret))
+
+	client, _ = store.GetClient(context.Background(), info.ClientID)
+	expired := *client
+	expired.Secrets = append([]OauthClientSecret(nil), client.Secrets...)
+	expired.Secrets[0].ExpiresAt = time.Now().Add(-time.Second)
+	utils.AssertEqual(t, nil, store.SaveClient(context.Background(), &expired))
+	utils.AssertEqual(t, http.StatusUnauthorized, clientCredentials(info.ClientSecret))
+
+	utils.AssertEqual(t, http.StatusNoContent, call(http.MethodDelete, path, info.RegistrationAccessToken, nil, nil))
+	utils.AssertEqual(t, http.StatusUnauthorized, call(http.MethodGet, path, info.RegistrationAccessToken, nil, nil))
+}
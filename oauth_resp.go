@@ -1,5 +1,12 @@
 package helpers
 
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
 // OauthRequest oauth request by IETF
 type OauthRequest struct {
 	APIKey       string `json:"client_id" form:"client_id" query:"client_id"`
@@ -14,22 +21,95 @@ type OauthRequest struct {
 	Password     string `json:"password" form:"password" query:"password"`
 	RefreshToken string `json:"refresh_token" form:"refresh_token" query:"refresh_token"`
 	Token        string `json:"token" form:"token" query:"token"`
+	// TokenTypeHint access_token or refresh_token (RFC 7009, RFC 7662)
+	TokenTypeHint string `json:"token_type_hint" form:"token_type_hint" query:"token_type_hint"`
+	// PKCE (RFC 7636)
+	CodeChallenge       string `json:"code_challenge" form:"code_challenge" query:"code_challenge"`
+	CodeChallengeMethod string `json:"code_challenge_method" form:"code_challenge_method" query:"code_challenge_method"`
+	CodeVerifier        string `json:"code_verifier" form:"code_verifier" query:"code_verifier"`
+	// OpenID Connect
+	Nonce string `json:"nonce" form:"nonce" query:"nonce"`
+	// Device authorization grant (RFC 8628)
+	DeviceCode string `json:"device_code" form:"device_code" query:"device_code"`
+	UserCode   string `json:"user_code" form:"user_code" query:"user_code"`
+	// JWT client authentication (RFC 7523)
+	ClientAssertion     string `json:"client_assertion" form:"client_assertion" query:"client_assertion"`
+	ClientAssertionType string `json:"client_assertion_type" form:"client_assertion_type" query:"client_assertion_type"`
+	// Token exchange (RFC 8693)
+	SubjectToken       string   `json:"subject_token" form:"subject_token" query:"subject_token"`
+	SubjectTokenType   string   `json:"subject_token_type" form:"subject_token_type" query:"subject_token_type"`
+	ActorToken         string   `json:"actor_token" form:"actor_token" query:"actor_token"`
+	ActorTokenType     string   `json:"actor_token_type" form:"actor_token_type" query:"actor_token_type"`
+	RequestedTokenType string   `json:"requested_token_type" form:"requested_token_type" query:"requested_token_type"`
+	Audience           []string `json:"audience" form:"audience" query:"audience"`
+	Resource           []string `json:"resource" form:"resource" query:"resource"`
+	// Request object and pushed request uri (RFC 9101, RFC 9126)
+	Request    string `json:"request" form:"request" query:"request"`
+	RequestURI string `json:"request_uri" form:"request_uri" query:"request_uri"`
+}
+
+// Values encode non-empty fields of request as form or query values.
+func (r OauthRequest) Values() url.Values {
+	values := url.Values{}
+	for _, field := range []struct{ key, value string }{
+		{"client_id", r.APIKey},
+		{"client_secret", r.APISecret},
+		{"response_type", r.ResponseType},
+		{"redirect_uri", r.RedirectURI},
+		{"scope", r.Scope},
+		{"state", r.State},
+		{"code", r.Code},
+		{"grant_type", r.GrantType},
+		{"username", r.UserName},
+		{"password", r.Password},
+		{"refresh_token", r.RefreshToken},
+		{"token", r.Token},
+		{"token_type_hint", r.TokenTypeHint},
+		{"code_challenge", r.CodeChallenge},
+		{"code_challenge_method", r.CodeChallengeMethod},
+		{"code_verifier", r.CodeVerifier},
+		{"nonce", r.Nonce},
+		{"device_code", r.DeviceCode},
+		{"user_code", r.UserCode},
+		{"client_assertion", r.ClientAssertion},
+		{"client_assertion_type", r.ClientAssertionType},
+		{"subject_token", r.SubjectToken},
+		{"subject_token_type", r.SubjectTokenType},
+		{"actor_token", r.ActorToken},
+		{"actor_token_type", r.ActorTokenType},
+		{"requested_token_type", r.RequestedTokenType},
+		{"request", r.Request},
+		{"request_uri", r.RequestURI},
+	} {
+		if field.value != "" {
+			values.Set(field.key, field.value)
+		}
+	}
+	for _, audience := range r.Audience {
+		values.Add("audience", audience)
+	}
+	for _, resource := range r.Resource {
+		values.Add("resource", resource)
+	}
+	return values
 }
 
 // OauthResponse oauth request by IETF
 type OauthResponse struct {
-	Scope        string      `json:"scope,omitempty"`
-	State        string      `json:"state,omitempty"`
-	Code         string      `json:"code,omitempty"`
-	Error        OauthErr    `json:"error,omitempty"`
-	ErrorDesc    string      `json:"error_description,omitempty"`
-	ErrorURI     string      `json:"error_uri,omitempty"`
-	AccessToken  string      `json:"access_token,omitempty"`
-	IDToken      string      `json:"id_token,omitempty"`
-	TokenType    string      `json:"token_type,omitempty"`
-	ExpiresIn    int         `json:"expires_in,omitempty"`
-	RefreshToken string      `json:"refresh_token,omitempty"`
-	Data         interface{} `json:"data,omitempty"`
+	Scope        string   `json:"scope,omitempty"`
+	State        string   `json:"state,omitempty"`
+	Code         string   `json:"code,omitempty"`
+	Error        OauthErr `json:"error,omitempty"`
+	ErrorDesc    string   `json:"error_description,omitempty"`
+	ErrorURI     string   `json:"error_uri,omitempty"`
+	AccessToken  string   `json:"access_token,omitempty"`
+	IDToken      string   `json:"id_token,omitempty"`
+	TokenType    string   `json:"token_type,omitempty"`
+	ExpiresIn    int      `json:"expires_in,omitempty"`
+	RefreshToken string   `json:"refresh_token,omitempty"`
+	// IssuedTokenType token type URI of token exchange response (RFC 8693)
+	IssuedTokenType string      `json:"issued_token_type,omitempty"`
+	Data            interface{} `json:"data,omitempty"`
 }
 
 // OauthErr Oauth error response
@@ -37,25 +117,119 @@ type OauthErr string
 
 // Oauth Error Response
 const (
-	InvalidRequest     OauthErr = "invalid_request"
-	UnauthorizedClient OauthErr = "unauthorized_client"
-	AccessDenied       OauthErr = "access_denied"
-	UnsupportType      OauthErr = "unsupported_response_type"
-	InvalidScope       OauthErr = "invalid_scope"
-	ServerError        OauthErr = "server_error"
-	Unavailable        OauthErr = "temporarily_unavailable"
+	InvalidRequest       OauthErr = "invalid_request"
+	InvalidClient        OauthErr = "invalid_client"
+	InvalidGrant         OauthErr = "invalid_grant"
+	UnauthorizedClient   OauthErr = "unauthorized_client"
+	AccessDenied         OauthErr = "access_denied"
+	UnsupportType        OauthErr = "unsupported_response_type"
+	UnsupportedGrantType OauthErr = "unsupported_grant_type"
+	InvalidScope         OauthErr = "invalid_scope"
+	ServerError          OauthErr = "server_error"
+	Unavailable          OauthErr = "temporarily_unavailable"
+	// Device authorization grant errors (RFC 8628)
+	AuthorizationPending OauthErr = "authorization_pending"
+	SlowDown             OauthErr = "slow_down"
+	ExpiredToken         OauthErr = "expired_token"
+	// Dynamic client registration errors (RFC 7591)
+	InvalidRedirectURI    OauthErr = "invalid_redirect_uri"
+	InvalidClientMetadata OauthErr = "invalid_client_metadata"
+	// Token exchange and resource indicators error (RFC 8693, RFC 8707)
+	InvalidTarget OauthErr = "invalid_target"
+	// Request object errors (RFC 9101)
+	InvalidRequestURI    OauthErr = "invalid_request_uri"
+	InvalidRequestObject OauthErr = "invalid_request_object"
+	// DPoP proof error (RFC 9449)
+	InvalidDPoPProof OauthErr = "invalid_dpop_proof"
+	// Bearer token errors (RFC 6750)
+	InvalidToken      OauthErr = "invalid_token"
+	InsufficientScope OauthErr = "insufficient_scope"
 )
 
+// HTTPStatus return HTTP status code of error response, token endpoint errors are 400
+// except invalid_client (RFC 6749 section 5.2), bearer token errors follow RFC 6750 section 3.1.
+func (e OauthErr) HTTPStatus() int {
+	switch e {
+	case InvalidClient, InvalidToken:
+		return http.StatusUnauthorized
+	case InsufficientScope:
+		return http.StatusForbidden
+	case ServerError:
+		return http.StatusInternalServerError
+	case Unavailable:
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusBadRequest
+}
+
+// OauthError is an OAuth error that can be returned as error and rendered as OauthResponse.
+type OauthError struct {
+	Err         OauthErr
+	Description string
+	URI         string
+	Status      int
+	// Cause original error of server_error, for logging only and never sent to client
+	Cause error
+}
+
+// NewOauthError create OauthError with HTTP status of err.
+func NewOauthError(err OauthErr, description ...string) *OauthError {
+	return &OauthError{
+		Err:         err,
+		Description: strings.Join(description, " "),
+		Status:      err.HTTPStatus(),
+	}
+}
+
+// AsOauthError converts err to *OauthError, non OAuth errors become server_error
+// with fixed description and err kept as Cause.
+func AsOauthError(err error) (oauthErr *OauthError) {
+	if err == nil {
+		return nil
+	}
+	if errors.As(err, &oauthErr) {
+		return
+	}
+	oauthErr = NewOauthError(ServerError, "internal server error")
+	oauthErr.Cause = err
+	return
+}
+
+func (e *OauthError) Error() string {
+	if e.Description == "" {
+		return string(e.Err)
+	}
+	return string(e.Err) + ": " + e.Description
+}
+
+// Unwrap return Cause for errors.Is and errors.As.
+func (e *OauthError) Unwrap() error {
+	return e.Cause
+}
+
+// Response render error as OauthResponse.
+func (e *OauthError) Response() OauthResponse {
+	return OauthResponse{
+		Error:     e.Err,
+		ErrorDesc: e.Description,
+		ErrorURI:  e.URI,
+	}
+}
+
 // GrantType Oauth grant type
 type GrantType string
 
 // GrantType constant
 const (
-	GrantTypeCode     GrantType = "authorization_code"
-	GrantTypeClient   GrantType = "client_credentials"
-	GrantTypeRefresh  GrantType = "refresh_token"
-	GrantTypeaccess   GrantType = "access_token"
-	GrantTypePassword GrantType = "password"
+	GrantTypeCode          GrantType = "authorization_code"
+	GrantTypeClient        GrantType = "client_credentials"
+	GrantTypeRefresh       GrantType = "refresh_token"
+	GrantTypeaccess        GrantType = "access_token"
+	GrantTypePassword      GrantType = "password"
+	GrantTypeDevice        GrantType = "urn:ietf:params:oauth:grant-type:device_code"
+	GrantTypeTokenExchange GrantType = "urn:ietf:params:oauth:grant-type:token-exchange"
+	// GrantTypeImplicit client metadata value of implicit flow (RFC 7591), not a token endpoint grant
+	GrantTypeImplicit GrantType = "implicit"
 )
 
 // ResponseType Oauth response type
@@ -0,0 +1,656 @@
+package helpers
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+// OauthServerConfig defines the config for OauthServer.
+type OauthServerConfig struct {
+	// Issuer identifier of server, e.g. https://auth.example.com
+	Issuer string
+
+	Clients OauthClientStore
+	Codes   OauthCodeStore
+	Tokens  OauthTokenStore
+	Devices OauthDeviceStore
+	// PushedRequests keep pushed authorization requests (RFC 9126)
+	PushedRequests OauthPushedRequestStore
+	// Consents record scopes users granted to clients
+	Consents OauthConsentStore
+	// Families detect reuse of rotated refresh tokens, default Tokens when it implements OauthRefreshFamilyStore.
+	Families OauthRefreshFamilyStore
+
+	// Registry stores dynamically registered clients, default Clients when it implements OauthClientRegistry.
+	Registry OauthClientRegistry
+
+	// InitialAccessToken authorize client registration (RFC 7591 section 3),
+	// registration endpoints are not mounted when nil.
+	InitialAccessToken func(ctx context.Context, token string) error
+
+	// RegistrationScope limit scope of dynamically registered clients, client registered
+	// without scope gets all of it. Registration is rejected when the client would end up
+	// without scope, because empty client scope allows any.
+	RegistrationScope string
+
+	// AuthorizeUser return user id of resource owner logged in to /authorize.
+	// Return empty user id with nil error when it has responded itself,
+	// e.g. redirected to login page.
+	// Return *OauthError, e.g. access_denied, to redirect error back to client.
+	AuthorizeUser func(c *fiber.Ctx, req *OauthRequest) (userID string, err error)
+
+	// ConsentUser ask user to approve scope not granted to client before, e.g. render consent page.
+	// Return granted scope, scope beyond requested is ignored so user may grant part of it,
+	// e.g. "*" grants all requested scope and approves request without scope.
+	// Return empty scope with nil error when it has responded itself,
+	// or *OauthError access_denied to deny. Consent is skipped when nil.
+	ConsentUser func(c *fiber.Ctx, consent *OauthConsentRequest) (granted string, err error)
+
+	// GrantsUser return user id of session managing own grants at /grants,
+	// the endpoints are not mounted when nil.
+	// Return empty user id with nil error when it has responded itself, e.g. redirected to login page.
+	GrantsUser func(c *fiber.Ctx) (userID string, err error)
+
+	// AuthenticateUser verify resource owner credentials of password grant,
+	// the grant is unsupported when nil.
+	AuthenticateUser func(ctx context.Context, username, password string) (userID string, err error)
+
+	// VerifyDevice render device verification page of user code (RFC 8628 section 3.3).
+	// On GET device is nil and hook only render user code form, user_code query may prefill it.
+	// On POST return user id to approve, *OauthError access_denied to deny,
+	// or empty user id with nil error when it has responded itself, e.g. login page.
+	VerifyDevice func(c *fiber.Ctx, device *OauthDeviceCode) (userID string, err error)
+
+	// RequirePushedRequests reject authorize requests not pushed to /par first (RFC 9126 section 5)
+	RequirePushedRequests bool
+
+	// VerificationURI shown to user of device flow, default Issuer + "/device"
+	VerificationURI string
+
+	// Keys sign JWT access tokens and are published at /.well-known/jwks.json
+	Keys *JWTKeySet
+
+	// UserClaims supply claims of OpenID Connect /userinfo
+	UserClaims OIDCUserClaimsProvider
+
+	// TokenExchangePolicy decide token exchange requests (RFC 8693),
+	// the grant is unsupported when nil.
+	TokenExchangePolicy TokenExchangePolicy
+
+	// DPoP validate proofs at token endpoint, tokens are bound to proof key (RFC 9449)
+	//
+	// Optional. Default: NewDPoPVerifier()
+	DPoP *DPoPVerifier
+
+	// HTTPClient fetch jwks_uri of clients
+	//
+	// Optional. Default: http.DefaultClient
+	HTTPClient *http.Client
+
+	// OnEvent receive audit events, e.g. refresh token reuse.
+	OnEvent func(ctx context.Context, event OauthEvent)
+
+	// GenerateAccessToken create access token string of token record.
+	// Default JWT access token when Keys is set, otherwise random hash.
+	GenerateAccessToken func(ctx context.Context, token *OauthToken) (string, error)
+
+	// Default 10 minutes
+	CodeTTL time.Duration
+	// Default 1 hour
+	AccessTokenTTL time.Duration
+	// Absolute lifetime of refresh token family. Default 30 days, negative value disables refresh token.
+	RefreshTokenTTL time.Duration
+	// Idle lifetime of refresh token since last rotation, zero disables idle expiry.
+	RefreshTokenIdleTTL time.Duration
+	// Old client secret stays valid this long after rotation. Default 24 hours
+	SecretRotationOverlap time.Duration
+	// Default 60 seconds
+	PushedRequestTTL time.Duration
+	// Default 10 minutes
+	DeviceCodeTTL time.Duration
+	// Default 5 seconds
+	DevicePollInterval time.Duration
+}
+
+// OauthServer OAuth 2.0 authorization server (RFC 6749)
+type OauthServer struct {
+	cfg OauthServerConfig
+
+	replays    *replayCache
+	remoteKeys *jwksCache
+}
+
+// NewOauthServer create authorization server, memory stores are used for nil stores.
+func NewOauthServer(config OauthServerConfig) *OauthServer {
+	memStore := NewMemoryOauthStore()
+	if config.Clients == nil {
+		config.Clients = memStore
+	}
+	if config.Codes == nil {
+		config.Codes = memStore
+	}
+	if config.Tokens == nil {
+		config.Tokens = memStore
+	}
+	if config.Devices == nil {
+		config.Devices = memStore
+	}
+	if config.PushedRequests == nil {
+		config.PushedRequests = memStore
+	}
+	if config.Consents == nil {
+		config.Consents = memStore
+	}
+	if config.Families == nil {
+		config.Families, _ = config.Tokens.(OauthRefreshFamilyStore)
+	}
+	if config.Registry == nil {
+		config.Registry, _ = config.Clients.(OauthClientRegistry)
+	}
+	if config.GenerateAccessToken == nil && config.Keys != nil {
+		config.GenerateAccessToken = JWTAccessToken(config.Keys, config.Issuer)
+	}
+	if config.GenerateAccessToken == nil {
+		config.GenerateAccessToken = func(ctx context.Context, token *OauthToken) (string, error) {
+			return RandomHash()
+		}
+	}
+	if config.CodeTTL == 0 {
+		config.CodeTTL = 10 * time.Minute
+	}
+	if config.AccessTokenTTL == 0 {
+		config.AccessTokenTTL = time.Hour
+	}
+	if config.RefreshTokenTTL == 0 {
+		config.RefreshTokenTTL = 30 * 24 * time.Hour
+	}
+	if config.SecretRotationOverlap == 0 {
+		config.SecretRotationOverlap = 24 * time.Hour
+	}
+	if config.PushedRequestTTL == 0 {
+		config.PushedRequestTTL = time.Minute
+	}
+	if config.DeviceCodeTTL == 0 {
+		config.DeviceCodeTTL = 10 * time.Minute
+	}
+	if config.DevicePollInterval == 0 {
+		config.DevicePollInterval = 5 * time.Second
+	}
+	if config.DPoP == nil {
+		config.DPoP = NewDPoPVerifier()
+	}
+	if config.VerificationURI == "" {
+		config.VerificationURI = strings.TrimSuffix(config.Issuer, "/") + "/device"
+	}
+	return &OauthServer{
+		cfg:        config,
+		replays:    newReplayCache(),
+		remoteKeys: &jwksCache{HTTPClient: config.HTTPClient},
+	}
+}
+
+// Register mount OAuth endpoints to router.
+func (s *OauthServer) Register(router fiber.Router) {
+	router.Get("/authorize", s.AuthorizeHandler)
+	router.Post("/authorize", s.AuthorizeHandler)
+	router.Post("/par", s.PushedAuthorizationHandler)
+	router.Post("/token", s.TokenHandler)
+	router.Post("/introspect", s.IntrospectHandler)
+	router.Post("/revoke", s.RevokeHandler)
+	router.Post("/device_authorization", s.DeviceAuthorizationHandler)
+	if s.cfg.VerifyDevice != nil {
+		router.Get("/device", s.DeviceVerifyHandler)
+		router.Post("/device", s.DeviceVerifyHandler)
+	}
+	if s.cfg.Registry != nil && s.cfg.InitialAccessToken != nil {
+		router.Post("/register", s.RegisterClientHandler)
+		router.Get("/register/:client_id", s.ReadClientHandler)
+		router.Put("/register/:client_id", s.UpdateClientHandler)
+		router.Delete("/register/:client_id", s.DeleteClientHandler)
+		router.Post("/register/:client_id/secret", s.RotateClientSecretHandler)
+	}
+	if s.cfg.GrantsUser != nil {
+		router.Get("/grants", s.ListGrantsHandler)
+		router.Delete("/grants/:client_id", s.RevokeGrantHandler)
+	}
+	if s.cfg.Keys != nil {
+		router.Get("/.well-known/jwks.json", s.cfg.Keys.JWKSHandler)
+		router.Get("/.well-known/openid-configuration", s.DiscoveryHandler)
+		router.Get("/userinfo", s.UserInfoHandler)
+		router.Post("/userinfo", s.UserInfoHandler)
+	}
+}
+
+// ResponseTypes return response types supported by authorize endpoint.
+func (s *OauthServer) ResponseTypes() []ResponseType {
+	return []ResponseType{ResponseTypeCode, ResponseTypeToken}
+}
+
+// GrantTypes return grant types supported by token endpoint.
+func (s *OauthServer) GrantTypes() (grantTypes []GrantType) {
+	grantTypes = []GrantType{GrantTypeCode, GrantTypeClient, GrantTypeRefresh, GrantTypeDevice}
+	if s.cfg.AuthenticateUser != nil {
+		grantTypes = append(grantTypes, GrantTypePassword)
+	}
+	if s.cfg.TokenExchangePolicy != nil {
+		grantTypes = append(grantTypes, GrantTypeTokenExchange)
+	}
+	return
+}
+
+// AuthorizeHandler handle authorization endpoint (RFC 6749 section 3.1)
+func (s *OauthServer) AuthorizeHandler(c *fiber.Ctx) (err error) {
+	ctx := c.UserContext()
+
+	var req OauthRequest
+	if err = parseOauthRequest(c, &req); err != nil {
+		return writeOauthError(c, NewOauthError(InvalidRequest, err.Error()))
+	}
+
+	// errors before redirect uri is trusted must not redirect
+	if oauthErr := s.resolveAuthorizeRequest(ctx, &req); oauthErr != nil {
+		return writeOauthError(c, oauthErr)
+	}
+	client, redirectURI, oauthErr := s.authorizeClient(ctx, &req)
+	if oauthErr != nil {
+		return writeOauthError(c, oauthErr)
+	}
+	responseType := ResponseType(req.ResponseType)
+
+	if oauthErr = s.validateAuthorize(client, &req); oauthErr != nil {
+		return redirectOauthError(c, redirectURI, responseType, req.State, oauthErr)
+	}
+
+	if s.cfg.AuthorizeUser == nil {
+		return redirectOauthError(c, redirectURI, responseType, req.State, NewOauthError(ServerError, "user authorization not configured"))
+	}
+	userID, err := s.cfg.AuthorizeUser(c, &req)
+	if err != nil {
+		return redirectOauthError(c, redirectURI, responseType, req.State, AsOauthError(err))
+	}
+	if userID == "" {
+		return nil
+	}
+	scope, responded, err := s.consent(c, client, userID, &req)
+	if err != nil {
+		return redirectOauthError(c, redirectURI, responseType, req.State, AsOauthError(err))
+	}
+	if responded {
+		return nil
+	}
+
+	resp := OauthResponse{State: req.State}
+	switch responseType {
+	case ResponseTypeCode:
+		code := &OauthCode{
+			ClientID:    client.ID,
+			UserID:      userID,
+			RedirectURI: req.RedirectURI,
+			Scope:       scope,
+			ExpiresAt:   time.Now().Add(s.cfg.CodeTTL),
+
+			CodeChallenge:       req.CodeChallenge,
+			CodeChallengeMethod: PKCEMethod(req.CodeChallengeMethod),
+
+			Nonce:    req.Nonce,
+			AuthTime: time.Now(),
+		}
+		if code.CodeChallenge != "" && code.CodeChallengeMethod == "" {
+			code.CodeChallengeMethod = PKCEPlain
+		}
+		if code.Code, err = RandomHash(); err != nil {
+			return redirectOauthError(c, redirectURI, responseType, req.State, AsOauthError(err))
+		}
+		if err = s.cfg.Codes.SaveCode(ctx, code); err != nil {
+			return redirectOauthError(c, redirectURI, responseType, req.State, AsOauthError(err))
+		}
+		resp.Code = code.Code
+	case ResponseTypeToken:
+		token, err := s.issueToken(ctx, client.ID, userID, scope, false)
+		if err != nil {
+			return redirectOauthError(c, redirectURI, responseType, req.State, AsOauthError(err))
+		}
+		tokenResp := token.Response()
+		tokenResp.State = req.State
+		resp = tokenResp
+	}
+	if req.RequestURI != "" {
+		_ = s.cfg.PushedRequests.RemovePushedRequest(ctx, req.RequestURI)
+	}
+
+	return redirectOauthResponse(c, redirectURI, responseType, resp)
+}
+
+// authorizeClient resolve client and redirect uri of authorize request.
+func (s *OauthServer) authorizeClient(ctx context.Context, req *OauthRequest) (client *OauthClient, redirectURI string, oauthErr *OauthError) {
+	if req.APIKey == "" {
+		oauthErr = NewOauthError(InvalidRequest, "missing client_id")
+		return
+	}
+	client, err := s.cfg.Clients.GetClient(ctx, req.APIKey)
+	if err != nil {
+		oauthErr = NewOauthError(InvalidClient, "unknown client")
+		oauthErr.Status = http.StatusBadRequest
+		return
+	}
+
+	switch {
+	case req.RedirectURI != "":
+		var ok bool
+		if redirectURI, ok = MatchRedirectURI(client.RedirectURIs, req.RedirectURI); !ok {
+			oauthErr = NewOauthError(InvalidRequest, "redirect_uri not registered")
+		}
+	case len(client.RedirectURIs) == 1:
+		redirectURI = client.RedirectURIs[0]
+	default:
+		oauthErr = NewOauthError(InvalidRequest, "missing redirect_uri")
+	}
+	return
+}
+
+// validateAuthorize check authorize request of trusted client and redirect uri.
+func (s *OauthServer) validateAuthorize(client *OauthClient, req *OauthRequest) *OauthError {
+	switch ResponseType(req.ResponseType) {
+	case ResponseTypeCode:
+		if !client.AllowGrant(GrantTypeCode) {
+			return NewOauthError(UnauthorizedClient, "client may not use authorization code")
+		}
+		if oauthErr := validatePKCERequest(client, req); oauthErr != nil {
+			return oauthErr
+		}
+	case ResponseTypeToken:
+		if !client.Public {
+			return NewOauthError(UnauthorizedClient, "implicit grant is for public client only")
+		}
+		if !client.AllowGrant(GrantTypeImplicit) {
+			return NewOauthError(UnauthorizedClient, "client may not use implicit grant")
+		}
+	case "":
+		return NewOauthError(InvalidRequest, "missing response_type")
+	default:
+		return NewOauthError(UnsupportType)
+	}
+	if !scopeAllowed(req.Scope, client.Scope) {
+		return NewOauthError(InvalidScope)
+	}
+	return nil
+}
+
+// validatePKCERequest check code_challenge of authorization code request (RFC 7636 section 4.4)
+func validatePKCERequest(client *OauthClient, req *OauthRequest) *OauthError {
+	if req.CodeChallenge == "" {
+		if req.CodeChallengeMethod != "" {
+			return NewOauthError(InvalidRequest, "missing code_challenge")
+		}
+		if client.RequirePKCE {
+			return NewOauthError(InvalidRequest, "code_challenge required")
+		}
+		return nil
+	}
+	switch PKCEMethod(req.CodeChallengeMethod) {
+	case "", PKCEPlain, PKCES256:
+	default:
+		return NewOauthError(InvalidRequest, "unsupported code_challenge_method")
+	}
+	if !validPKCEValue(req.CodeChallenge) {
+		return NewOauthError(InvalidRequest, "invalid code_challenge")
+	}
+	return nil
+}
+
+// TokenHandler handle token endpoint (RFC 6749 section 3.2)
+func (s *OauthServer) TokenHandler(c *fiber.Ctx) (err error) {
+	ctx := c.UserContext()
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	c.Set(fiber.HeaderPragma, "no-cache")
+
+	var req OauthRequest
+	if err = parseOauthRequest(c, &req); err != nil {
+		return writeOauthError(c, NewOauthError(InvalidRequest, err.Error()))
+	}
+
+	client, oauthErr := s.authenticateClient(c, &req)
+	if oauthErr != nil {
+		return writeOauthError(c, oauthErr)
+	}
+	if proof := c.Get(HeaderDPoP); proof != "" {
+		jkt, err := s.cfg.DPoP.Verify(proof, c.Method(), dpopRequestURI(c), "")
+		if err != nil {
+			return writeOauthError(c, AsOauthError(err))
+		}
+		ctx = withDPoPKey(ctx, jkt)
+	}
+
+	grantType := GrantType(req.GrantType)
+	if grantType == "" {
+		return writeOauthError(c, NewOauthError(InvalidRequest, "missing grant_type"))
+	}
+	if !client.AllowGrant(grantType) {
+		return writeOauthError(c, NewOauthError(UnauthorizedClient, "client may not use "+req.GrantType))
+	}
+
+	var token *OauthToken
+	switch grantType {
+	case GrantTypeCode:
+		token, err = s.grantCode(ctx, client, &req)
+	case GrantTypeClient:
+		token, err = s.grantClient(ctx, client, &req)
+	case GrantTypePassword:
+		token, err = s.grantPassword(ctx, client, &req)
+	case GrantTypeRefresh:
+		token, err = s.grantRefresh(ctx, client, &req)
+	case GrantTypeDevice:
+		token, err = s.grantDevice(ctx, client, &req)
+	case GrantTypeTokenExchange:
+		if s.cfg.TokenExchangePolicy == nil {
+			err = NewOauthError(UnsupportedGrantType)
+			break
+		}
+		token, err = s.grantTokenExchange(ctx, client, &req)
+	default:
+		err = NewOauthError(UnsupportedGrantType)
+	}
+	if err != nil {
+		return writeOauthError(c, AsOauthError(err))
+	}
+
+	return c.JSON(token.Response())
+}
+
+func (s *OauthServer) grantCode(ctx context.Context, client *OauthClient, req *OauthRequest) (token *OauthToken, err error) {
+	if req.Code == "" {
+		return nil, NewOauthError(InvalidRequest, "missing code")
+	}
+	code, err := s.cfg.Codes.TakeCode(ctx, req.Code)
+	if err != nil {
+		return nil, NewOauthError(InvalidGrant, "invalid or expired code")
+	}
+	if code.ClientID != client.ID {
+		return nil, NewOauthError(InvalidGrant, "code was issued to another client")
+	}
+	if code.RedirectURI != req.RedirectURI {
+		return nil, NewOauthError(InvalidGrant, "redirect_uri mismatch")
+	}
+	switch {
+	case code.CodeChallenge != "" && req.CodeVerifier == "":
+		return nil, NewOauthError(InvalidRequest, "missing code_verifier")
+	case code.CodeChallenge == "" && req.CodeVerifier != "":
+		return nil, NewOauthError(InvalidGrant, "code was issued without code_challenge")
+	case code.CodeChallenge != "" && !VerifyPKCE(req.CodeVerifier, code.CodeChallenge, code.CodeChallengeMethod):
+		return nil, NewOauthError(InvalidGrant, "code_verifier mismatch")
+	}
+	if token, err = s.issueToken(ctx, client.ID, code.UserID, code.Scope, true); err != nil {
+		return
+	}
+	err = s.issueIDToken(token, code.Nonce, code.AuthTime)
+	return
+}
+
+func (s *OauthServer) grantClient(ctx context.Context, client *OauthClient, req *OauthRequest) (token *OauthToken, err error) {
+	if client.Public {
+		return nil, NewOauthError(UnauthorizedClient, "public client may not use client_credentials")
+	}
+	if !scopeAllowed(req.Scope, client.Scope) {
+		return nil, NewOauthError(InvalidScope)
+	}
+	return s.issueToken(ctx, client.ID, "", req.Scope, false)
+}
+
+func (s *OauthServer) grantPassword(ctx context.Context, client *OauthClient, req *OauthRequest) (token *OauthToken, err error) {
+	if s.cfg.AuthenticateUser == nil {
+		return nil, NewOauthError(UnsupportedGrantType)
+	}
+	if req.UserName == "" || req.Password == "" {
+		return nil, NewOauthError(InvalidRequest, "missing username or password")
+	}
+	if !scopeAllowed(req.Scope, client.Scope) {
+		return nil, NewOauthError(InvalidScope)
+	}
+	userID, err := s.cfg.AuthenticateUser(ctx, req.UserName, req.Password)
+	if err != nil || userID == "" {
+		return nil, NewOauthError(InvalidGrant, "invalid resource owner credentials")
+	}
+	if token, err = s.issueToken(ctx, client.ID, userID, req.Scope, true); err != nil {
+		return
+	}
+	err = s.issueIDToken(token, "", time.Now())
+	return
+}
+
+// newToken create unsaved access token record, options set fields before access token is generated.
+func (s *OauthServer) newToken(ctx context.Context, clientID, userID, scope string, options ...func(token *OauthToken)) (token *OauthToken, err error) {
+	now := time.Now()
+	token = &OauthToken{
+		TokenType: "Bearer",
+		ClientID:  clientID,
+		UserID:    userID,
+		Scope:     scope,
+		IssuedAt:  now,
+		ExpiresAt: now.Add(s.cfg.AccessTokenTTL),
+	}
+	if jkt := dpopKey(ctx); jkt != "" {
+		token.TokenType = "DPoP"
+		token.JKT = jkt
+	}
+	for _, option := range options {
+		option(token)
+	}
+	token.AccessToken, err = s.cfg.GenerateAccessToken(ctx, token)
+	return
+}
+
+// issueToken create and save access token, with refresh token when asked and enabled.
+func (s *OauthServer) issueToken(ctx context.Context, clientID, userID, scope string, withRefresh bool) (token *OauthToken, err error) {
+	token, err = s.newToken(ctx, clientID, userID, scope)
+	if err != nil {
+		return
+	}
+	if withRefresh && s.cfg.RefreshTokenTTL > 0 {
+		if token.RefreshToken, err = RandomHash(); err != nil {
+			return
+		}
+		if token.FamilyID, err = UUIDv4(); err != nil {
+			return
+		}
+		token.FamilyExpiresAt = token.IssuedAt.Add(s.cfg.RefreshTokenTTL)
+		token.RefreshExpiresAt = s.refreshExpiresAt(token.IssuedAt, token.FamilyExpiresAt)
+	}
+	err = s.cfg.Tokens.SaveToken(ctx, token)
+	return
+}
+
+// parseOauthRequest parse form body, or query of GET request, into req.
+//
+// Values are copied as fiber strings are only valid within the handler
+// while they may be kept in stores, e.g. scope of issued token.
+func parseOauthRequest(c *fiber.Ctx, req *OauthRequest) (err error) {
+	if c.Method() == fiber.MethodGet {
+		err = c.QueryParser(req)
+	} else {
+		err = c.BodyParser(req)
+	}
+	if err != nil {
+		return
+	}
+	v := reflect.ValueOf(req).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		switch field.Kind() {
+		case reflect.String:
+			field.SetString(utils.CopyString(field.String()))
+		case reflect.Slice:
+			values := make([]string, field.Len())
+			for j := range values {
+				values[j] = utils.CopyString(field.Index(j).String())
+			}
+			field.Set(reflect.ValueOf(values))
+		}
+	}
+	return
+}
+
+// writeOauthError respond error as JSON with its status.
+func writeOauthError(c *fiber.Ctx, oauthErr *OauthError) error {
+	return c.Status(oauthErr.Status).JSON(oauthErr.Response())
+}
+
+// redirectOauthError send error back to client redirect uri.
+func redirectOauthError(c *fiber.Ctx, redirectURI string, responseType ResponseType, state string, oauthErr *OauthError) error {
+	resp := oauthErr.Response()
+	resp.State = state
+	return redirectOauthResponse(c, redirectURI, responseType, resp)
+}
+
+// redirectOauthResponse redirect with response in query, or fragment for implicit grant.
+func redirectOauthResponse(c *fiber.Ctx, redirectURI string, responseType ResponseType, resp OauthResponse) error {
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		return writeOauthError(c, NewOauthError(InvalidRequest, "invalid redirect_uri"))
+	}
+	params := u.Query()
+	if responseType == ResponseTypeToken {
+		params = url.Values{}
+	}
+	for key, value := range oauthResponseValues(resp) {
+		params.Set(key, value)
+	}
+	if responseType == ResponseTypeToken {
+		u.Fragment = params.Encode()
+	} else {
+		u.RawQuery = params.Encode()
+	}
+	return c.Redirect(u.String(), http.StatusFound)
+}
+
+// oauthResponseValues return non-empty response fields by their json name.
+func oauthResponseValues(resp OauthResponse) map[string]string {
+	values := map[string]string{
+		"code":              resp.Code,
+		"state":             resp.State,
+		"scope":             resp.Scope,
+		"error":             string(resp.Error),
+		"error_description": resp.ErrorDesc,
+		"error_uri":         resp.ErrorURI,
+		"access_token":      resp.AccessToken,
+		"id_token":          resp.IDToken,
+		"token_type":        resp.TokenType,
+		"refresh_token":     resp.RefreshToken,
+	}
+	if resp.ExpiresIn != 0 {
+		values["expires_in"] = strconv.Itoa(resp.ExpiresIn)
+	}
+	for key, value := range values {
+		if value == "" {
+			delete(values, key)
+		}
+	}
+	return values
+}
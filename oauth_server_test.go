@@ -0,0 +1,667 @@
+package helpers
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/segmentio/encoding/json"
+)
+
+func newTestOauthServer(t *testing.T) (app *fiber.App, server *OauthServer, store *MemoryOauthStore) {
+	store = NewMemoryOauthStore()
+	ctx := context.Background()
+	utils.AssertEqual(t, nil, store.SaveClient(ctx, &OauthClient{
+		ID:           "web",
+		Secret:       "web-secret",
+		RedirectURIs: []string{"https://client.example.com/cb"},
+		Scope:        "profile orders",
+	}))
+	utils.AssertEqual(t, nil, store.SaveClient(ctx, &OauthClient{
+		ID:           "spa",
+		Public:       true,
+		RedirectURIs: []string{"https://spa.example.com/cb"},
+	}))
+
+	server = NewOauthServer(OauthServerConfig{
+		Issuer:  "https://auth.example.com",
+		Clients: store,
+		Codes:   store,
+		Tokens:  store,
+		AuthorizeUser: func(c *fiber.Ctx, req *OauthRequest) (string, error) {
+			return "user-1", nil
+		},
+		AuthenticateUser: func(ctx context.Context, username, password string) (string, error) {
+			if username == "alice" && password == "secret" {
+				return "user-1", nil
+			}
+			return "", fiber.ErrUnauthorized
+		},
+	})
+	app = fiber.New()
+	server.Register(app)
+	return
+}
+
+func oauthTokenRequest(t *testing.T, app *fiber.App, form url.Values, header ...string) (status int, resp OauthResponse) {
+	return oauthTokenRequestTo(t, app, "/token", form, header...)
+}
+
+func oauthFormRequest(path string, form url.Values, header ...string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationForm)
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	return req
+}
+
+func oauthTokenRequestTo(t *testing.T, app *fiber.App, path string, form url.Values, header ...string) (status int, resp OauthResponse) {
+	httpResp, err := app.Test(oauthFormRequest(path, form, header...))
+	utils.AssertEqual(t, nil, err)
+	body, err := io.ReadAll(httpResp.Body)
+	utils.AssertEqual(t, nil, err)
+	if len(body) != 0 {
+		utils.AssertEqual(t, nil, json.Unmarshal(body, &resp))
+	}
+	return httpResp.StatusCode, resp
+}
+
+func oauthAuthorize(t *testing.T, app *fiber.App, query url.Values) *url.URL {
+	httpResp, err := app.Test(httptest.NewRequest(http.MethodGet, "/authorize?"+query.Encode(), nil))
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, http.StatusFound, httpResp.StatusCode)
+	location, err := url.Parse(httpResp.Header.Get(fiber.HeaderLocation))
+	utils.AssertEqual(t, nil, err)
+	return location
+}
+
+func TestOauthServerCodeFlow(t *testing.T) {
+	t.Parallel()
+	app, _, _ := newTestOauthServer(t)
+
+	location := oauthAuthorize(t, app, url.Values{
+		"client_id":     {"web"},
+		"response_type": {"code"},
+		"scope":         {"profile"},
+		"state":         {"xyz"},
+	})
+	utils.AssertEqual(t, "xyz", location.Query().Get("state"))
+	code := location.Query().Get("code")
+	utils.AssertEqual(t, true, code != "")
+
+	status, resp := oauthTokenRequest(t, app, url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"client_id":     {"web"},
+		"client_secret": {"web-secret"},
+	})
+	utils.AssertEqual(t, http.StatusOK, status)
+	utils.AssertEqual(t, "Bearer", resp.TokenType)
+	utils.AssertEqual(t, "profile", resp.Scope)
+	utils.AssertEqual(t, true, resp.RefreshToken != "")
+
+	// code is single use
+	status, resp = oauthTokenRequest(t, app, url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"client_id":     {"web"},
+		"client_secret": {"web-secret"},
+	})
+	utils.AssertEqual(t, http.StatusBadRequest, status)
+	utils.AssertEqual(t, InvalidGrant, resp.Error)
+}
+
+func TestOauthServerAuthorizeErrors(t *testing.T) {
+	t.Parallel()
+	app, _, _ := newTestOauthServer(t)
+
+	// unregistered redirect uri must not redirect
+	httpResp, err := app.Test(httptest.NewRequest(http.MethodGet, "/authorize?"+url.Values{
+		"client_id":     {"web"},
+		"response_type": {"code"},
+		"redirect_uri":  {"https://evil.example.com/cb"},
+	}.Encode(), nil))
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, http.StatusBadRequest, httpResp.StatusCode)
+
+	location := oauthAuthorize(t, app, url.Values{
+		"client_id":     {"web"},
+		"response_type": {"code"},
+		"scope":         {"admin"},
+		"state":         {"xyz"},
+	})
+	utils.AssertEqual(t, string(InvalidScope), location.Query().Get("error"))
+	utils.AssertEqual(t, "xyz", location.Query().Get("state"))
+}
+
+func TestOauthServerGrants(t *testing.T) {
+	t.Parallel()
+	app, _, _ := newTestOauthServer(t)
+
+	status, resp := oauthTokenRequest(t, app, url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {"web"},
+		"client_secret": {"wrong"},
+	})
+	utils.AssertEqual(t, http.StatusUnauthorized, status)
+	utils.AssertEqual(t, InvalidClient, resp.Error)
+
+	status, resp = oauthTokenRequest(t, app, url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {"web"},
+		"client_secret": {"web-secret"},
+		"scope":         {"orders"},
+	})
+	utils.AssertEqual(t, http.StatusOK, status)
+	utils.AssertEqual(t, "", resp.RefreshToken)
+
+	status, resp = oauthTokenRequest(t, app, url.Values{
+		"grant_type": {"password"},
+		"client_id":  {"spa"},
+		"username":   {"alice"},
+		"password":   {"secret"},
+	})
+	utils.AssertEqual(t, http.StatusOK, status)
+	refreshToken := resp.RefreshToken
+
+	status, resp = oauthTokenRequest(t, app, url.Values{
+		"grant_type":    {"refresh_token"},
+		"client_id":     {"spa"},
+		"refresh_token": {refreshToken},
+	})
+	utils.AssertEqual(t, http.StatusOK, status)
+	utils.AssertEqual(t, true, resp.AccessToken != "")
+
+	status, resp = oauthTokenRequest(t, app, url.Values{
+		"grant_type": {"client_credentials"},
+		"client_id":  {"spa"},
+	})
+	utils.AssertEqual(t, http.StatusBadRequest, status)
+	utils.AssertEqual(t, UnauthorizedClient, resp.Error)
+}
+
+func TestOauthServerRefreshDownscope(t *testing.T) {
+	t.Parallel()
+	app, _, store := newTestOauthServer(t)
+
+	_, resp := oauthTokenRequest(t, app, url.Values{
+		"grant_type": {"password"},
+		"client_id":  {"spa"},
+		"username":   {"alice"},
+		"password":   {"secret"},
+		"scope":      {"orders profile"},
+	})
+	utils.AssertEqual(t, "orders profile", resp.Scope)
+
+	refresh := func(refreshToken, scope string) (int, OauthResponse) {
+		return oauthTokenRequest(t, app, url.Values{
+			"grant_type":    {"refresh_token"},
+			"client_id":     {"spa"},
+			"refresh_token": {refreshToken},
+			"scope":         {scope},
+		})
+	}
+
+	// narrow access token does not narrow refresh token
+	status, resp := refresh(resp.RefreshToken, "profile")
+	utils.AssertEqual(t, http.StatusOK, status)
+	utils.AssertEqual(t, "profile", resp.Scope)
+	access, err := store.GetAccessToken(context.Background(), resp.AccessToken)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, "profile", access.Scope)
+	utils.AssertEqual(t, "orders profile", access.GrantScope())
+
+	status, resp = refresh(resp.RefreshToken, "orders profile")
+	utils.AssertEqual(t, http.StatusOK, status)
+	utils.AssertEqual(t, "orders profile", resp.Scope)
+
+	status, resp = refresh(resp.RefreshToken, "orders admin")
+	utils.AssertEqual(t, http.StatusBadRequest, status)
+	utils.AssertEqual(t, InvalidScope, resp.Error)
+}
+
+func TestOauthServerRefreshRotation(t *testing.T) {
+	t.Parallel()
+	store := NewMemoryOauthStore()
+	utils.AssertEqual(t, nil, store.SaveClient(context.Background(), &OauthClient{ID: "spa", Public: true}))
+
+	var mu sync.Mutex
+	var events []OauthEventType
+	server := NewOauthServer(OauthServerConfig{
+		Clients: store,
+		Tokens:  store,
+		AuthenticateUser: func(ctx context.Context, username, password string) (string, error) {
+			return "user-1", nil
+		},
+		RefreshTokenIdleTTL: time.Hour,
+		OnEvent: func(ctx context.Context, event OauthEvent) {
+			mu.Lock()
+			events = append(events, event.Type)
+			mu.Unlock()
+		},
+	})
+	app := fiber.New()
+	server.Register(app)
+
+	refresh := func(refreshToken string) (int, OauthResponse) {
+		return oauthTokenRequest(t, app, url.Values{
+			"grant_type":    {"refresh_token"},
+			"client_id":     {"spa"},
+			"refresh_token": {refreshToken},
+		})
+	}
+
+	_, resp := oauthTokenRequest(t, app, url.Values{
+		"grant_type": {"password"},
+		"client_id":  {"spa"},
+		"username":   {"alice"},
+		"password":   {"secret"},
+	})
+	first := resp.RefreshToken
+
+	token, err := store.GetRefreshToken(context.Background(), first)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, true, token.FamilyID != "")
+	utils.AssertEqual(t, true, token.RefreshExpiresAt.Before(token.FamilyExpiresAt))
+
+	status, resp := refresh(first)
+	utils.AssertEqual(t, http.StatusOK, status)
+	second := resp.RefreshToken
+	utils.AssertEqual(t, true, second != "" && second != first)
+
+	// reuse of rotated token revokes the whole family
+	status, resp = refresh(first)
+	utils.AssertEqual(t, http.StatusBadRequest, status)
+	utils.AssertEqual(t, InvalidGrant, resp.Error)
+	status, _ = refresh(second)
+	utils.AssertEqual(t, http.StatusBadRequest, status)
+
+	mu.Lock()
+	utils.AssertEqual(t, []OauthEventType{EventRefreshRotated, EventRefreshReused, EventFamilyRevoked}, events)
+	mu.Unlock()
+
+	// absolute lifetime
+	_, resp = oauthTokenRequest(t, app, url.Values{
+		"grant_type": {"password"},
+		"client_id":  {"spa"},
+		"username":   {"alice"},
+		"password":   {"secret"},
+	})
+	token, err = store.GetRefreshToken(context.Background(), resp.RefreshToken)
+	utils.AssertEqual(t, nil, err)
+	token.FamilyExpiresAt = time.Now().Add(-time.Second)
+	status, _ = refresh(resp.RefreshToken)
+	utils.AssertEqual(t, http.StatusBadRequest, status)
+}
+
+func TestOauthServerPKCE(t *testing.T) {
+	t.Parallel()
+	app, _, store := newTestOauthServer(t)
+	spa, _ := store.GetClient(context.Background(), "spa")
+	spa.RequirePKCE = true
+
+	location := oauthAuthorize(t, app, url.Values{
+		"client_id":     {"spa"},
+		"response_type": {"code"},
+	})
+	utils.AssertEqual(t, string(InvalidRequest), location.Query().Get("error"))
+
+	verifier, err := GeneratePKCEVerifier()
+	utils.AssertEqual(t, nil, err)
+	location = oauthAuthorize(t, app, url.Values{
+		"client_id":             {"spa"},
+		"response_type":         {"code"},
+		"code_challenge":        {PKCEChallenge(verifier, PKCES256)},
+		"code_challenge_method": {string(PKCES256)},
+	})
+	code := location.Query().Get("code")
+	utils.AssertEqual(t, true, code != "")
+
+	status, resp := oauthTokenRequest(t, app, url.Values{
+		"grant_type":    {"authorization_code"},
+		"client_id":     {"spa"},
+		"code":          {code},
+		"code_verifier": {verifier + "x"},
+	})
+	utils.AssertEqual(t, http.StatusBadRequest, status)
+	utils.AssertEqual(t, InvalidGrant, resp.Error)
+
+	location = oauthAuthorize(t, app, url.Values{
+		"client_id":             {"spa"},
+		"response_type":         {"code"},
+		"code_challenge":        {PKCEChallenge(verifier, PKCES256)},
+		"code_challenge_method": {string(PKCES256)},
+	})
+	status, resp = oauthTokenRequest(t, app, url.Values{
+		"grant_type":    {"authorization_code"},
+		"client_id":     {"spa"},
+		"code":          {location.Query().Get("code")},
+		"code_verifier": {verifier},
+	})
+	utils.AssertEqual(t, http.StatusOK, status)
+	utils.AssertEqual(t, true, resp.AccessToken != "")
+}
+
+func TestOauthServerIntrospectRevoke(t *testing.T) {
+	t.Parallel()
+	app, server, _ := newTestOauthServer(t)
+	app.Get("/orders", OauthResource(OauthResourceConfig{Validator: server.Validator()}), func(c *fiber.Ctx) error {
+		cc := Ctx{c}
+		return c.SendString(cc.TokenInfo().ClientID)
+	})
+
+	_, token := oauthTokenRequest(t, app, url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {"web"},
+		"client_secret": {"web-secret"},
+	})
+
+	status, _ := oauthTokenRequestTo(t, app, "/introspect", url.Values{
+		"token":         {token.AccessToken},
+		"client_id":     {"web"},
+		"client_secret": {"web-secret"},
+	})
+	utils.AssertEqual(t, http.StatusOK, status)
+
+	req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+	req.Header.Set(fiber.HeaderAuthorization, "Bearer "+token.AccessToken)
+	httpResp, err := app.Test(req)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, http.StatusOK, httpResp.StatusCode)
+
+	status, _ = oauthTokenRequestTo(t, app, "/revoke", url.Values{
+		"token":         {token.AccessToken},
+		"client_id":     {"web"},
+		"client_secret": {"web-secret"},
+	})
+	utils.AssertEqual(t, http.StatusOK, status)
+
+	httpResp, err = app.Test(req)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, http.StatusUnauthorized, httpResp.StatusCode)
+	utils.AssertEqual(t, true, strings.Contains(httpResp.Header.Get(fiber.HeaderWWWAuthenticate), "invalid_token"))
+}
+
+func TestRemoteIntrospector(t *testing.T) {
+	t.Parallel()
+	app, _, _ := newTestOauthServer(t)
+	_, token := oauthTokenRequest(t, app, url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {"web"},
+		"client_secret": {"web-secret"},
+	})
+
+	calls := 0
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		resp, err := app.Test(r)
+		utils.AssertEqual(t, nil, err)
+		w.WriteHeader(resp.StatusCode)
+		_, _ = io.Copy(w, resp.Body)
+	}))
+	defer remote.Close()
+
+	introspector := &RemoteIntrospector{
+		Endpoint:     remote.URL + "/introspect",
+		ClientID:     "web",
+		ClientSecret: "web-secret",
+	}
+	for i := 0; i < 2; i++ {
+		info, err := introspector.ValidateToken(context.Background(), token.AccessToken)
+		utils.AssertEqual(t, nil, err)
+		utils.AssertEqual(t, true, info.Active)
+		utils.AssertEqual(t, "web", info.ClientID)
+	}
+	utils.AssertEqual(t, 1, calls)
+
+	// inactive results are not cached
+	for i := 0; i < 2; i++ {
+		info, err := introspector.ValidateToken(context.Background(), "random-token")
+		utils.AssertEqual(t, nil, err)
+		utils.AssertEqual(t, false, info.Active)
+	}
+	utils.AssertEqual(t, 3, calls)
+	utils.AssertEqual(t, 1, introspector.lru.Len())
+
+	// least recently used token is evicted when cache is full
+	_, other := oauthTokenRequest(t, app, url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {"web"},
+		"client_secret": {"web-secret"},
+	})
+	introspector.CacheSize = 1
+	_, err := introspector.ValidateToken(context.Background(), other.AccessToken)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, 1, introspector.lru.Len())
+	_, err = introspector.ValidateToken(context.Background(), token.AccessToken)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, 5, calls)
+
+	// unreachable introspection endpoint does not leak its address to caller
+	down := &RemoteIntrospector{Endpoint: "http://127.0.0.1:1/introspect"}
+	resource := fiber.New()
+	resource.Get("/orders", OauthResource(OauthResourceConfig{Validator: down}), func(c *fiber.Ctx) error {
+		return c.SendStatus(http.StatusOK)
+	})
+	req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+	req.Header.Set(fiber.HeaderAuthorization, "Bearer "+token.AccessToken)
+	httpResp, err := resource.Test(req)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, http.StatusServiceUnavailable, httpResp.StatusCode)
+	body, _ := io.ReadAll(httpResp.Body)
+	utils.AssertEqual(t, "token validation unavailable", string(body))
+}
+
+func TestOauthServerOpenIDConnect(t *testing.T) {
+	t.Parallel()
+	store := NewMemoryOauthStore()
+	utils.AssertEqual(t, nil, store.SaveClient(context.Background(), &OauthClient{
+		ID:           "web",
+		Secret:       "web-secret",
+		RedirectURIs: []string{"https://client.example.com/cb"},
+	}))
+	key, err := GenerateJWTKey(ES256)
+	utils.AssertEqual(t, nil, err)
+	keys := NewJWTKeySet(key)
+	server := NewOauthServer(OauthServerConfig{
+		Issuer:  "https://auth.example.com",
+		Clients: store,
+		Keys:    keys,
+		AuthorizeUser: func(c *fiber.Ctx, req *OauthRequest) (string, error) {
+			return "user-1", nil
+		},
+		UserClaims: OIDCUserClaimsFunc(func(ctx context.Context, userID string, scopes []string) (map[string]interface{}, error) {
+			return map[string]interface{}{"name": "Alice"}, nil
+		}),
+	})
+	app := fiber.New()
+	server.Register(app)
+
+	location := oauthAuthorize(t, app, url.Values{
+		"client_id":     {"web"},
+		"response_type": {"code"},
+		"scope":         {"openid profile"},
+		"nonce":         {"n-0S6_WzA2Mj"},
+	})
+	_, resp := oauthTokenRequest(t, app, url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {location.Query().Get("code")},
+		"client_id":     {"web"},
+		"client_secret": {"web-secret"},
+	})
+	utils.AssertEqual(t, true, resp.IDToken != "")
+
+	var idClaims IDTokenClaims
+	_, err = keys.Verify(resp.IDToken, &idClaims)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, "user-1", idClaims.Subject)
+	utils.AssertEqual(t, "n-0S6_WzA2Mj", idClaims.Nonce)
+	utils.AssertEqual(t, true, idClaims.Audience.Contains("web"))
+	utils.AssertEqual(t, tokenHash(ES256, resp.AccessToken), idClaims.AtHash)
+
+	req := httptest.NewRequest(http.MethodGet, "/userinfo", nil)
+	req.Header.Set(fiber.HeaderAuthorization, "Bearer "+resp.AccessToken)
+	httpResp, err := app.Test(req)
+	utils.AssertEqual(t, nil, err)
+	var userInfo map[string]interface{}
+	utils.AssertEqual(t, nil, json.NewDecoder(httpResp.Body).Decode(&userInfo))
+	utils.AssertEqual(t, "user-1", userInfo["sub"])
+	utils.AssertEqual(t, "Alice", userInfo["name"])
+
+	httpResp, err = app.Test(httptest.NewRequest(http.MethodGet, "/.well-known/openid-configuration", nil))
+	utils.AssertEqual(t, nil, err)
+	var discovery OpenIDConfiguration
+	utils.AssertEqual(t, nil, json.NewDecoder(httpResp.Body).Decode(&discovery))
+	utils.AssertEqual(t, "https://auth.example.com/token", discovery.TokenEndpoint)
+	utils.AssertEqual(t, []string{"ES256"}, discovery.IDTokenSigningAlgValuesSupported)
+
+	// shared secret key is not in JWKS but still signs ID token
+	secret, err := GenerateJWTKey(HS256)
+	utils.AssertEqual(t, nil, err)
+	hsServer := NewOauthServer(OauthServerConfig{Issuer: "https://auth.example.com", Clients: store, Keys: NewJWTKeySet(secret)})
+	utils.AssertEqual(t, 0, len(hsServer.cfg.Keys.JWKS().Keys))
+	utils.AssertEqual(t, []string{"HS256"}, hsServer.OpenIDConfiguration().IDTokenSigningAlgValuesSupported)
+}
+
+func TestOauthServerDeviceFlow(t *testing.T) {
+	t.Parallel()
+	app, server, _ := newTestOauthServer(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/device_authorization", strings.NewReader(url.Values{
+		"client_id": {"spa"},
+		"scope":     {"profile"},
+	}.Encode()))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationForm)
+	httpResp, err := app.Test(req)
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, http.StatusOK, httpResp.StatusCode)
+	var device OauthDeviceResponse
+	utils.AssertEqual(t, nil, json.NewDecoder(httpResp.Body).Decode(&device))
+	utils.AssertEqual(t, 9, len(device.UserCode))
+	utils.AssertEqual(t, 5, device.Interval)
+
+	poll := url.Values{
+		"grant_type":  {string(GrantTypeDevice)},
+		"client_id":   {"spa"},
+		"device_code": {device.DeviceCode},
+	}
+	_, resp := oauthTokenRequest(t, app, poll)
+	utils.AssertEqual(t, AuthorizationPending, resp.Error)
+	_, resp = oauthTokenRequest(t, app, poll)
+	utils.AssertEqual(t, SlowDown, resp.Error)
+
+	utils.AssertEqual(t, nil, server.ApproveDevice(context.Background(), strings.ToLower(device.UserCode), "user-1"))
+	status, resp := oauthTokenRequest(t, app, poll)
+	utils.AssertEqual(t, http.StatusOK, status)
+	utils.AssertEqual(t, "profile", resp.Scope)
+
+	_, resp = oauthTokenRequest(t, app, poll)
+	utils.AssertEqual(t, InvalidGrant, resp.Error)
+}
+
+func TestOauthServerDeviceVerifyPostOnly(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	store := NewMemoryOauthStore()
+	rendered := 0
+	server := NewOauthServer(OauthServerConfig{
+		Issuer:  "https://auth.example.com",
+		Clients: store,
+		Tokens:  store,
+		Devices: store,
+		VerifyDevice: func(c *fiber.Ctx, device *OauthDeviceCode) (string, error) {
+			if device == nil {
+				rendered++
+				return "", c.SendString("enter code")
+			}
+			// signed in user approve whatever device is submitted
+			return "user-1", nil
+		},
+	})
+	app := fiber.New()
+	server.Register(app)
+	utils.AssertEqual(t, nil, store.SaveDeviceCode(ctx, &OauthDeviceCode{
+		DeviceCode: "device-1",
+		UserCode:   "BCDF-GHJK",
+		Status:     DevicePending,
+		ExpiresAt:  time.Now().Add(time.Minute),
+	}))
+
+	// link in other site only render form, it does not approve device
+	httpResp, err := app.Test(httptest.NewRequest(http.MethodGet, "/device?user_code=BCDF-GHJK", nil))
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, http.StatusOK, httpResp.StatusCode)
+	utils.AssertEqual(t, 1, rendered)
+	device, err := store.GetDeviceCode(ctx, "device-1")
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, DevicePending, device.Status)
+
+	httpResp, err = app.Test(oauthFormRequest("/device", url.Values{"user_code": {"BCDF-GHJK"}}))
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, http.StatusOK, httpResp.StatusCode)
+	device, err = store.GetDeviceCode(ctx, "device-1")
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, DeviceApproved, device.Status)
+	utils.AssertEqual(t, "user-1", device.UserID)
+}
+
+func TestMemoryOauthStoreDeviceCode(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	store := NewMemoryOauthStore()
+	utils.AssertEqual(t, nil, store.SaveDeviceCode(ctx, &OauthDeviceCode{
+		DeviceCode: "device-1",
+		UserCode:   "BCDF-GHJK",
+		Status:     DevicePending,
+		ExpiresAt:  time.Now().Add(time.Minute),
+	}))
+
+	// poll read pending code before approval, its update must not overwrite approval
+	polled, err := store.GetDeviceCode(ctx, "device-1")
+	utils.AssertEqual(t, nil, err)
+	approved, err := store.GetDeviceCodeByUserCode(ctx, "bcdf ghjk")
+	utils.AssertEqual(t, nil, err)
+	approved.Status = DeviceApproved
+	utils.AssertEqual(t, nil, store.UpdateDeviceCode(ctx, approved, DevicePending))
+	polled.LastPolledAt = time.Now()
+	utils.AssertEqual(t, fiber.ErrNotFound, store.UpdateDeviceCode(ctx, polled, DevicePending))
+
+	var (
+		wg       sync.WaitGroup
+		consumed int32
+	)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if device, err := store.ConsumeDeviceCode(ctx, "device-1"); err == nil && device.Status == DeviceApproved {
+				atomic.AddInt32(&consumed, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	utils.AssertEqual(t, int32(1), consumed)
+	_, err = store.GetDeviceCodeByUserCode(ctx, "BCDFGHJK")
+	utils.AssertEqual(t, fiber.ErrNotFound, err)
+}
+
+func TestGenerateUserCode(t *testing.T) {
+	t.Parallel()
+	code, err := GenerateUserCode()
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, byte('-'), code[4])
+	for _, r := range NormalizeUserCode(code) {
+		utils.AssertEqual(t, true, strings.ContainsRune(userCodeAlphabet, r))
+	}
+	utils.AssertEqual(t, "BCDFGHJK", NormalizeUserCode("bcdf-ghjk "))
+}
@@ -0,0 +1,173 @@
+package helpers
+
+import (
+	"context"
+	"crypto/subtle"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// OauthState pending authorization request of OAuth client, keyed by state.
+type OauthState struct {
+	State        string    `json:"state"`
+	Nonce        string    `json:"nonce,omitempty"`
+	CodeVerifier string    `json:"code_verifier,omitempty"`
+	RedirectURI  string    `json:"redirect_uri,omitempty"`
+	ReturnTo     string    `json:"return_to,omitempty"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+// VerifyNonce check nonce of ID token against the one sent with authorization request.
+func (st *OauthState) VerifyNonce(nonce string) error {
+	if st.Nonce == "" || subtle.ConstantTimeCompare([]byte(st.Nonce), []byte(nonce)) != 1 {
+		return NewOauthError(InvalidToken, "nonce mismatch")
+	}
+	return nil
+}
+
+// OauthStateStore keep pending states, TakeState must remove the state so it is used once.
+type OauthStateStore interface {
+	SaveState(ctx context.Context, state *OauthState) error
+	TakeState(ctx context.Context, state string) (*OauthState, error)
+}
+
+// OauthStateConfig config of OauthStateManager
+type OauthStateConfig struct {
+	// Store of pending states
+	//
+	// Optional. Default: NewMemoryOauthStore()
+	Store OauthStateStore
+
+	// TTL of state
+	//
+	// Optional. Default: 10 minutes
+	TTL time.Duration
+
+	// CookieName binds state to user agent
+	//
+	// Optional. Default: "oauth_state"
+	CookieName string
+
+	// CookieSecure set Secure flag of state cookie
+	//
+	// Optional. Default: false
+	CookieSecure bool
+}
+
+// OauthStateManager generate and verify state and nonce of authorization
+// request to stop CSRF on OAuth callbacks.
+type OauthStateManager struct {
+	cfg OauthStateConfig
+}
+
+// NewOauthStateManager create OauthStateManager with defaults of unset config.
+func NewOauthStateManager(config ...OauthStateConfig) *OauthStateManager {
+	var cfg OauthStateConfig
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+	if cfg.Store == nil {
+		cfg.Store = NewMemoryOauthStore()
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = 10 * time.Minute
+	}
+	if cfg.CookieName == "" {
+		cfg.CookieName = "oauth_state"
+	}
+	return &OauthStateManager{cfg: cfg}
+}
+
+// New generate and store state, nonce and PKCE verifier for redirect uri.
+func (m *OauthStateManager) New(ctx context.Context, redirectURI string) (state *OauthState, err error) {
+	state = &OauthState{
+		RedirectURI: redirectURI,
+		ExpiresAt:   time.Now().Add(m.cfg.TTL),
+	}
+	if state.State, err = RandomHash(); err != nil {
+		return nil, err
+	}
+	if state.Nonce, err = RandomHash(); err != nil {
+		return nil, err
+	}
+	if state.CodeVerifier, err = GeneratePKCEVerifier(); err != nil {
+		return nil, err
+	}
+	if err = m.cfg.Store.SaveState(ctx, state); err != nil {
+		return nil, err
+	}
+	return
+}
+
+// Verify take stored state once, unknown or expired state is invalid_request.
+func (m *OauthStateManager) Verify(ctx context.Context, state string) (*OauthState, error) {
+	if state == "" {
+		return nil, NewOauthError(InvalidRequest, "missing state")
+	}
+	stored, err := m.cfg.Store.TakeState(ctx, state)
+	if err != nil || time.Now().After(stored.ExpiresAt) {
+		return nil, NewOauthError(InvalidRequest, "state mismatch")
+	}
+	return stored, nil
+}
+
+// Begin create state as New and bind it to user agent with cookie.
+func (m *OauthStateManager) Begin(c *fiber.Ctx, redirectURI string) (state *OauthState, err error) {
+	if state, err = m.New(c.UserContext(), redirectURI); err != nil {
+		return
+	}
+	c.Cookie(&fiber.Cookie{
+		Name:     m.cfg.CookieName,
+		Value:    state.State,
+		Path:     "/",
+		Expires:  state.ExpiresAt,
+		Secure:   m.cfg.CookieSecure,
+		HTTPOnly: true,
+		SameSite: fiber.CookieSameSiteLaxMode,
+	})
+	return
+}
+
+// Callback verify state of callback request against cookie and store,
+// error returned by authorization server is passed as OauthError.
+func (m *OauthStateManager) Callback(c *fiber.Ctx) (state *OauthState, err error) {
+	value := c.Query("state")
+	cookie := c.Cookies(m.cfg.CookieName)
+	c.ClearCookie(m.cfg.CookieName)
+	if value == "" || subtle.ConstantTimeCompare([]byte(value), []byte(cookie)) != 1 {
+		return nil, NewOauthError(InvalidRequest, "state mismatch")
+	}
+	if state, err = m.Verify(c.UserContext(), value); err != nil {
+		return
+	}
+	if errCode := c.Query("error"); errCode != "" {
+		oauthErr := NewOauthError(OauthErr(errCode), c.Query("error_description"))
+		oauthErr.URI = c.Query("error_uri")
+		return state, oauthErr
+	}
+	if c.Query("code") == "" && c.Query("access_token") == "" {
+		return state, NewOauthError(InvalidRequest, "missing code")
+	}
+	return
+}
+
+func (s *MemoryOauthStore) SaveState(ctx context.Context, state *OauthState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sweepExpired(time.Now())
+	record := *state
+	s.states[state.State] = &record
+	return nil
+}
+
+func (s *MemoryOauthStore) TakeState(ctx context.Context, state string) (*OauthState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record, ok := s.states[state]
+	if !ok {
+		return nil, fiber.ErrNotFound
+	}
+	delete(s.states, state)
+	return record, nil
+}
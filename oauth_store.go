@@ -0,0 +1,384 @@
+package helpers
+
+import (
+	"context"
+	"crypto/subtle"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ClientAuthMethod token endpoint authentication method of client (RFC 7591 section 2)
+type ClientAuthMethod string
+
+// ClientAuthMethod constant
+const (
+	ClientAuthNone    ClientAuthMethod = "none"
+	ClientSecretBasic ClientAuthMethod = "client_secret_basic"
+	ClientSecretPost  ClientAuthMethod = "client_secret_post"
+	PrivateKeyJWT     ClientAuthMethod = "private_key_jwt"
+)
+
+// OauthClient registered OAuth client
+type OauthClient struct {
+	ID string `json:"client_id"`
+	// Secret plaintext secret of statically configured client, registered clients keep hashed Secrets.
+	Secret string `json:"-"`
+	// Public clients (SPA, mobile) have no secret and cannot use client_credentials grant.
+	Public       bool        `json:"public"`
+	RedirectURIs []string    `json:"redirect_uris"`
+	GrantTypes   []GrantType `json:"grant_types,omitempty"`
+	// Scope allowed scopes, space delimited, empty allows any.
+	Scope string `json:"scope,omitempty"`
+	// RequirePKCE reject authorization code requests without code_challenge.
+	RequirePKCE bool `json:"require_pkce,omitempty"`
+
+	Name       string           `json:"client_name,omitempty"`
+	ClientURI  string           `json:"client_uri,omitempty"`
+	Contacts   []string         `json:"contacts,omitempty"`
+	AuthMethod ClientAuthMethod `json:"token_endpoint_auth_method,omitempty"`
+	JWKSURI    string           `json:"jwks_uri,omitempty"`
+	JWKS       *JWKSet          `json:"jwks,omitempty"`
+
+	// Secrets hashed secrets, old secrets stay valid until ExpiresAt after rotation.
+	Secrets []OauthClientSecret `json:"secrets,omitempty"`
+	// RegistrationTokenHash hashed registration access token (RFC 7592)
+	RegistrationTokenHash string    `json:"registration_token_hash,omitempty"`
+	IssuedAt              time.Time `json:"client_id_issued_at,omitempty"`
+}
+
+// OauthClientSecret hashed client secret
+type OauthClientSecret struct {
+	Hash      string    `json:"hash"`
+	CreatedAt time.Time `json:"created_at"`
+	// ExpiresAt zero never expires
+	ExpiresAt time.Time `json:"expires_at,omitempty"`
+}
+
+// VerifySecret compare secret with plaintext Secret in constant time,
+// or with unexpired hashed Secrets.
+func (c *OauthClient) VerifySecret(secret string) bool {
+	if secret == "" {
+		return false
+	}
+	if c.Secret != "" && subtle.ConstantTimeCompare([]byte(c.Secret), []byte(secret)) == 1 {
+		return true
+	}
+	now := time.Now()
+	for _, hashed := range c.Secrets {
+		if !hashed.ExpiresAt.IsZero() && now.After(hashed.ExpiresAt) {
+			continue
+		}
+		if CheckPasswordHashString(secret, hashed.Hash) == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowGrant report whether client may use grant type, empty GrantTypes allows any.
+func (c *OauthClient) AllowGrant(grantType GrantType) bool {
+	if len(c.GrantTypes) == 0 {
+		return !(c.Public && grantType == GrantTypeClient)
+	}
+	for _, allowed := range c.GrantTypes {
+		if allowed == grantType {
+			return true
+		}
+	}
+	return false
+}
+
+// OauthCode issued authorization code
+type OauthCode struct {
+	Code        string    `json:"code"`
+	ClientID    string    `json:"client_id"`
+	UserID      string    `json:"user_id"`
+	RedirectURI string    `json:"redirect_uri,omitempty"`
+	Scope       string    `json:"scope,omitempty"`
+	ExpiresAt   time.Time `json:"expires_at"`
+
+	CodeChallenge       string     `json:"code_challenge,omitempty"`
+	CodeChallengeMethod PKCEMethod `json:"code_challenge_method,omitempty"`
+
+	// OpenID Connect
+	Nonce    string    `json:"nonce,omitempty"`
+	AuthTime time.Time `json:"auth_time,omitempty"`
+}
+
+// OauthToken issued access token and its refresh token
+type OauthToken struct {
+	AccessToken      string    `json:"access_token"`
+	RefreshToken     string    `json:"refresh_token,omitempty"`
+	TokenType        string    `json:"token_type"`
+	ClientID         string    `json:"client_id"`
+	UserID           string    `json:"user_id,omitempty"`
+	Scope            string    `json:"scope,omitempty"`
+	IssuedAt         time.Time `json:"issued_at"`
+	ExpiresAt        time.Time `json:"expires_at"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at,omitempty"`
+	IDToken          string    `json:"id_token,omitempty"`
+
+	// RefreshScope scope of refresh token when access token was downscoped, empty means Scope
+	RefreshScope string `json:"refresh_scope,omitempty"`
+
+	// FamilyID shared by refresh tokens rotated from the same grant
+	FamilyID string `json:"family_id,omitempty"`
+	// FamilyExpiresAt absolute lifetime of refresh token family
+	FamilyExpiresAt time.Time `json:"family_expires_at,omitempty"`
+
+	// Audience restrict token to resource servers, e.g. by token exchange
+	Audience []string `json:"audience,omitempty"`
+	// Actor acting party of delegated token (RFC 8693 section 4.1)
+	Actor *JWTActor `json:"act,omitempty"`
+	// IssuedTokenType token type URI of token exchange response
+	IssuedTokenType string `json:"issued_token_type,omitempty"`
+
+	// JKT thumbprint of DPoP key the token is bound to (RFC 9449)
+	JKT string `json:"jkt,omitempty"`
+}
+
+// GrantScope return scope of refresh token, the whole grant before any downscope of access token.
+func (t *OauthToken) GrantScope() string {
+	if t.RefreshScope != "" {
+		return t.RefreshScope
+	}
+	return t.Scope
+}
+
+// Confirmation return cnf claim of DPoP bound token, nil for bearer token.
+func (t *OauthToken) Confirmation() *JWTConfirmation {
+	if t.JKT == "" {
+		return nil
+	}
+	return &JWTConfirmation{JKT: t.JKT}
+}
+
+// Response render token as OauthResponse.
+func (t *OauthToken) Response() OauthResponse {
+	return OauthResponse{
+		AccessToken:  t.AccessToken,
+		TokenType:    t.TokenType,
+		ExpiresIn:    int(t.ExpiresAt.Sub(t.IssuedAt).Seconds()),
+		RefreshToken: t.RefreshToken,
+		IDToken:      t.IDToken,
+		Scope:        t.Scope,
+
+		IssuedTokenType: t.IssuedTokenType,
+	}
+}
+
+// OauthClientStore lookup registered clients.
+//
+// Stores return fiber.ErrNotFound when record does not exist.
+type OauthClientStore interface {
+	GetClient(ctx context.Context, clientID string) (*OauthClient, error)
+}
+
+// OauthClientRegistry manage registered clients.
+type OauthClientRegistry interface {
+	OauthClientStore
+	SaveClient(ctx context.Context, client *OauthClient) error
+	RemoveClient(ctx context.Context, clientID string) error
+}
+
+// OauthCodeStore keep authorization codes until exchanged.
+type OauthCodeStore interface {
+	SaveCode(ctx context.Context, code *OauthCode) error
+	// TakeCode return and delete code, so it can be used once only.
+	TakeCode(ctx context.Context, code string) (*OauthCode, error)
+}
+
+// OauthTokenStore keep issued tokens.
+type OauthTokenStore interface {
+	SaveToken(ctx context.Context, token *OauthToken) error
+	GetAccessToken(ctx context.Context, accessToken string) (*OauthToken, error)
+	GetRefreshToken(ctx context.Context, refreshToken string) (*OauthToken, error)
+	// RemoveToken delete both access and refresh token of record.
+	RemoveToken(ctx context.Context, token *OauthToken) error
+}
+
+// MemoryOauthStore in-memory implementation of OAuth stores, for tests and single instance deployment.
+type MemoryOauthStore struct {
+	mu            sync.RWMutex
+	clients       map[string]*OauthClient
+	codes         map[string]*OauthCode
+	accessTokens  map[string]*OauthToken
+	refreshTokens map[string]*OauthToken
+	deviceCodes   map[string]*OauthDeviceCode
+	userCodes     map[string]string
+	states        map[string]*OauthState
+	usedRefresh   map[string]usedRefreshToken
+	pushed        map[string]*OauthPushedRequest
+	consents      map[string]map[string]*OauthConsent
+
+	sweptAt time.Time
+}
+
+// memoryStoreSweepInterval how often expired short lived records are deleted from MemoryOauthStore
+const memoryStoreSweepInterval = time.Minute
+
+// NewMemoryOauthStore create empty MemoryOauthStore.
+func NewMemoryOauthStore() *MemoryOauthStore {
+	return &MemoryOauthStore{
+		clients:       make(map[string]*OauthClient),
+		codes:         make(map[string]*OauthCode),
+		accessTokens:  make(map[string]*OauthToken),
+		refreshTokens: make(map[string]*OauthToken),
+		deviceCodes:   make(map[string]*OauthDeviceCode),
+		userCodes:     make(map[string]string),
+		states:        make(map[string]*OauthState),
+		usedRefresh:   make(map[string]usedRefreshToken),
+		pushed:        make(map[string]*OauthPushedRequest),
+		consents:      make(map[string]map[string]*OauthConsent),
+	}
+}
+
+// sweepExpired delete expired states, codes, pushed requests, device codes, tokens and used refresh tokens
+// at most once per memoryStoreSweepInterval, caller must hold lock.
+func (s *MemoryOauthStore) sweepExpired(now time.Time) {
+	if now.Sub(s.sweptAt) < memoryStoreSweepInterval {
+		return
+	}
+	s.sweptAt = now
+	for key, record := range s.states {
+		if now.After(record.ExpiresAt) {
+			delete(s.states, key)
+		}
+	}
+	for key, record := range s.codes {
+		if now.After(record.ExpiresAt) {
+			delete(s.codes, key)
+		}
+	}
+	for key, record := range s.pushed {
+		if now.After(record.ExpiresAt) {
+			delete(s.pushed, key)
+		}
+	}
+	for key, record := range s.deviceCodes {
+		if now.After(record.ExpiresAt) {
+			s.removeDeviceCode(key)
+		}
+	}
+	for key, token := range s.accessTokens {
+		if tokenExpired(token, now) {
+			delete(s.accessTokens, key)
+		}
+	}
+	for key, token := range s.refreshTokens {
+		if tokenExpired(token, now) {
+			delete(s.refreshTokens, key)
+		}
+	}
+	for key, record := range s.usedRefresh {
+		if !record.expiresAt.IsZero() && now.After(record.expiresAt) {
+			delete(s.usedRefresh, key)
+		}
+	}
+}
+
+// tokenExpired report token record is useless, both access token and refresh token expired.
+// Refresh token without own expiry lives until its family expiry, or forever.
+func tokenExpired(token *OauthToken, now time.Time) bool {
+	if !now.After(token.ExpiresAt) {
+		return false
+	}
+	if token.RefreshToken == "" {
+		return true
+	}
+	refreshExpiresAt := token.RefreshExpiresAt
+	if refreshExpiresAt.IsZero() {
+		refreshExpiresAt = token.FamilyExpiresAt
+	}
+	return !refreshExpiresAt.IsZero() && now.After(refreshExpiresAt)
+}
+
+// SaveClient add or replace client.
+func (s *MemoryOauthStore) SaveClient(ctx context.Context, client *OauthClient) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clients[client.ID] = client
+	return nil
+}
+
+func (s *MemoryOauthStore) RemoveClient(ctx context.Context, clientID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.clients, clientID)
+	return nil
+}
+
+func (s *MemoryOauthStore) GetClient(ctx context.Context, clientID string) (*OauthClient, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	client, ok := s.clients[clientID]
+	if !ok {
+		return nil, fiber.ErrNotFound
+	}
+	return client, nil
+}
+
+func (s *MemoryOauthStore) SaveCode(ctx context.Context, code *OauthCode) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sweepExpired(time.Now())
+	s.codes[code.Code] = code
+	return nil
+}
+
+func (s *MemoryOauthStore) TakeCode(ctx context.Context, code string) (*OauthCode, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record, ok := s.codes[code]
+	if !ok {
+		return nil, fiber.ErrNotFound
+	}
+	delete(s.codes, code)
+	if time.Now().After(record.ExpiresAt) {
+		return nil, fiber.ErrNotFound
+	}
+	return record, nil
+}
+
+func (s *MemoryOauthStore) SaveToken(ctx context.Context, token *OauthToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sweepExpired(time.Now())
+	s.accessTokens[token.AccessToken] = token
+	if token.RefreshToken != "" {
+		s.refreshTokens[token.RefreshToken] = token
+	}
+	return nil
+}
+
+func (s *MemoryOauthStore) GetAccessToken(ctx context.Context, accessToken string) (*OauthToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	token, ok := s.accessTokens[accessToken]
+	if !ok || time.Now().After(token.ExpiresAt) {
+		return nil, fiber.ErrNotFound
+	}
+	return token, nil
+}
+
+func (s *MemoryOauthStore) GetRefreshToken(ctx context.Context, refreshToken string) (*OauthToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	token, ok := s.refreshTokens[refreshToken]
+	if !ok || (!token.RefreshExpiresAt.IsZero() && time.Now().After(token.RefreshExpiresAt)) {
+		return nil, fiber.ErrNotFound
+	}
+	return token, nil
+}
+
+func (s *MemoryOauthStore) RemoveToken(ctx context.Context, token *OauthToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.accessTokens, token.AccessToken)
+	if token.RefreshToken != "" && s.refreshTokens[token.RefreshToken] == token {
+		delete(s.refreshTokens, token.RefreshToken)
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+package helpers
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ScopeOpenID requests OpenID Connect authentication
+const ScopeOpenID = "openid"
+
+// OIDCUserClaimsProvider supply user claims of /userinfo, e.g. name and email,
+// limited to what granted scopes allow.
+type OIDCUserClaimsProvider interface {
+	UserClaims(ctx context.Context, userID string, scopes []string) (map[string]interface{}, error)
+}
+
+// OIDCUserClaimsFunc adapts an ordinary function to OIDCUserClaimsProvider.
+type OIDCUserClaimsFunc func(ctx context.Context, userID string, scopes []string) (map[string]interface{}, error)
+
+// UserClaims calls f(ctx, userID, scopes).
+func (f OIDCUserClaimsFunc) UserClaims(ctx context.Context, userID string, scopes []string) (map[string]interface{}, error) {
+	return f(ctx, userID, scopes)
+}
+
+// IDTokenClaims OpenID Connect ID token claims
+type IDTokenClaims struct {
+	JWTClaims
+	AuthTime        int64  `json:"auth_time,omitempty"`
+	Nonce           string `json:"nonce,omitempty"`
+	AtHash          string `json:"at_hash,omitempty"`
+	AuthorizedParty string `json:"azp,omitempty"`
+}
+
+// OpenIDConfiguration OpenID Provider metadata (OpenID Connect Discovery 1.0)
+type OpenIDConfiguration struct {
+	Issuer                                     string   `json:"issuer"`
+	AuthorizationEndpoint                      string   `json:"authorization_endpoint"`
+	TokenEndpoint                              string   `json:"token_endpoint"`
+	UserinfoEndpoint                           string   `json:"userinfo_endpoint,omitempty"`
+	JWKSURI                                    string   `json:"jwks_uri"`
+	IntrospectionEndpoint                      string   `json:"introspection_endpoint,omitempty"`
+	RevocationEndpoint                         string   `json:"revocation_endpoint,omitempty"`
+	DeviceAuthorizationEndpoint                string   `json:"device_authorization_endpoint,omitempty"`
+	RegistrationEndpoint                       string   `json:"registration_endpoint,omitempty"`
+	PushedAuthorizationRequestEndpoint         string   `json:"pushed_authorization_request_endpoint,omitempty"`
+	ScopesSupported                            []string `json:"scopes_supported,omitempty"`
+	ResponseTypesSupported                     []string `json:"response_types_supported"`
+	GrantTypesSupported                        []string `json:"grant_types_supported,omitempty"`
+	SubjectTypesSupported                      []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported           []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported          []string `json:"token_endpoint_auth_methods_supported,omitempty"`
+	TokenEndpointAuthSigningAlgValuesSupported []string `json:"token_endpoint_auth_signing_alg_values_supported,omitempty"`
+	CodeChallengeMethodsSupported              []string `json:"code_challenge_methods_supported,omitempty"`
+	ClaimsSupported                            []string `json:"claims_supported,omitempty"`
+	DPoPSigningAlgValuesSupported              []string `json:"dpop_signing_alg_values_supported,omitempty"`
+	RequirePushedAuthorizationRequests         bool     `json:"require_pushed_authorization_requests,omitempty"`
+	RequestParameterSupported                  bool     `json:"request_parameter_supported"`
+	RequestObjectSigningAlgValuesSupported     []string `json:"request_object_signing_alg_values_supported,omitempty"`
+}
+
+// tokenHash left-most half of hash of value, as at_hash and c_hash.
+func tokenHash(alg JWTAlgorithm, value string) string {
+	var sum []byte
+	if alg == EdDSA {
+		h := sha512.Sum512([]byte(value))
+		sum = h[:]
+	} else {
+		h := sha256.Sum256([]byte(value))
+		sum = h[:]
+	}
+	return base64.RawURLEncoding.EncodeToString(sum[:len(sum)/2])
+}
+
+// issueIDToken set ID token of token when openid scope was granted and server has signing keys.
+func (s *OauthServer) issueIDToken(token *OauthToken, nonce string, authTime time.Time) (err error) {
+	if s.cfg.Keys == nil || token.UserID == "" || !ParseScope(token.Scope).Has(ScopeOpenID) {
+		return
+	}
+	key := s.cfg.Keys.SigningKey()
+	if key == nil {
+		return
+	}
+	claims := IDTokenClaims{
+		JWTClaims: JWTClaims{
+			Issuer:    s.cfg.Issuer,
+			Subject:   token.UserID,
+			Audience:  JWTAudience{token.ClientID},
+			ExpiresAt: token.ExpiresAt.Unix(),
+			IssuedAt:  token.IssuedAt.Unix(),
+		},
+		Nonce:           nonce,
+		AtHash:          tokenHash(key.Algorithm, token.AccessToken),
+		AuthorizedParty: token.ClientID,
+	}
+	if !authTime.IsZero() {
+		claims.AuthTime = authTime.Unix()
+	}
+	token.IDToken, err = key.Sign(claims)
+	return
+}
+
+// UserInfoHandler handle OpenID Connect UserInfo endpoint, requires Bearer or DPoP access token with openid scope.
+func (s *OauthServer) UserInfoHandler(c *fiber.Ctx) (err error) {
+	ctx := c.UserContext()
+
+	auth, err := ExtractAuthString(c.Get(fiber.HeaderAuthorization))
+	if err != nil || (auth.Type != BearerToken && auth.Type != DPoPToken) {
+		return resourceError(c, s.cfg.Issuer, "", s.cfg.DPoP, nil)
+	}
+	info, err := s.Validator().ValidateToken(ctx, auth.Token)
+	if err != nil || !info.Active || info.Sub == "" {
+		return resourceError(c, s.cfg.Issuer, auth.Type, s.cfg.DPoP, NewOauthError(InvalidToken, "invalid or expired token"))
+	}
+	// DPoP bound token is rejected as Bearer, so stolen token cannot be replayed without the key
+	if oauthErr := verifyDPoPAccess(c, s.cfg.DPoP, auth, info.Cnf); oauthErr != nil {
+		return resourceError(c, s.cfg.Issuer, auth.Type, s.cfg.DPoP, oauthErr)
+	}
+	if !ParseScope(info.Scope).Has(ScopeOpenID) {
+		return resourceError(c, s.cfg.Issuer, auth.Type, s.cfg.DPoP, NewOauthError(InsufficientScope, "openid scope required"), ScopeOpenID)
+	}
+
+	claims := map[string]interface{}{}
+	if s.cfg.UserClaims != nil {
+		if claims, err = s.cfg.UserClaims.UserClaims(ctx, info.Sub, strings.Fields(info.Scope)); err != nil {
+			return writeOauthError(c, AsOauthError(err))
+		}
+	}
+	if claims == nil {
+		claims = map[string]interface{}{}
+	}
+	claims["sub"] = info.Sub
+	return c.JSON(claims)
+}
+
+// OpenIDConfiguration return provider metadata from configured endpoints,
+// Register must be mounted at Issuer.
+func (s *OauthServer) OpenIDConfiguration() OpenIDConfiguration {
+	issuer := strings.TrimSuffix(s.cfg.Issuer, "/")
+	config := OpenIDConfiguration{
+		Issuer:                                     s.cfg.Issuer,
+		AuthorizationEndpoint:                      issuer + "/authorize",
+		TokenEndpoint:                              issuer + "/token",
+		UserinfoEndpoint:                           issuer + "/userinfo",
+		JWKSURI:                                    issuer + "/.well-known/jwks.json",
+		IntrospectionEndpoint:                      issuer + "/introspect",
+		RevocationEndpoint:                         issuer + "/revoke",
+		DeviceAuthorizationEndpoint:                issuer + "/device_authorization",
+		PushedAuthorizationRequestEndpoint:         issuer + "/par",
+		RequirePushedAuthorizationRequests:         s.cfg.RequirePushedRequests,
+		RequestParameterSupported:                  true,
+		RequestObjectSigningAlgValuesSupported:     []string{string(RS256), string(ES256), string(EdDSA)},
+		ScopesSupported:                            []string{ScopeOpenID},
+		SubjectTypesSupported:                      []string{"public"},
+		TokenEndpointAuthMethodsSupported:          []string{string(ClientSecretBasic), string(ClientSecretPost), string(PrivateKeyJWT), string(ClientAuthNone)},
+		TokenEndpointAuthSigningAlgValuesSupported: []string{string(RS256), string(ES256), string(EdDSA)},
+		CodeChallengeMethodsSupported:              []string{string(PKCES256), string(PKCEPlain)},
+		ClaimsSupported:                            []string{"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce", "at_hash", "azp"},
+	}
+	if s.cfg.Registry != nil && s.cfg.InitialAccessToken != nil {
+		config.RegistrationEndpoint = issuer + "/register"
+	}
+	for _, responseType := range s.ResponseTypes() {
+		config.ResponseTypesSupported = append(config.ResponseTypesSupported, string(responseType))
+	}
+	for _, alg := range s.cfg.DPoP.Algorithms() {
+		config.DPoPSigningAlgValuesSupported = append(config.DPoPSigningAlgValuesSupported, string(alg))
+	}
+	for _, grantType := range s.GrantTypes() {
+		config.GrantTypesSupported = append(config.GrantTypesSupported, string(grantType))
+	}
+	// ID token is signed by current signing key, HS256 key is not published in JWKS
+	if s.cfg.Keys != nil {
+		if key := s.cfg.Keys.SigningKey(); key != nil {
+			config.IDTokenSigningAlgValuesSupported = []string{string(key.Algorithm)}
+		}
+	}
+	return config
+}
+
+// DiscoveryHandler serve /.well-known/openid-configuration
+func (s *OauthServer) DiscoveryHandler(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+	return c.JSON(s.OpenIDConfiguration())
+}
@@ -0,0 +1,277 @@
+package helpers
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Argon2Params cost of argon2id password hash
+type Argon2Params struct {
+	// Memory in KiB
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  int
+	KeyLength   uint32
+}
+
+// DefaultArgon2Params second recommended option of RFC 9106 section 4
+var DefaultArgon2Params = Argon2Params{
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 4,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+const (
+	argon2idPrefix = "$argon2id$"
+	apr1Prefix     = "$apr1$"
+	sha1Prefix     = "{SHA}"
+)
+
+// HashPasswordArgon2id hash password with argon2id into PHC string format,
+// e.g. $argon2id$v=19$m=65536,t=3,p=4$salt$hash
+func HashPasswordArgon2id(password string, params ...Argon2Params) (string, error) {
+	p := DefaultArgon2Params
+	if len(params) != 0 {
+		p = params[0]
+	}
+	if p.Iterations == 0 || p.Parallelism == 0 || p.KeyLength == 0 {
+		return "", fiber.NewError(http.StatusInternalServerError, "invalid argon2id parameters")
+	}
+	salt := make([]byte, p.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s", argon2idPrefix, argon2.Version, p.Memory, p.Iterations, p.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// checkPasswordFormat compare password with bcrypt or argon2id hash,
+// mismatch is bcrypt.ErrMismatchedHashAndPassword for every format.
+func checkPasswordFormat(password, hash []byte) error {
+	if !strings.HasPrefix(string(hash), argon2idPrefix) {
+		return bcrypt.CompareHashAndPassword(hash, password)
+	}
+	p, salt, expected, err := parseArgon2id(string(hash))
+	if err != nil {
+		return err
+	}
+	actual := argon2.IDKey(password, salt, p.Iterations, p.Memory, p.Parallelism, uint32(len(expected)))
+	if subtle.ConstantTimeCompare(expected, actual) != 1 {
+		return bcrypt.ErrMismatchedHashAndPassword
+	}
+	return nil
+}
+
+// checkHtpasswdFormat compare password with htpasswd hash, unsalted {SHA} and
+// MD5 $apr1$ are accepted here only to serve existing htpasswd files.
+func checkHtpasswdFormat(password, hash []byte) error {
+	var expected []byte
+	switch h := string(hash); {
+	case strings.HasPrefix(h, apr1Prefix):
+		salt, _, ok := strings.Cut(h[len(apr1Prefix):], "$")
+		if !ok {
+			return fiber.NewError(http.StatusInternalServerError, "invalid apr1 hash")
+		}
+		expected = []byte(apr1Crypt(password, []byte(salt)))
+	case strings.HasPrefix(h, sha1Prefix):
+		sum := sha1.Sum(password)
+		expected = []byte(sha1Prefix + base64.StdEncoding.EncodeToString(sum[:]))
+	default:
+		return checkPasswordFormat(password, hash)
+	}
+	if subtle.ConstantTimeCompare(expected, hash) != 1 {
+		return bcrypt.ErrMismatchedHashAndPassword
+	}
+	return nil
+}
+
+// parseArgon2id decode PHC string of argon2id hash.
+func parseArgon2id(hash string) (p Argon2Params, salt, key []byte, err error) {
+	var version int
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		err = fiber.NewError(http.StatusInternalServerError, "invalid argon2id hash")
+		return
+	}
+	if _, err = fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		err = fiber.NewError(http.StatusInternalServerError, "unsupported argon2id version")
+		return
+	}
+	if _, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Iterations, &p.Parallelism); err != nil {
+		err = fiber.NewError(http.StatusInternalServerError, "invalid argon2id parameters")
+		return
+	}
+	// argon2.IDKey panics on zero iterations or parallelism
+	if p.Iterations == 0 || p.Parallelism == 0 {
+		err = fiber.NewError(http.StatusInternalServerError, "invalid argon2id parameters")
+		return
+	}
+	if salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		err = fiber.NewError(http.StatusInternalServerError, "invalid argon2id salt")
+		return
+	}
+	if key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil || len(key) == 0 {
+		err = fiber.NewError(http.StatusInternalServerError, "invalid argon2id hash")
+		return
+	}
+	p.SaltLength, p.KeyLength = len(salt), uint32(len(key))
+	return
+}
+
+// dummyHashLike hash random password with format and cost of hash, so checking unknown
+// user against it take the same time as checking user of hash. Default bcrypt of DefaultCost.
+func dummyHashLike(hash string) (dummy []byte, err error) {
+	password, err := RandomHash()
+	if err != nil {
+		return
+	}
+	switch {
+	case strings.HasPrefix(hash, argon2idPrefix):
+		if p, _, _, err := parseArgon2id(hash); err == nil {
+			h, err := HashPasswordArgon2id(password, p)
+			return []byte(h), err
+		}
+	case strings.HasPrefix(hash, apr1Prefix):
+		return []byte(apr1Crypt([]byte(password), []byte(password[:8]))), nil
+	case strings.HasPrefix(hash, sha1Prefix):
+		sum := sha1.Sum([]byte(password))
+		return []byte(sha1Prefix + base64.StdEncoding.EncodeToString(sum[:])), nil
+	}
+	cost, err := bcrypt.Cost([]byte(hash))
+	if err != nil {
+		cost = bcrypt.DefaultCost
+	}
+	return bcrypt.GenerateFromPassword([]byte(password), cost)
+}
+
+// dummyHashFormat return key of hash format and cost, hashes of the same key take the same time to check.
+func dummyHashFormat(hash string) string {
+	switch {
+	case strings.HasPrefix(hash, argon2idPrefix):
+		if p, _, _, err := parseArgon2id(hash); err == nil {
+			return fmt.Sprintf("%s%+v", argon2idPrefix, p)
+		}
+	case strings.HasPrefix(hash, apr1Prefix):
+		return apr1Prefix
+	case strings.HasPrefix(hash, sha1Prefix):
+		return sha1Prefix
+	}
+	cost, err := bcrypt.Cost([]byte(hash))
+	if err != nil {
+		cost = bcrypt.DefaultCost
+	}
+	return "bcrypt:" + strconv.Itoa(cost)
+}
+
+// dummyHashes dummy hashes created on first use by format of sample hash,
+// so sample changed by reload of store get matching dummy.
+type dummyHashes struct {
+	mu     sync.Mutex
+	hashes map[string][]byte
+}
+
+// like return dummy hash with format and cost of sample,
+// bcrypt of DefaultCost when it can not be created.
+func (d *dummyHashes) like(sample string) []byte {
+	format := dummyHashFormat(sample)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if dummy, ok := d.hashes[format]; ok {
+		return dummy
+	}
+	dummy, err := dummyHashLike(sample)
+	if err != nil {
+		dummy, _ = bcrypt.GenerateFromPassword([]byte(format), bcrypt.DefaultCost)
+	}
+	if d.hashes == nil {
+		d.hashes = make(map[string][]byte)
+	}
+	d.hashes[format] = dummy
+	return dummy
+}
+
+// apr1Crypt Apache variant of MD5-crypt used by htpasswd -m
+func apr1Crypt(password, salt []byte) string {
+	if len(salt) > 8 {
+		salt = salt[:8]
+	}
+	alt := md5.New()
+	alt.Write(password)
+	alt.Write(salt)
+	alt.Write(password)
+	altSum := alt.Sum(nil)
+
+	ctx := md5.New()
+	ctx.Write(password)
+	ctx.Write([]byte(apr1Prefix))
+	ctx.Write(salt)
+	for i := len(password); i > 0; i -= 16 {
+		if i > 16 {
+			ctx.Write(altSum)
+		} else {
+			ctx.Write(altSum[:i])
+		}
+	}
+	for i := len(password); i > 0; i >>= 1 {
+		if i&1 != 0 {
+			ctx.Write([]byte{0})
+		} else {
+			ctx.Write(password[:1])
+		}
+	}
+	sum := ctx.Sum(nil)
+
+	for i := 0; i < 1000; i++ {
+		round := md5.New()
+		if i&1 != 0 {
+			round.Write(password)
+		} else {
+			round.Write(sum)
+		}
+		if i%3 != 0 {
+			round.Write(salt)
+		}
+		if i%7 != 0 {
+			round.Write(password)
+		}
+		if i&1 != 0 {
+			round.Write(sum)
+		} else {
+			round.Write(password)
+		}
+		sum = round.Sum(nil)
+	}
+
+	const itoa64 = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	var b strings.Builder
+	b.WriteString(apr1Prefix)
+	b.Write(salt)
+	b.WriteByte('$')
+	encode := func(v uint32, n int) {
+		for ; n > 0; n-- {
+			b.WriteByte(itoa64[v&0x3f])
+			v >>= 6
+		}
+	}
+	for _, group := range [][3]int{{0, 6, 12}, {1, 7, 13}, {2, 8, 14}, {3, 9, 15}, {4, 10, 5}} {
+		encode(uint32(sum[group[0]])<<16|uint32(sum[group[1]])<<8|uint32(sum[group[2]]), 4)
+	}
+	encode(uint32(sum[11]), 2)
+	return b.String()
+}
@@ -0,0 +1,87 @@
+package helpers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// PanicReporter receives panics caught by Recover, e.g. to forward them to an error tracker.
+type PanicReporter interface {
+	ReportPanic(c *fiber.Ctx, recovered interface{}, err *Error)
+}
+
+// PanicReporterFunc adapts an ordinary function to PanicReporter.
+type PanicReporterFunc func(c *fiber.Ctx, recovered interface{}, err *Error)
+
+// ReportPanic calls f(c, recovered, err).
+func (f PanicReporterFunc) ReportPanic(c *fiber.Ctx, recovered interface{}, err *Error) {
+	f(c, recovered, err)
+}
+
+// RecoverConfig defines the config for Recover middleware.
+type RecoverConfig struct {
+	// Next defines a function to skip this middleware when returned true.
+	Next func(c *fiber.Ctx) bool
+
+	// Reporter is called with every recovered panic after it has been logged.
+	Reporter PanicReporter
+
+	// EnableStackTrace exposes the panic value and stack trace in the response.
+	// Keep it disabled in production.
+	EnableStackTrace bool
+}
+
+// Recover creates a middleware that turns panics in later handlers into
+// a 500 ResponseForm instead of dropping the connection.
+func Recover(config ...RecoverConfig) fiber.Handler {
+	var cfg RecoverConfig
+	if len(config) != 0 {
+		cfg = config[0]
+	}
+
+	return func(c *fiber.Ctx) (err error) {
+		if cfg.Next != nil && cfg.Next(c) {
+			return c.Next()
+		}
+
+		defer func() {
+			r := recover()
+			if r == nil {
+				return
+			}
+
+			panicErr := &Error{
+				Code:    http.StatusInternalServerError,
+				Source:  GetStackTrace(),
+				Title:   http.StatusText(http.StatusInternalServerError),
+				Message: fmt.Sprintf("panic: %v", r),
+			}
+
+			log.Printf("%s %s from %s\n", c.Method(), c.OriginalURL(), c.IP())
+			panicErr.Log()
+
+			if cfg.Reporter != nil {
+				cfg.Reporter.ReportPanic(c, r, panicErr)
+			}
+
+			respErr := ResponseError{
+				Code:    panicErr.Code,
+				Title:   panicErr.Title,
+				Message: panicErr.Title,
+			}
+			if cfg.EnableStackTrace {
+				respErr.Source = panicErr.Source
+				respErr.Message = panicErr.Message
+			}
+
+			err = c.Status(panicErr.Code).JSON(ResponseForm{
+				Errors: []ResponseError{respErr},
+			})
+		}()
+
+		return c.Next()
+	}
+}
@@ -0,0 +1,38 @@
+package helpers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/segmentio/encoding/json"
+)
+
+func TestRecover(t *testing.T) {
+	t.Parallel()
+
+	var reported interface{}
+	app := fiber.New()
+	app.Use(Recover(RecoverConfig{
+		Reporter: PanicReporterFunc(func(c *fiber.Ctx, recovered interface{}, err *Error) {
+			reported = recovered
+		}),
+	}))
+	app.Get("/panic", func(c *fiber.Ctx) error {
+		panic("boom")
+	})
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/panic", nil))
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, http.StatusInternalServerError, resp.StatusCode)
+	utils.AssertEqual(t, "boom", reported)
+
+	var body ResponseForm
+	utils.AssertEqual(t, nil, json.NewDecoder(resp.Body).Decode(&body))
+	utils.AssertEqual(t, false, body.Success)
+	utils.AssertEqual(t, 1, len(body.Errors))
+	utils.AssertEqual(t, http.StatusInternalServerError, body.Errors[0].Code)
+	utils.AssertEqual(t, nil, body.Errors[0].Source)
+}
@@ -0,0 +1,152 @@
+package helpers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Scope set of OAuth scopes.
+//
+// Scopes may be hierarchical with ":" separator, a granted scope covers
+// its children: "orders" covers "orders:read", "orders:*" covers every
+// child of orders but not orders itself and "*" covers everything.
+type Scope []string
+
+// ParseScope parse space delimited scope, the result is sorted without duplicates.
+func ParseScope(scope string) (s Scope) {
+	seen := make(map[string]bool)
+	for _, v := range strings.Fields(scope) {
+		if !seen[v] {
+			seen[v] = true
+			s = append(s, v)
+		}
+	}
+	sort.Strings(s)
+	return
+}
+
+// String return space delimited scope.
+func (s Scope) String() string {
+	return strings.Join(s, " ")
+}
+
+// Has report whether scope is granted by the set, including wildcard and parent scopes.
+func (s Scope) Has(scope string) bool {
+	for _, granted := range s {
+		if scopeCovers(granted, scope) {
+			return true
+		}
+	}
+	return false
+}
+
+// HasAll report whether every scope is granted by the set.
+func (s Scope) HasAll(scopes ...string) bool {
+	for _, scope := range scopes {
+		if !s.Has(scope) {
+			return false
+		}
+	}
+	return true
+}
+
+// Contains report whether other is a subset of the set.
+func (s Scope) Contains(other Scope) bool {
+	return s.HasAll(other...)
+}
+
+// Intersect return scopes of other that are granted by the set.
+func (s Scope) Intersect(other Scope) (result Scope) {
+	for _, scope := range other {
+		if s.Has(scope) {
+			result = append(result, scope)
+		}
+	}
+	return
+}
+
+// Downscope narrow the set to requested scope of refresh or token exchange,
+// empty requested keeps the set and scope beyond the set is invalid_scope.
+func (s Scope) Downscope(requested string) (Scope, error) {
+	if strings.TrimSpace(requested) == "" {
+		return s, nil
+	}
+	req := ParseScope(requested)
+	for _, scope := range req {
+		if !s.Has(scope) {
+			return nil, NewOauthError(InvalidScope, fmt.Sprintf("scope %q exceeds original grant", scope))
+		}
+	}
+	return req, nil
+}
+
+// scopeCovers report whether granted scope covers required scope.
+func scopeCovers(granted, required string) bool {
+	switch {
+	case granted == required, granted == "*":
+		return true
+	case strings.HasSuffix(granted, ":*"):
+		return strings.HasPrefix(required, granted[:len(granted)-1])
+	}
+	return strings.HasPrefix(required, granted+":")
+}
+
+// scopeAllowed report whether requested scope is allowed, empty allowed allows any.
+//
+// Wildcard "*" and "x:*" are only allowed when listed in allowed as is, even when it is empty.
+func scopeAllowed(requested, allowed string) bool {
+	allowedScope := ParseScope(allowed)
+	for _, scope := range ParseScope(requested) {
+		if isWildcardScope(scope) && !containsString(allowedScope, scope) {
+			return false
+		}
+	}
+	if len(allowedScope) == 0 {
+		return true
+	}
+	return allowedScope.Contains(ParseScope(requested))
+}
+
+// isWildcardScope report whether scope is "*" or "x:*".
+func isWildcardScope(scope string) bool {
+	return scope == "*" || strings.HasSuffix(scope, ":*")
+}
+
+// Scopes returns scopes of token validated by OauthResource or JWTAuth middleware,
+// or of principal authenticated by Auth middleware.
+func (c *Ctx) Scopes() Scope {
+	if info := c.TokenInfo(); info != nil {
+		return ParseScope(info.Scope)
+	}
+	if principal := c.Principal(); principal != nil {
+		return ParseScope(principal.Scope)
+	}
+	return nil
+}
+
+// RequireScopes creates a middleware that requires all scopes in validated token,
+// it must run after OauthResource, JWTAuth or Auth.
+func RequireScopes(scopes ...string) fiber.Handler {
+	required := strings.Join(scopes, " ")
+	return func(c *fiber.Ctx) error {
+		cc := Ctx{c}
+		info, principal := cc.TokenInfo(), cc.Principal()
+		if info == nil && principal == nil {
+			return resourceError(c, "", "", nil, nil)
+		}
+		if !cc.Scopes().HasAll(scopes...) {
+			// insufficient_scope is defined for token schemes only
+			switch {
+			case info != nil && strings.EqualFold(info.TokenType, DPoPToken.Name()):
+				SetAuthChallenge(c, AuthChallenge{Scheme: DPoPToken, Error: InsufficientScope, Scope: required})
+			case info != nil || principal.Scheme == BearerToken:
+				SetAuthChallenge(c, AuthChallenge{Scheme: BearerToken, Error: InsufficientScope, Scope: required})
+			}
+			return fiber.NewError(InsufficientScope.ResourceStatus(), "insufficient scope")
+		}
+		return c.Next()
+	}
+}
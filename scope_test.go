@@ -0,0 +1,85 @@
+package helpers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+func TestScope(t *testing.T) {
+	t.Parallel()
+
+	scope := ParseScope("orders:read  profile orders:read billing:*")
+	utils.AssertEqual(t, "billing:* orders:read profile", scope.String())
+
+	utils.AssertEqual(t, true, scope.Has("orders:read"))
+	utils.AssertEqual(t, false, scope.Has("orders:write"))
+	utils.AssertEqual(t, true, scope.Has("billing:invoices:read"))
+	utils.AssertEqual(t, false, scope.Has("billing"))
+	utils.AssertEqual(t, true, ParseScope("orders").Has("orders:write"))
+	utils.AssertEqual(t, false, ParseScope("orders").Has("ordersx"))
+	utils.AssertEqual(t, true, ParseScope("*").Has("anything"))
+
+	utils.AssertEqual(t, true, scope.Contains(ParseScope("profile orders:read")))
+	utils.AssertEqual(t, "orders:read", scope.Intersect(ParseScope("orders:read orders:write")).String())
+
+	narrowed, err := scope.Downscope("profile")
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, "profile", narrowed.String())
+	narrowed, err = scope.Downscope("")
+	utils.AssertEqual(t, nil, err)
+	utils.AssertEqual(t, scope.String(), narrowed.String())
+	_, err = scope.Downscope("admin")
+	utils.AssertEqual(t, InvalidScope, AsOauthError(err).Err)
+}
+
+func TestScopeAllowed(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		requested, allowed string
+		expected           bool
+	}{
+		{"orders profile", "", true},
+		{"orders:read", "orders", true},
+		{"admin", "orders", false},
+		// wildcard must be listed as is, even for client without scope restriction
+		{"*", "", false},
+		{"orders:*", "", false},
+		{"orders:*", "orders", false},
+		{"orders:* profile", "*", false},
+		{"*", "*", true},
+		{"orders:* profile", "orders:* profile", true},
+		{"orders:read", "*", true},
+	} {
+		utils.AssertEqual(t, tt.expected, scopeAllowed(tt.requested, tt.allowed), tt.requested+" / "+tt.allowed)
+	}
+}
+
+func TestRequireScopes(t *testing.T) {
+	t.Parallel()
+
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals(localsOauthTokenInfo, &OauthTokenInfo{Active: true, Scope: c.Get("X-Scope")})
+		return c.Next()
+	})
+	app.Post("/orders", RequireScopes("orders:write"), func(c *fiber.Ctx) error {
+		return c.SendStatus(http.StatusCreated)
+	})
+
+	for scope, status := range map[string]int{
+		"orders":             http.StatusCreated,
+		"orders:read":        http.StatusForbidden,
+		"orders:write other": http.StatusCreated,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/orders", nil)
+		req.Header.Set("X-Scope", scope)
+		resp, err := app.Test(req)
+		utils.AssertEqual(t, nil, err)
+		utils.AssertEqual(t, status, resp.StatusCode, scope)
+	}
+}
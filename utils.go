@@ -3,7 +3,6 @@ package helpers
 import (
 	"bytes"
 	"crypto/rand"
-	"encoding/base64"
 	"encoding/hex"
 	"fmt"
 	"hash/crc64"
@@ -85,15 +84,15 @@ func HashPassword(password []byte) ([]byte, error) {
 	return bytes, err
 }
 
-// CheckPasswordHash with bcrypt
+// CheckPasswordHash with bcrypt or argon2id hash
 func CheckPasswordHashString(password, hash string) (err error) {
-	err = bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	err = checkPasswordFormat([]byte(password), []byte(hash))
 	return
 }
 
-// CheckPasswordHash with bcrypt
+// CheckPasswordHash with bcrypt or argon2id hash
 func CheckPasswordHash(password, hash []byte) (err error) {
-	err = bcrypt.CompareHashAndPassword(hash, password)
+	err = checkPasswordFormat(password, hash)
 	return
 }
 
@@ -172,33 +171,3 @@ func InitSnowflake(machineIDs ...int) (snowflakeGen *snowflake.Generator) {
 	snowflakeGen = snowflake.New(machineID)
 	return
 }
-
-func ExtractAuthString(authStr string) (auth HttpAuth, err error) {
-	authSlice := strings.Split(authStr, " ")
-	if len(authSlice) != 2 {
-		err = fiber.NewError(http.StatusUnauthorized, "invalid authorize format")
-		return
-	}
-	switch strings.ToLower(authSlice[0]) {
-	case string(BasicAuth):
-		basicBytes, deErr := base64.StdEncoding.DecodeString(authSlice[1])
-		if deErr != nil {
-			err = fiber.NewError(http.StatusUnauthorized, err.Error())
-			return
-		}
-		basicSlice := bytes.Split(basicBytes, []byte(":"))
-		auth = HttpAuth{
-			Type:     BasicAuth,
-			Username: string(basicSlice[0]),
-			Password: string(basicSlice[1]),
-		}
-	case string(BearerToken):
-		auth = HttpAuth{
-			Type:  BearerToken,
-			Token: authSlice[1],
-		}
-	default:
-		err = fiber.NewError(http.StatusUnauthorized, fmt.Sprintf("invalid authorize type: %s", authSlice[0]))
-	}
-	return
-}